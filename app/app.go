@@ -6,8 +6,8 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/state"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -18,6 +18,19 @@ import (
 	emtTypes "github.com/tendermint/ethermint/types"
 )
 
+// CodeTypeBlockFull is returned by DeliverTx for a transaction that does not fit
+// in the gas left in the block. Unlike the abci error codes it does not mean the
+// transaction is invalid, it can be proposed again in the next block.
+const CodeTypeBlockFull abciTypes.CodeType = 1000
+
+// deliverTxResult is the json encoded data of a successful DeliverTx result, the
+// result of abci has no field for the gas. Transactions queued for reordering
+// have no result data.
+type deliverTxResult struct {
+	GasUsed         hexutil.Uint64  `json:"gasUsed"`
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+}
+
 // EthermintApplication implements an ABCI application
 type EthermintApplication struct {
 
@@ -25,24 +38,36 @@ type EthermintApplication struct {
 	// and wrangles other services started by an ethereum node (eg. tx pool)
 	backend *ethereum.Backend // backend ethereum struct
 
-	// a closure to return the latest current state from the ethereum blockchain
-	currentState func() (*state.StateDB, error)
-
 	// an ethereum rpc client we can forward queries to
 	rpcClient *rpc.Client
 
 	// strategy for validator compensation
 	strategy *emtTypes.Strategy
+
+	// results of eth_call queries, nil if disabled
+	callCache *callCache
+
+	// tendermint height of the current block
+	height uint64
+	// tendermint height the pending ethereum block started at, 0 if none started yet
+	blockStart uint64
+	// whether the ethereum block is committed at the current height, set in EndBlock
+	commits bool
+	// transactions delivered at the current height, applied in EndBlock if
+	// they are reordered
+	queuedTxs []*ethTypes.Transaction
 }
 
 // NewEthermintApplication creates the abci application for ethermint
 func NewEthermintApplication(backend *ethereum.Backend,
 	client *rpc.Client, strategy *emtTypes.Strategy) (*EthermintApplication, error) {
 	app := &EthermintApplication{
-		backend:      backend,
-		rpcClient:    client,
-		currentState: backend.Ethereum().BlockChain().State,
-		strategy:     strategy,
+		backend:   backend,
+		rpcClient: client,
+		strategy:  strategy,
+	}
+	if config := backend.EthermintConfig(); config.CallCacheSize > 0 {
+		app.callCache = newCallCache(int(config.CallCacheSize), config.CallCacheTTL)
 	}
 
 	err := app.backend.ResetWork(app.Receiver()) // init the block results
@@ -68,9 +93,14 @@ func (app *EthermintApplication) Info() abciTypes.ResponseInfo {
 		}
 	}
 
+	// heights that were not committed to the chain are replayed by tendermint
+	lastHeight, err := app.backend.TendermintHeight(height.Uint64())
+	if err != nil {
+		lastHeight = height.Uint64() * app.batchSize() // committed before heights were recorded
+	}
 	return abciTypes.ResponseInfo{
 		Data:             "ABCIEthereum",
-		LastBlockHeight:  height.Uint64(),
+		LastBlockHeight:  lastHeight,
 		LastBlockAppHash: hash[:],
 	}
 }
@@ -98,7 +128,15 @@ func (app *EthermintApplication) CheckTx(txBytes []byte) abciTypes.Result {
 	return app.validateTx(tx)
 }
 
-// DeliverTx executes a transaction against the latest state
+// queuedTxLog is the log of the DeliverTx results of the transactions queued
+// for reordering
+const queuedTxLog = "queued, executed at the end of the height"
+
+// DeliverTx executes a transaction against the latest state. With ReorderTxs
+// the transaction is only queued: the OK result carries neither the gas used
+// nor the contract address, and does not mean the transaction is included.
+// Queued transactions that fail at the end of the height are left out of the
+// block, their receipt is the only record of the execution.
 func (app *EthermintApplication) DeliverTx(txBytes []byte) abciTypes.Result {
 	tx, err := decodeTx(txBytes)
 	if err != nil {
@@ -106,40 +144,102 @@ func (app *EthermintApplication) DeliverTx(txBytes []byte) abciTypes.Result {
 	}
 
 	log.Info("Got DeliverTx", "tx", tx)
-	err = app.backend.DeliverTx(tx)
-	if err != nil {
-		log.Warn("DeliverTx error", "err", err)
+	if app.backend.EthermintConfig().ReorderTxs {
+		app.queuedTxs = append(app.queuedTxs, tx)
+		return abciTypes.NewResultOK(nil, queuedTxLog)
+	}
+	receipt, err := app.backend.DeliverTx(tx)
+	if rejected, ok := err.(*ethereum.TxRejectedError); ok && rejected.Err == ethereum.ErrBlockFull {
+		log.Info("DeliverTx block is full", "hash", tx.Hash())
+
+		return abciTypes.NewError(CodeTypeBlockFull, err.Error())
+	} else if ok {
+		log.Warn("DeliverTx rejected transaction", "hash", tx.Hash(), "err", err)
+
+		return abciTypes.ErrBaseInvalidInput.AppendLog(err.Error())
+	} else if err != nil {
+		log.Error("DeliverTx error", "hash", tx.Hash(), "err", err)
 
 		return abciTypes.ErrInternalError.AppendLog(err.Error())
 	}
 	app.CollectTx(tx)
 
-	return abciTypes.OK
+	result := deliverTxResult{GasUsed: hexutil.Uint64(receipt.GasUsed.Uint64())}
+	if tx.To() == nil {
+		result.ContractAddress = &receipt.ContractAddress
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Error("DeliverTx error encoding result", "hash", tx.Hash(), "err", err)
+
+		return abciTypes.ErrInternalError.AppendLog(err.Error())
+	}
+	return abciTypes.NewResultOK(data, "")
 }
 
 // BeginBlock starts a new Ethereum block
 func (app *EthermintApplication) BeginBlock(hash []byte, tmHeader *abciTypes.Header) {
 	log.Info("BeginBlock")
+	app.height = tmHeader.Height
 
-	// update the eth header with the tendermint header
-	app.backend.UpdateHeaderWithTimeInfo(tmHeader)
+	// update the eth header with the tendermint header that starts the block
+	if app.blockStart == 0 {
+		app.blockStart = app.height
+		app.backend.UpdateHeaderWithTimeInfo(tmHeader)
+	}
+	app.backend.BeginBlock(tmHeader)
+	app.backend.SetTendermintHeight(app.height)
 }
 
-// EndBlock accumulates rewards for the validators and updates them
+// EndBlock accumulates rewards for the validators and updates them, with the
+// changes collected by the strategy and those read from the ethereum state
 func (app *EthermintApplication) EndBlock(height uint64) abciTypes.ResponseEndBlock {
 	log.Info("EndBlock")
-	app.backend.AccumulateRewards(app.strategy)
-	return app.GetUpdatedValidators()
+	if len(app.queuedTxs) > 0 {
+		app.deliverQueuedTxs()
+	}
+	app.commits = app.closesBatch(height) && !app.holdsBlock(height)
+	if app.commits {
+		app.backend.AccumulateRewards(app.strategy)
+	}
+	response := app.GetUpdatedValidators()
+	response.Diffs = append(response.Diffs, app.backend.EndBlock(app.strategy)...)
+	return response
+}
+
+// deliverQueuedTxs applies the transactions queued at this height sorted by
+// nonce per sender. Their DeliverTx results were already returned, so those
+// that fail are only logged.
+func (app *EthermintApplication) deliverQueuedTxs() {
+	txs, _, errs := app.backend.DeliverTxsByNonce(app.queuedTxs)
+	app.queuedTxs = nil
+	for i, tx := range txs {
+		if errs[i] != nil {
+			log.Warn("Queued transaction left out of the block", "hash", tx.Hash(), "err", errs[i])
+			continue
+		}
+		app.CollectTx(tx)
+	}
 }
 
 // Commit commits the block and returns a hash of the current state
 func (app *EthermintApplication) Commit() abciTypes.Result {
 	log.Info("Commit")
+	if !app.commits {
+		root := app.backend.IntermediateRoot()
+		return abciTypes.NewResultOK(root[:], "")
+	}
+
 	blockHash, err := app.backend.Commit(app.Receiver())
+	if _, ok := err.(*ethereum.HaltError); ok {
+		log.Error("Halting block processing, restart the node", "err", err)
+		return abciTypes.ErrInternalError.AppendLog(err.Error())
+	}
 	if err != nil {
 		log.Warn("Error getting latest ethereum state", "err", err)
 		return abciTypes.ErrInternalError.AppendLog(err.Error())
 	}
+	app.blockStart = 0
 	return abciTypes.NewResultOK(blockHash[:], "")
 }
 
@@ -150,6 +250,32 @@ func (app *EthermintApplication) Query(query abciTypes.RequestQuery) abciTypes.R
 	if err := json.Unmarshal(query.Data, &in); err != nil {
 		return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: err.Error()}
 	}
+
+	// calls against committed blocks are cached per latest block
+	var head common.Hash
+	var key string
+	cacheable := app.callCache != nil && isCommittedCall(in)
+	if cacheable {
+		head = app.backend.Ethereum().BlockChain().CurrentBlock().Hash()
+		params, err := json.Marshal(in.Params)
+		if err != nil {
+			return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: err.Error()}
+		}
+		key = string(params)
+		if bytes, ok := app.callCache.get(head, key); ok {
+			return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: bytes}
+		}
+	}
+
+	// queries on the pending block hold a state copy slot while they run
+	if isPendingQuery(in) {
+		release, err := app.backend.AcquireStateCopy()
+		if err != nil {
+			return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+		}
+		defer release()
+	}
+
 	var result interface{}
 	if err := app.rpcClient.Call(&result, in.Method, in.Params...); err != nil {
 		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
@@ -158,17 +284,47 @@ func (app *EthermintApplication) Query(query abciTypes.RequestQuery) abciTypes.R
 	if err != nil {
 		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
 	}
+	if cacheable {
+		app.callCache.put(head, key, bytes)
+	}
 	return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: bytes}
 }
 
 //-------------------------------------------------------
 
+// batchSize returns the number of tendermint heights per ethereum block
+func (app *EthermintApplication) batchSize() uint64 {
+	if size := app.backend.EthermintConfig().BlockBatchSize; size > 1 {
+		return size
+	}
+	return 1
+}
+
+// closesBatch reports whether the pending ethereum block spans a whole batch at
+// the given tendermint height. A held block goes on past the end of its batch
+// and is committed at the first height that releases it, whatever the batch size.
+func (app *EthermintApplication) closesBatch(height uint64) bool {
+	return height-app.blockStart+1 >= app.batchSize()
+}
+
+// holdsBlock reports whether the pending ethereum block waits for more transactions
+// at the given tendermint height. It only depends on the delivered transactions
+// and the heights, so every validator holds the same blocks.
+func (app *EthermintApplication) holdsBlock(height uint64) bool {
+	config := app.backend.EthermintConfig()
+	count := uint64(app.backend.PendingTxCount())
+	if count == 0 || count >= config.MinBlockTransactions {
+		return false
+	}
+	return config.MaxBlockWait == 0 || height-app.blockStart+1 < config.MaxBlockWait
+}
+
 // validateTx checks the validity of a tx against the blockchain's current state.
 // it duplicates the logic in ethereum's tx_pool
 func (app *EthermintApplication) validateTx(tx *ethTypes.Transaction) abciTypes.Result {
-	currentState, err := app.currentState()
-	if err != nil {
-		return abciTypes.ErrInternalError.AppendLog(err.Error())
+	// Reject transactions replayed from other chains before recovering the sender
+	if err := app.backend.CheckReplayProtection(tx); err != nil {
+		return abciTypes.ErrBaseInvalidSignature.AppendLog(err.Error())
 	}
 
 	var signer ethTypes.Signer = ethTypes.FrontierSigner{}
@@ -182,18 +338,9 @@ func (app *EthermintApplication) validateTx(tx *ethTypes.Transaction) abciTypes.
 			AppendLog(core.ErrInvalidSender.Error())
 	}
 
-	// Make sure the account exist. Non existent accounts
-	// haven't got funds and well therefor never pass.
-	if !currentState.Exist(from) {
-		return abciTypes.ErrBaseUnknownAddress.
-			AppendLog(core.ErrInvalidSender.Error())
-	}
-
-	// Check for nonce errors
-	currentNonce := currentState.GetNonce(from)
-	if currentNonce > tx.Nonce() {
-		return abciTypes.ErrBadNonce.
-			AppendLog(fmt.Sprintf("Got: %d, Current: %d", tx.Nonce(), currentNonce))
+	// Check the sender, nonce, funds and intrinsic gas against the check state
+	if err := app.backend.CheckTx(from, tx); err != nil {
+		return checkTxResult(err)
 	}
 
 	// Check the transaction doesn't exceed the current block limit gas.
@@ -202,6 +349,39 @@ func (app *EthermintApplication) validateTx(tx *ethTypes.Transaction) abciTypes.
 		return abciTypes.ErrInternalError.AppendLog(core.ErrGasLimitReached.Error())
 	}
 
+	// Reject transactions paying less than the node accepts
+	if minGasPrice := app.backend.EthermintConfig().MinGasPrice; minGasPrice != nil && tx.GasPrice().Cmp(minGasPrice) < 0 {
+		return abciTypes.ErrBaseInsufficientFees.
+			AppendLog(fmt.Sprintf("Gas price %s is below the minimum %s", tx.GasPrice(), minGasPrice))
+	}
+
+	// Reject overpaying transactions if the node caps the tip
+	if maxGasPrice := app.backend.EthermintConfig().MaxGasPrice; maxGasPrice != nil && tx.GasPrice().Cmp(maxGasPrice) > 0 {
+		return abciTypes.ErrBaseInvalidInput.
+			AppendLog(fmt.Sprintf("Gas price %s exceeds the maximum %s", tx.GasPrice(), maxGasPrice))
+	}
+
+	// Reject transactions tipping below the floor set by the recent congestion
+	if floor := app.backend.GasPriceFloor(); floor != nil && tx.GasPrice().Cmp(floor) < 0 {
+		return abciTypes.ErrBaseInsufficientFees.
+			AppendLog(fmt.Sprintf("Gas price %s is below the floor %s", tx.GasPrice(), floor))
+	}
+
+	// Signed gas prices can't be rounded, so prices between the steps are rejected
+	if step := app.backend.EthermintConfig().GasPriceGranularity; step != nil && new(big.Int).Mod(tx.GasPrice(), step).Sign() != 0 {
+		return abciTypes.ErrBaseInvalidInput.
+			AppendLog(fmt.Sprintf("Gas price %s is not a multiple of %s", tx.GasPrice(), step))
+	}
+
+	// Protect senders from fees out of proportion to the value they transfer
+	if multiple := app.backend.EthermintConfig().MaxFeeValueMultiple; multiple > 0 && tx.Value().Sign() > 0 {
+		maxFee := new(big.Int).Mul(tx.Value(), new(big.Int).SetUint64(multiple))
+		if fee := new(big.Int).Mul(tx.Gas(), tx.GasPrice()); fee.Cmp(maxFee) > 0 {
+			return abciTypes.ErrBaseInvalidInput.
+				AppendLog(fmt.Sprintf("Maximum fee %s exceeds %d times the value %s", fee, multiple, tx.Value()))
+		}
+	}
+
 	// Transactions can't be negative. This may never happen
 	// using RLP decoded transactions but may occur if you create
 	// a transaction using the RPC for example.
@@ -210,20 +390,36 @@ func (app *EthermintApplication) validateTx(tx *ethTypes.Transaction) abciTypes.
 			SetLog(core.ErrNegativeValue.Error())
 	}
 
-	// Transactor should have enough funds to cover the costs
-	// cost == V + GP * GL
-	currentBalance := currentState.GetBalance(from)
-	if currentBalance.Cmp(tx.Cost()) < 0 {
-		return abciTypes.ErrInsufficientFunds.
-			AppendLog(fmt.Sprintf("Current balance: %s, tx cost: %s", currentBalance, tx.Cost()))
-
-	}
-
-	intrGas := core.IntrinsicGas(tx.Data(), tx.To() == nil, true) // homestead == true
-	if tx.Gas().Cmp(intrGas) < 0 {
-		return abciTypes.ErrBaseInsufficientFees.
-			SetLog(core.ErrIntrinsicGas.Error())
+	// Optionally execute the transaction to keep failing ones out of the mempool
+	if app.backend.EthermintConfig().SimulateCheckTx {
+		if err := app.backend.SimulateTx(tx); err == ethereum.ErrStateCopiesBusy {
+			return abciTypes.ErrInternalError.AppendLog(err.Error())
+		} else if err != nil {
+			return abciTypes.ErrBaseInvalidInput.
+				AppendLog(fmt.Sprintf("Transaction would fail: %v", err))
+		}
 	}
 
 	return abciTypes.OK
 }
+
+// checkTxResult maps the errors of Backend.CheckTx to abci results
+func checkTxResult(err error) abciTypes.Result {
+	cerr, ok := err.(*ethereum.CheckTxError)
+	if !ok {
+		return abciTypes.ErrInternalError.AppendLog(err.Error())
+	}
+	switch cerr.Err {
+	case core.ErrInvalidSender:
+		return abciTypes.ErrBaseUnknownAddress.AppendLog(cerr.Err.Error())
+	case core.ErrNonce:
+		return abciTypes.ErrBadNonce.AppendLog(cerr.Log)
+	case core.ErrInsufficientFunds:
+		return abciTypes.ErrInsufficientFunds.AppendLog(cerr.Log)
+	case core.ErrIntrinsicGas:
+		return abciTypes.ErrBaseInsufficientFees.SetLog(cerr.Err.Error())
+	case ethereum.ErrYoungAccount, ethereum.ErrStateSizeLimit:
+		return abciTypes.ErrBaseInvalidInput.AppendLog(cerr.Error())
+	}
+	return abciTypes.ErrInternalError.AppendLog(err.Error())
+}
@@ -4,29 +4,42 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 
 	"golang.org/x/net/context"
 
 	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rlp"
 
 	"github.com/tendermint/ethermint/app"
 	emtUtils "github.com/tendermint/ethermint/cmd/utils"
 	"github.com/tendermint/ethermint/ethereum"
+	emtTypes "github.com/tendermint/ethermint/types"
 
 	abciTypes "github.com/tendermint/abci/types"
 
@@ -35,6 +48,9 @@ import (
 
 var (
 	receiverAddress = common.StringToAddress("0x1234123412341234123412341234123412341234")
+
+	// ethash block reward credited to the coinbase in accumulateRewards
+	blockReward = big.NewInt(5e+18)
 )
 
 // implements: tendermint.rpc.client.HTTPClient
@@ -44,6 +60,24 @@ type MockClient struct {
 
 func NewMockClient() *MockClient { return &MockClient{make(chan struct{})} }
 
+// testStrategy pays all rewards to a fixed receiver, never changes the validators
+// and applies the given slashes in every block
+type testStrategy struct {
+	receiver common.Address
+	slashes  []emtTypes.Slash
+}
+
+func newTestStrategy(receiver common.Address, slashes ...emtTypes.Slash) *emtTypes.Strategy {
+	s := &testStrategy{receiver, slashes}
+	return &emtTypes.Strategy{MinerRewardStrategy: s, ValidatorsStrategy: s, SlashingStrategy: s}
+}
+
+func (s *testStrategy) Receiver() common.Address                        { return s.receiver }
+func (s *testStrategy) SetValidators(validators []*abciTypes.Validator) {}
+func (s *testStrategy) CollectTx(tx *types.Transaction)                 {}
+func (s *testStrategy) GetUpdatedValidators() []*abciTypes.Validator    { return nil }
+func (s *testStrategy) Slashes() []emtTypes.Slash                       { return s.slashes }
+
 func (mc *MockClient) Call(method string, params map[string]interface{}, result interface{}) (interface{}, error) {
 	switch method {
 	case "status":
@@ -51,7 +85,11 @@ func (mc *MockClient) Call(method string, params map[string]interface{}, result
 
 		return result, nil
 	case "broadcast_tx_sync":
-		close(mc.sentBroadcastTx)
+		select {
+		case <-mc.sentBroadcastTx: // already fired by an earlier tx
+		default:
+			close(mc.sentBroadcastTx)
+		}
 		result = &ctypes.ResultBroadcastTx{}
 
 		return result, nil
@@ -101,7 +139,7 @@ func TestBumpingNonces(t *testing.T) {
 	app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: 1})
 
 	// check deliverTx
-	assert.Equal(t, abciTypes.OK, app.DeliverTx(encodedtx))
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedtx).Code)
 
 	app.EndBlock(height)
 
@@ -184,12 +222,12 @@ func TestMultipleTxOneAcc(t *testing.T) {
 	app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: 1})
 
 	// check deliverTx for 1st tx
-	assert.Equal(t, abciTypes.OK, app.DeliverTx(encodedTx1))
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx1).Code)
 
 	// and for 2nd tx (should fail because of wrong nonce2)
 	deliverTx2Result := app.DeliverTx(encodedTx2)
 
-	assert.Equal(t, abciTypes.ErrInternalError.Code, deliverTx2Result.Code)
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, deliverTx2Result.Code)
 
 	app.EndBlock(height)
 
@@ -256,9 +294,9 @@ func TestMultipleTxTwoAcc(t *testing.T) {
 	app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: 1})
 
 	// check deliverTx for 1st tx
-	assert.Equal(t, abciTypes.OK, app.DeliverTx(encodedtx1))
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedtx1).Code)
 	// and for 2nd tx
-	assert.Equal(t, abciTypes.OK, app.DeliverTx(encodedTx2))
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx2).Code)
 
 	app.EndBlock(height)
 
@@ -268,84 +306,5042 @@ func TestMultipleTxTwoAcc(t *testing.T) {
 	node.Stop()
 }
 
-// mimics abciEthereumAction from cmd/ethermint/main.go
-func makeTestApp(tempDatadir string, addresses []common.Address, mockclient *MockClient) (*node.Node, *ethereum.Backend, *app.EthermintApplication, error) {
-	stack, err := makeTestSystemNode(tempDatadir, addresses, mockclient)
+func TestTreasuryFeeSkim(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
 	if err != nil {
-		return nil, nil, nil, err
+		t.Errorf("Error generating key %v", err)
 	}
-	ethUtils.StartNode(stack)
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
 
-	var backend *ethereum.Backend
-	if err = stack.Service(&backend); err != nil {
-		return nil, nil, nil, err
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
 	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
 
-	app, err := app.NewEthermintApplication(backend, nil, nil)
+	mockclient := NewMockClient()
 
-	return stack, backend, app, err
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	treasury := common.StringToAddress("0x5555555555555555555555555555555555555555")
+	emtConfig := &ethereum.Config{TreasuryAddress: treasury, TreasuryFeePercent: 15}
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr1, addr2}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey1, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx1, err := rlp.EncodeToBytes(tx1)
+
+	tx2, err := createTransaction(privateKey2, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx2, err := rlp.EncodeToBytes(tx2)
+
+	height := uint64(1)
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx1).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx2).Code)
+	app.EndBlock(height)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// two transfers paying 21000 gas at a gas price of 10
+	fees := big.NewInt(2 * 21000 * 10)
+	skim := new(big.Int).Div(new(big.Int).Mul(fees, big.NewInt(15)), big.NewInt(100))
+	proposerShare := new(big.Int).Sub(fees, skim)
+
+	state, err := backend.Ethereum().BlockChain().State()
+	if err != nil {
+		t.Errorf("Error getting state: %v", err)
+	}
+	coinbase := backend.Ethereum().BlockChain().CurrentBlock().Coinbase()
+	assert.Equal(t, skim, state.GetBalance(treasury))
+	assert.Equal(t, new(big.Int).Add(blockReward, proposerShare), state.GetBalance(coinbase))
+
+	node.Stop()
 }
 
-func makeTestGenesis(addresses []common.Address) (*core.Genesis, error) {
-	gopath := os.Getenv("GOPATH")
-	genesisPath := filepath.Join(gopath, "src/github.com/tendermint/ethermint/dev/genesis.json")
+func TestHasAddressActivity(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
 
-	file, err := os.Open(genesisPath)
-	defer file.Close()
+	privateKey2, err := crypto.GenerateKey()
 	if err != nil {
-		return nil, err
+		t.Errorf("Error generating key %v", err)
 	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
 
-	genesis := new(core.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
-		ethUtils.Fatalf("invalid genesis file: %v", err)
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
 	}
+	defer os.RemoveAll(tempDatadir)
 
-	balance, result := new(big.Int).SetString("10000000000000000000000000000000000", 10)
-	if !result {
-		return nil, errors.New("BigInt convertation error")
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr1, addr2}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
 	}
 
-	for _, addr := range addresses {
-		genesis.Alloc[addr] = core.GenesisAccount{Balance: balance}
+	tx1, err := createTransaction(privateKey1, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
 	}
+	deliverBlock(t, app, 1, tx1)
 
-	return genesis, nil
+	tx2, err := createTransaction(privateKey2, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, tx2)
+
+	// addr1 sent in block 1 and receiverAddress received in both blocks
+	active, err := backend.HasAddressActivity(addr1, 1)
+	assert.Nil(t, err)
+	assert.True(t, active)
+	active, err = backend.HasAddressActivity(receiverAddress, 1)
+	assert.Nil(t, err)
+	assert.True(t, active)
+
+	// addr1 did nothing in block 2
+	active, err = backend.HasAddressActivity(addr1, 2)
+	assert.Nil(t, err)
+	assert.False(t, active)
+
+	_, err = backend.HasAddressActivity(addr1, 3)
+	assert.NotNil(t, err)
+
+	node.Stop()
 }
 
-// mimics MakeSystemNode from ethereum/node.go
-func makeTestSystemNode(tempDatadir string, addresses []common.Address, mockclient *MockClient) (*node.Node, error) {
-	// Configure the node's service container
-	nodeConf := emtUtils.DefaultNodeConfig()
-	emtUtils.SetEthermintNodeConfig(&nodeConf)
-	nodeConf.DataDir = tempDatadir
+func TestBlockAddresses(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
 
-	// Configure the Ethereum service
-	ethConf := eth.DefaultConfig
-	emtUtils.SetEthermintEthConfig(&ethConf)
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
 
-	genesis, err := makeTestGenesis(addresses)
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
 	if err != nil {
-		return nil, err
+		t.Error("unable to create temporary datadir")
 	}
+	defer os.RemoveAll(tempDatadir)
 
-	ethConf.Genesis = genesis
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr1, addr2}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
 
-	// Assemble and return the protocol stack
-	stack, err := node.New(&nodeConf)
+	tx1, err := createTransaction(privateKey1, 0)
 	if err != nil {
-		return nil, err
+		t.Errorf("Error creating transaction: %v", err)
 	}
-	return stack, stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return ethereum.NewBackend(ctx, &ethConf, mockclient)
-	})
+	tx2, err := createContractTransaction(privateKey2, 0, logEmittingContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// fails, so its contract address is left out
+	tx3, err := createContractTransaction(privateKey1, 1, oversizedDepositContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1, tx2, tx3)
+
+	contractAddr := crypto.CreateAddress(addr2, 0)
+	addresses, err := backend.BlockAddresses(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{addr1, receiverAddress, addr2, contractAddr}, addresses)
+
+	_, err = backend.BlockAddresses(2)
+	assert.NotNil(t, err)
+
+	node.Stop()
 }
 
-func createTransaction(key *ecdsa.PrivateKey, nonce uint64) (*types.Transaction, error) {
+func TestCoinbaseMaturity(t *testing.T) {
+	// the coinbase has no genesis funds, only block rewards
+	coinbaseKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	coinbase := crypto.PubkeyToAddress(coinbaseKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{CoinbaseMaturity: 3}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{}, mockclient,
+		emtConfig, newTestStrategy(coinbase))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(coinbaseKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+
+	// mints the first reward
+	deliverBlock(t, app, 1)
+
+	// the rewards of blocks 1 and 2 are still immature
+	for height := uint64(2); height <= 3; height++ {
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: height})
+		assert.NotEqual(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+		app.EndBlock(height)
+		assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	}
+
+	// the reward of block 1 has matured
+	deliverBlock(t, app, 4, tx)
+
+	node.Stop()
+}
+
+func TestStorageChanges(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+
+	contractAddr := crypto.CreateAddress(addr, 0)
+	callTx, err := createCallTransaction(privateKey, 1, contractAddr, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx)
+
+	changes, err := backend.StorageChanges(callTx.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, []ethereum.StorageChange{
+		{Address: contractAddr, Slot: common.BigToHash(big.NewInt(0)), NewValue: common.BigToHash(big.NewInt(1))},
+		{Address: contractAddr, Slot: common.BigToHash(big.NewInt(1)), NewValue: common.BigToHash(big.NewInt(2))},
+	}, changes)
+
+	_, err = backend.StorageChanges(common.Hash{})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestStorageReads(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, sumContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+
+	contractAddr := crypto.CreateAddress(addr, 0)
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		callTx, err := createCallTransaction(privateKey, nonce, contractAddr, nil)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, nonce+1, callTx)
+
+		// the second call reads the sum the first one wrote
+		reads, err := backend.StorageReads(callTx.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, []ethereum.StorageRead{
+			{Address: contractAddr, Slot: common.BigToHash(big.NewInt(0)), Value: common.BigToHash(big.NewInt(int64(nonce - 1)))},
+			{Address: contractAddr, Slot: common.BigToHash(big.NewInt(1))},
+			{Address: contractAddr, Slot: common.BigToHash(big.NewInt(2))},
+		}, reads)
+
+		// of the read slots only the first is written
+		changes, err := backend.StorageChanges(callTx.Hash())
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(changes)) {
+			assert.Equal(t, common.BigToHash(big.NewInt(0)), changes[0].Slot)
+		}
+	}
+
+	// a transfer reads no storage
+	transferTx, err := createTransaction(privateKey, 3)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 4, transferTx)
+	reads, err := backend.StorageReads(transferTx.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, []ethereum.StorageRead{}, reads)
+
+	_, err = backend.StorageReads(common.Hash{})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestStateRoot(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// without minting, an empty block leaves the state untouched
+	emtConfig := &ethereum.Config{FeeOnlyRewards: true}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := createTransaction(privateKey, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, nonce+1, tx)
+	}
+	deliverBlock(t, app, 3)
+
+	blockchain := backend.Ethereum().BlockChain()
+	roots := make([]common.Hash, 4)
+	for number := range roots {
+		header := blockchain.GetHeaderByNumber(uint64(number))
+		roots[number], err = backend.StateRoot(uint64(number))
+		assert.Nil(t, err)
+		assert.Equal(t, header.Root, roots[number])
+
+		root, err := backend.StateRootByHash(header.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, header.Root, root)
+	}
+	assert.NotEqual(t, roots[0], roots[1])
+	assert.NotEqual(t, roots[1], roots[2])
+	assert.Equal(t, roots[2], roots[3])
+
+	_, err = backend.StateRoot(4)
+	assert.NotNil(t, err)
+	_, err = backend.StateRootByHash(common.Hash{})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestSenderGasBudget(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
+
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// about 1.3M gas per sender with the dev genesis gas limit
+	emtConfig := &ethereum.Config{SenderGasPercent: 1}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr1, addr2}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey1, 0, gasBurnerContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+	burner := crypto.CreateAddress(addr1, 0)
+
+	// each call burns its full gas limit of 1M
+	call1, err := createCallTransaction(privateKey1, 1, burner, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	call2, err := createCallTransaction(privateKey1, 2, burner, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	call3, err := createCallTransaction(privateKey2, 0, burner, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedCall1, err := rlp.EncodeToBytes(call1)
+	encodedCall2, err := rlp.EncodeToBytes(call2)
+	encodedCall3, err := rlp.EncodeToBytes(call3)
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedCall1).Code)
+	// addr1 has spent its budget, the second call is deferred
+	assert.NotEqual(t, abciTypes.OK.Code, app.DeliverTx(encodedCall2).Code)
+	// addr2 has its own budget
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedCall3).Code)
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// the budget is reset with the next block
+	deliverBlock(t, app, 3, call2)
+
+	node.Stop()
+}
+
+func TestRawBlockAndTransaction(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+	committed := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+
+	rawBlock, err := backend.RawBlockByNumber(1)
+	assert.Nil(t, err)
+	block := new(types.Block)
+	assert.Nil(t, rlp.DecodeBytes(rawBlock, block))
+	assert.Equal(t, committed.Hash(), block.Hash())
+	assert.Equal(t, tx.Hash(), block.Transactions()[0].Hash())
+
+	rawBlockByHash, err := backend.RawBlockByHash(committed.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, rawBlock, rawBlockByHash)
+
+	rawTx, err := backend.RawTransaction(tx.Hash())
+	assert.Nil(t, err)
+	decodedTx := new(types.Transaction)
+	assert.Nil(t, rlp.DecodeBytes(rawTx, decodedTx))
+	assert.Equal(t, tx.Hash(), decodedTx.Hash())
+
+	_, err = backend.RawBlockByNumber(2)
+	assert.NotNil(t, err)
+	_, err = backend.RawTransaction(common.Hash{})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestSlashing(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	coinbase := common.StringToAddress("0x7777777777777777777777777777777777777777")
+	strategy := newTestStrategy(coinbase,
+		emtTypes.Slash{Address: addr, Amount: big.NewInt(1000)},
+		// exceeds the 10 wei received in the block
+		emtTypes.Slash{Address: receiverAddress, Amount: big.NewInt(1e18)},
+		// invalid amounts are skipped
+		emtTypes.Slash{Address: addr},
+		emtTypes.Slash{Address: addr, Amount: big.NewInt(-1000)},
+	)
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, strategy)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	before, err := backend.Ethereum().BlockChain().State()
+	if err != nil {
+		t.Errorf("Error getting state: %v", err)
+	}
+	balance := new(big.Int).Set(before.GetBalance(addr))
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+
+	after, err := backend.Ethereum().BlockChain().State()
+	if err != nil {
+		t.Errorf("Error getting state: %v", err)
+	}
+	// the sender paid the value, the fee and the penalty
+	expected := new(big.Int).Sub(balance, tx.Value())
+	expected.Sub(expected, big.NewInt(21000*10))
+	expected.Sub(expected, big.NewInt(1000))
+	assert.Equal(t, expected, after.GetBalance(addr))
+	assert.Equal(t, 0, after.GetBalance(receiverAddress).Sign())
+
+	// the penalties are burned, the receiver only had its 10 wei
+	stats, err := backend.BlockStats(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, big.NewInt(1010).Cmp(stats.BurnedFees))
+
+	node.Stop()
+}
+
+func TestBlockSize(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx, transferTx)
+	deliverBlock(t, app, 2)
+
+	encodedSize := func(v interface{}) uint64 {
+		encoded, err := rlp.EncodeToBytes(v)
+		if err != nil {
+			t.Errorf("Error encoding %v", err)
+		}
+		return uint64(len(encoded))
+	}
+
+	blockchain := backend.Ethereum().BlockChain()
+	size, err := backend.BlockSize(1)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.BlockSize{
+		Size:          encodedSize(blockchain.GetBlockByNumber(1)),
+		TxCount:       2,
+		AverageTxSize: (encodedSize(deployTx) + encodedSize(transferTx)) / 2,
+	}, size)
+
+	size, err = backend.BlockSize(2)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.BlockSize{Size: encodedSize(blockchain.GetBlockByNumber(2))}, size)
+
+	_, err = backend.BlockSize(3)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestBlockStats(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
+
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{TreasuryAddress: common.StringToAddress("0x5555555555555555555555555555555555555555"), TreasuryFeePercent: 10}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr1, addr2}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey1, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createTransaction(privateKey2, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1, tx2)
+
+	// sum up the receipts by hand
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), 1)
+	gasUsed, fees := new(big.Int), new(big.Int)
+	for i, receipt := range receipts {
+		gasUsed.Add(gasUsed, receipt.GasUsed)
+		fees.Add(fees, new(big.Int).Mul(receipt.GasUsed, block.Transactions()[i].GasPrice()))
+	}
+
+	stats, err := backend.BlockStats(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stats.TxCount)
+	assert.Equal(t, 2, stats.Senders)
+	assert.Equal(t, 0, gasUsed.Cmp(stats.GasUsed))
+	assert.Equal(t, 0, block.GasUsed().Cmp(stats.GasUsed))
+	assert.Equal(t, 0, fees.Cmp(stats.Fees))
+	assert.Equal(t, 0, new(big.Int).Div(fees, big.NewInt(10)).Cmp(stats.TreasuryFees))
+	assert.Equal(t, 0, stats.BurnedFees.Sign())
+
+	_, err = backend.BlockStats(2)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestBlockSenders(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	var addresses []common.Address
+	for i := 0; i < 3; i++ {
+		privateKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Errorf("Error generating key %v", err)
+		}
+		keys = append(keys, privateKey)
+		addresses = append(addresses, crypto.PubkeyToAddress(privateKey.PublicKey))
+	}
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, addresses, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the first sender sends twice
+	var txs []*types.Transaction
+	for i, nonce := range []uint64{0, 1, 0, 0} {
+		key := keys[0]
+		if i > 1 {
+			key = keys[i-1]
+		}
+		tx, err := createTransaction(key, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	deliverBlock(t, app, 1, txs...)
+
+	stats, err := backend.BlockStats(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, stats.TxCount)
+	assert.Equal(t, 3, stats.Senders)
+
+	node.Stop()
+}
+
+func TestBlockCreations(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	var txs []*types.Transaction
+	// the init code of the last deployment hits an invalid opcode
+	for nonce, code := range [][]byte{storageContractCode, counterContractCode, common.FromHex("0xfe")} {
+		tx, err := createContractTransaction(privateKey, uint64(nonce), code)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	transferTx, err := createTransaction(privateKey, 3)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, append(txs, transferTx)...)
+	deliverBlock(t, app, 2)
+
+	stats, err := backend.BlockStats(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, stats.TxCount)
+	assert.Equal(t, 2, stats.Creations)
+
+	stats, err = backend.BlockStats(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stats.Creations)
+
+	node.Stop()
+}
+
+func TestTopFeeTransaction(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the first of the two highest prices wins
+	var txs []*types.Transaction
+	for nonce, price := range []int64{10, 30, 20, 30} {
+		tx, err := createTransactionWithGasPrice(privateKey, uint64(nonce), big.NewInt(price))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	deliverBlock(t, app, 1, txs...)
+	deliverBlock(t, app, 2)
+
+	top, err := backend.TopFeeTransaction(1)
+	assert.Nil(t, err)
+	assert.Equal(t, txs[1].Hash(), top.TxHash)
+	assert.Equal(t, 0, top.GasPrice.Cmp(big.NewInt(30)))
+	assert.Equal(t, 0, top.GasUsed.Cmp(big.NewInt(21000)))
+	assert.Equal(t, 0, top.Fee.Cmp(big.NewInt(21000*30)))
+
+	top, err = backend.TopFeeTransaction(2)
+	assert.Nil(t, err)
+	assert.Nil(t, top)
+
+	_, err = backend.TopFeeTransaction(3)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestCommittedLogs(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	client, err := node.Attach()
+	if err != nil {
+		t.Errorf("Error attaching rpc client: %v", err)
+	}
+
+	// a transfer without logs goes first, so the transaction and log indexes differ
+	transferTx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	txs := []*types.Transaction{transferTx}
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		tx, err := createContractTransaction(privateKey, nonce, logEmittingContractCode)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	deliverBlock(t, app, 1, txs...)
+	block := backend.Ethereum().BlockChain().CurrentBlock()
+
+	for i, receipt := range backend.Receipts([]common.Hash{txs[1].Hash(), txs[2].Hash()}) {
+		assert.Equal(t, 1, len(receipt.Logs))
+		log := receipt.Logs[0]
+		assert.Equal(t, block.Hash(), log.BlockHash)
+		assert.Equal(t, uint64(1), log.BlockNumber)
+		assert.Equal(t, txs[i+1].Hash(), log.TxHash)
+		assert.Equal(t, uint(i+1), log.TxIndex)
+		assert.Equal(t, uint(i), log.Index)
+	}
+
+	var logs []*types.Log
+	filter := map[string]interface{}{"fromBlock": "0x1", "toBlock": "0x1"}
+	assert.Nil(t, client.Call(&logs, "eth_getLogs", filter))
+	assert.Equal(t, 2, len(logs))
+	for i, log := range logs {
+		assert.Equal(t, block.Hash(), log.BlockHash)
+		assert.Equal(t, uint64(1), log.BlockNumber)
+		assert.Equal(t, txs[i+1].Hash(), log.TxHash)
+		assert.Equal(t, uint(i+1), log.TxIndex)
+		assert.Equal(t, uint(i), log.Index)
+	}
+
+	node.Stop()
+}
+
+func TestLogsBloom(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createContractTransaction(privateKey, 0, logEmittingContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+
+	block := backend.Ethereum().BlockChain().CurrentBlock()
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), block.NumberU64())
+	assert.Equal(t, types.CreateBloom(receipts), block.Bloom())
+	assert.True(t, types.BloomLookup(block.Bloom(), crypto.CreateAddress(addr, 0)))
+	assert.False(t, types.BloomLookup(block.Bloom(), addr))
+
+	node.Stop()
+}
+
+func TestMinGasPrice(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{MinGasPrice: big.NewInt(10)}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	for _, c := range []struct {
+		gasPrice int64
+		code     abciTypes.CodeType
+	}{
+		{9, abciTypes.ErrBaseInsufficientFees.Code},
+		{10, abciTypes.OK.Code},
+		{11, abciTypes.OK.Code},
+	} {
+		tx, err := createTransactionWithGasPrice(privateKey, 0, big.NewInt(c.gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		assert.Equal(t, c.code, app.CheckTx(encodedTx).Code, "gas price %d", c.gasPrice)
+	}
+
+	node.Stop()
+}
+
+func TestMaxGasPrice(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{MaxGasPrice: big.NewInt(20)}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	for _, c := range []struct {
+		gasPrice int64
+		code     abciTypes.CodeType
+	}{
+		{10, abciTypes.OK.Code},
+		{20, abciTypes.OK.Code},
+		{21, abciTypes.ErrBaseInvalidInput.Code},
+	} {
+		tx, err := createTransactionWithGasPrice(privateKey, 0, big.NewInt(c.gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		assert.Equal(t, c.code, app.CheckTx(encodedTx).Code, "gas price %d", c.gasPrice)
+	}
+
+	node.Stop()
+}
+
+func TestMaxFeeValueMultiple(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// the test transactions transfer 10 wei with 21000 gas
+	emtConfig := &ethereum.Config{MaxFeeValueMultiple: 42000}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	for _, c := range []struct {
+		gasPrice int64
+		code     abciTypes.CodeType
+	}{
+		{10, abciTypes.OK.Code},
+		{20, abciTypes.OK.Code},
+		{21, abciTypes.ErrBaseInvalidInput.Code},
+	} {
+		tx, err := createTransactionWithGasPrice(privateKey, 0, big.NewInt(c.gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		assert.Equal(t, c.code, app.CheckTx(encodedTx).Code, "gas price %d", c.gasPrice)
+	}
+
+	// contract calls without value are exempt
+	callTx, err := createCallTransaction(privateKey, 0, receiverAddress, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(callTx)
+	assert.Equal(t, abciTypes.OK, app.CheckTx(encodedTx))
+
+	node.Stop()
+}
+
+func TestReplayProtection(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	encode := func(nonce uint64, signer types.Signer) []byte {
+		tx, err := types.SignTx(
+			types.NewTransaction(nonce, receiverAddress, big.NewInt(10), big.NewInt(21000), big.NewInt(10), nil),
+			signer,
+			privateKey,
+		)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		return encodedTx
+	}
+
+	for _, required := range []bool{false, true} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{RequireReplayProtection: required}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+		chainID := backend.Ethereum().ApiBackend.ChainConfig().ChainId
+
+		protectedTx := encode(0, types.NewEIP155Signer(chainID))
+		otherChainTx := encode(1, types.NewEIP155Signer(new(big.Int).Add(chainID, big.NewInt(1))))
+		legacyTx := encode(1, types.HomesteadSigner{})
+
+		assert.Equal(t, abciTypes.OK, app.CheckTx(protectedTx))
+		assert.Equal(t, abciTypes.ErrBaseInvalidSignature.Code, app.CheckTx(otherChainTx).Code)
+
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 3})
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(protectedTx).Code)
+		assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, app.DeliverTx(otherChainTx).Code)
+		if required {
+			assert.Equal(t, abciTypes.ErrBaseInvalidSignature.Code, app.CheckTx(legacyTx).Code)
+			assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, app.DeliverTx(legacyTx).Code)
+		} else {
+			assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(legacyTx).Code)
+		}
+		app.EndBlock(1)
+		assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestSimulateCheckTx(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{SimulateCheckTx: true}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, gasBurnerContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+	burner := crypto.CreateAddress(addr, 0)
+
+	// a plain transfer succeeds
+	transferTx, err := createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTransfer, err := rlp.EncodeToBytes(transferTx)
+	assert.Equal(t, abciTypes.OK, app.CheckTx(encodedTransfer))
+
+	// the burner hits an invalid opcode and would consume all its gas
+	callTx, err := createCallTransaction(privateKey, 1, burner, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedCall, err := rlp.EncodeToBytes(callTx)
+	res := app.CheckTx(encodedCall)
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, res.Code)
+	assert.Contains(t, res.Log, "Transaction would fail")
+
+	// simulation does not touch the pending state
+	deliverBlock(t, app, 2, transferTx)
+
+	node.Stop()
+}
+
+func TestGenesisAlloc(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, _, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	genesis, err := makeTestGenesis([]common.Address{addr})
+	if err != nil {
+		t.Errorf("Error making test genesis: %v", err)
+	}
+
+	alloc, err := backend.GenesisAlloc()
+	assert.Nil(t, err)
+	assert.Equal(t, len(genesis.Alloc), len(alloc))
+	for address, account := range genesis.Alloc {
+		assert.Equal(t, 0, account.Balance.Cmp(alloc[address].Balance), "balance of %x", address)
+		assert.Equal(t, account.Nonce, alloc[address].Nonce)
+		assert.Equal(t, len(account.Code), len(alloc[address].Code))
+	}
+
+	devAccount := common.HexToAddress("0x7eff122b94897ea5b0e2a9abf47b86337fafebdc")
+	_, ok := alloc[devAccount]
+	assert.True(t, ok)
+
+	node.Stop()
+}
+
+func TestGasHistogram(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// 21000 gas
+	transferTx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// 21000 + 100 * 68 gas for the payload
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = 1
+	}
+	payloadTx, err := createCallTransaction(privateKey, 1, receiverAddress, data)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// more than 53000 gas for any creation
+	deployTx, err := createContractTransaction(privateKey, 2, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, transferTx, payloadTx, deployTx)
+
+	histogram, err := backend.GasHistogram(1, []uint64{21000, 50000})
+	assert.Nil(t, err)
+	assert.Equal(t, []ethereum.GasHistogramBucket{
+		{UpperBound: 21000, Count: 1},
+		{UpperBound: 50000, Count: 1},
+		{UpperBound: math.MaxUint64, Count: 1},
+	}, histogram)
+
+	histogram, err = backend.GasHistogram(1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(histogram))
+
+	_, err = backend.GasHistogram(1, []uint64{50000, 21000})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+// testUpgradeStrategy schedules its current upgrades in every block
+type testUpgradeStrategy struct {
+	upgrades []emtTypes.ForkUpgrade
+}
+
+func (s *testUpgradeStrategy) ChainUpgrades() []emtTypes.ForkUpgrade { return s.upgrades }
+
+// testRewardStrategy credits a fixed amount to its beneficiary in every block
+type testRewardStrategy struct {
+	beneficiary common.Address
+	amount      *big.Int
+}
+
+func (s *testRewardStrategy) AccumulateRewards(state *state.StateDB, header *types.Header) {
+	state.AddBalance(s.beneficiary, s.amount)
+}
+
+func TestRewardStrategy(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	coinbase := common.HexToAddress("0x9999999999999999999999999999999999999999")
+	beneficiary := common.HexToAddress("0x8888888888888888888888888888888888888888")
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	strategy := newTestStrategy(coinbase)
+	strategy.RewardStrategy = &testRewardStrategy{beneficiary, big.NewInt(1e+18)}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, strategy)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+	deliverBlock(t, app, 2)
+
+	// the strategy replaces the ethash reward, the coinbase keeps the fees
+	state, err := backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, state.GetBalance(beneficiary).Cmp(big.NewInt(2e+18)))
+	assert.Equal(t, 0, state.GetBalance(coinbase).Cmp(big.NewInt(21000*10)))
+
+	node.Stop()
+}
+
+func TestForkUpgrade(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	upgrades := &testUpgradeStrategy{}
+	strategy := newTestStrategy(common.StringToAddress("0x7777777777777777777777777777777777777777"))
+	strategy.ChainUpgradeStrategy = upgrades
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, strategy)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, balanceContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	upgrades.upgrades = []emtTypes.ForkUpgrade{
+		{Fork: "eip150", Block: big.NewInt(1)},    // the current block is in the past
+		{Fork: "homestead", Block: big.NewInt(5)}, // active since genesis
+		{Fork: "eip150", Block: big.NewInt(3)},
+	}
+	deliverBlock(t, app, 1, deployTx)
+	upgrades.upgrades = nil
+	contract := crypto.CreateAddress(addr, 0)
+
+	// the config of go-ethereum is not changed, the blocks from the activation
+	// height on are executed with the fork
+	assert.Nil(t, backend.Ethereum().BlockChain().Config().EIP150Block)
+	assert.Equal(t, 0, big.NewInt(3).Cmp(backend.ChainConfig().EIP150Block))
+
+	// BALANCE costs 20 gas before the fork and 400 gas from its activation block
+	// on, without a restart
+	for i, cost := range []int64{20, 400, 400} {
+		number := uint64(i + 2)
+		callTx, err := createCallTransaction(privateKey, uint64(i+1), contract, nil)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, number, callTx)
+
+		stats, err := backend.BlockStats(number)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, big.NewInt(21000+2+cost+2).Cmp(stats.GasUsed), "block %d", number)
+	}
+
+	// restarting keeps the scheduled fork
+	node.Stop()
+	node, backend, app, err = makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, strategy)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	blockchain := backend.Ethereum().BlockChain()
+	assert.Equal(t, uint64(4), blockchain.CurrentBlock().NumberU64())
+	assert.Equal(t, 0, big.NewInt(3).Cmp(blockchain.Config().EIP150Block))
+	assert.Equal(t, 0, big.NewInt(3).Cmp(backend.ChainConfig().EIP150Block))
+
+	callTx, err := createCallTransaction(privateKey, 4, contract, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 5, callTx)
+
+	stats, err := backend.BlockStats(5)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, big.NewInt(21000+2+400+2).Cmp(stats.GasUsed))
+
+	node.Stop()
+}
+
+// TestForkUpgradeRace schedules forks while go-ethereum and the backend read the
+// chain config, which fails under go test -race if a config in use is written
+func TestForkUpgradeRace(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	upgrades := &testUpgradeStrategy{}
+	strategy := newTestStrategy(common.StringToAddress("0x7777777777777777777777777777777777777777"))
+	strategy.ChainUpgradeStrategy = upgrades
+	_, backend, app, cleanup := newTestApp(t, []common.Address{addr}, &ethereum.Config{}, strategy)
+	defer cleanup()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				config := backend.Ethereum().ApiBackend.ChainConfig()
+				config.IsEIP150(big.NewInt(200))
+				backend.ChainConfig().IsEIP150(big.NewInt(200))
+			}
+		}
+	}()
+
+	for height := uint64(1); height <= 5; height++ {
+		upgrades.upgrades = []emtTypes.ForkUpgrade{{Fork: "eip150", Block: big.NewInt(int64(100 + height))}}
+		deliverBlock(t, app, height)
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Nil(t, backend.Ethereum().BlockChain().Config().EIP150Block)
+	assert.Equal(t, 0, big.NewInt(105).Cmp(backend.ChainConfig().EIP150Block))
+}
+
+func TestStateGrowth(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	coinbase := common.StringToAddress("0x7777777777777777777777777777777777777777")
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, newTestStrategy(coinbase))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// adds the recipient, the contract and the coinbase
+	transferTx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deployTx, err := createContractTransaction(privateKey, 1, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, transferTx, deployTx)
+
+	growth, err := backend.StateGrowth(1)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.StateGrowth{AccountsAdded: 3, CodeBytes: 11}, growth)
+
+	// only touches existing accounts
+	transferTx, err = createTransaction(privateKey, 2)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, transferTx)
+
+	growth, err = backend.StateGrowth(2)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.StateGrowth{}, growth)
+
+	// a chain that did not track the size counts its state on restart
+	size := backend.StateSize()
+	assert.Equal(t, uint64(11), size.CodeBytes)
+	assert.Nil(t, backend.Ethereum().ChainDb().Delete([]byte("emt-state-size")))
+	node.Stop()
+
+	node, backend, _, err = makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, newTestStrategy(coinbase))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	assert.Equal(t, size, backend.StateSize())
+
+	node.Stop()
+}
+
+// queryBlockNumberContract calls the contract deployed by blockNumberContractCode
+func queryBlockNumberContract(app *app.EthermintApplication, contract common.Address) abciTypes.ResponseQuery {
+	data, _ := json.Marshal(map[string]interface{}{
+		"method": "eth_call",
+		"params": []interface{}{map[string]interface{}{"to": contract}, "latest"},
+	})
+	return app.Query(abciTypes.RequestQuery{Data: data})
+}
+
+func TestCallCache(t *testing.T) {
+	// the results are the same with and without the cache
+	for _, size := range []uint64{0, 16} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			privateKey, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatalf("Error generating key %v", err)
+			}
+			addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+			// without block rewards an empty block keeps the state root of its parent
+			emtConfig := &ethereum.Config{CallCacheSize: size, FeeOnlyRewards: true}
+			_, backend, app, cleanup := newTestApp(t, []common.Address{addr}, emtConfig, nil)
+			defer cleanup()
+
+			deployTx, err := createContractTransaction(privateKey, 0, blockNumberContractCode)
+			if err != nil {
+				t.Fatalf("Error creating transaction: %v", err)
+			}
+			deliverBlock(t, app, 1, deployTx)
+			contract := crypto.CreateAddress(addr, 0)
+
+			expected := func(number byte) []byte {
+				result := make([]byte, 32)
+				result[31] = number
+				value, _ := json.Marshal(hexutil.Bytes(result))
+				return value
+			}
+
+			for i := 0; i < 2; i++ {
+				res := queryBlockNumberContract(app, contract)
+				assert.Equal(t, abciTypes.OK.Code, res.Code, res.Log)
+				assert.Equal(t, expected(1), res.Value)
+			}
+
+			// the cached result of block 1 must not be served for block 2
+			deliverBlock(t, app, 2)
+			blockchain := backend.Ethereum().BlockChain()
+			assert.Equal(t, blockchain.GetBlockByNumber(1).Root(), blockchain.CurrentBlock().Root())
+			res := queryBlockNumberContract(app, contract)
+			assert.Equal(t, abciTypes.OK.Code, res.Code, res.Log)
+			assert.Equal(t, expected(2), res.Value)
+		})
+	}
+}
+
+func BenchmarkCallCache(b *testing.B) {
+	for _, size := range []uint64{0, 16} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			privateKey, err := crypto.GenerateKey()
+			if err != nil {
+				b.Fatalf("Error generating key %v", err)
+			}
+			addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+			emtConfig := &ethereum.Config{CallCacheSize: size}
+			_, _, app, cleanup := newTestApp(b, []common.Address{addr}, emtConfig, nil)
+			defer cleanup()
+
+			deployTx, err := createContractTransaction(privateKey, 0, blockNumberContractCode)
+			if err != nil {
+				b.Fatalf("Error creating transaction: %v", err)
+			}
+			deliverBlock(b, app, 1, deployTx)
+			contract := crypto.CreateAddress(addr, 0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				queryBlockNumberContract(app, contract)
+			}
+		})
+	}
+}
+
+func TestMaxStateCopies(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	emtConfig := &ethereum.Config{SimulateCheckTx: true, MaxStateCopies: 2, StateCopyWait: 100 * time.Millisecond}
+	_, backend, app, cleanup := newTestApp(t, []common.Address{addr}, emtConfig, nil)
+	defer cleanup()
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Fatalf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatalf("Error encoding transaction: %v", err)
+	}
+
+	// hold all slots
+	releases := make([]func(), 0, 2)
+	for i := 0; i < 2; i++ {
+		release, err := backend.AcquireStateCopy()
+		assert.Nil(t, err)
+		releases = append(releases, release)
+	}
+
+	start := time.Now()
+	_, err = backend.AcquireStateCopy()
+	assert.Equal(t, ethereum.ErrStateCopiesBusy, err)
+	assert.True(t, time.Since(start) >= emtConfig.StateCopyWait)
+
+	res := app.CheckTx(encodedTx)
+	assert.Equal(t, abciTypes.ErrInternalError.Code, res.Code)
+	assert.Contains(t, res.Log, ethereum.ErrStateCopiesBusy.Error())
+
+	// so are the queries on the pending block
+	query, err := json.Marshal(map[string]interface{}{
+		"method": "eth_getBalance",
+		"params": []interface{}{addr.Hex(), "pending"},
+	})
+	assert.Nil(t, err)
+	queryRes := app.Query(abciTypes.RequestQuery{Data: query})
+	assert.Equal(t, abciTypes.ErrInternalError.Code, queryRes.Code)
+	assert.Contains(t, queryRes.Log, ethereum.ErrStateCopiesBusy.Error())
+
+	// and eth_call on the pending block, which holds its slot in the rpc service
+	// for the node endpoints too
+	callQuery, err := json.Marshal(map[string]interface{}{
+		"method": "eth_call",
+		"params": []interface{}{map[string]interface{}{"from": addr.Hex(), "to": addr.Hex()}, "pending"},
+	})
+	assert.Nil(t, err)
+	queryRes = app.Query(abciTypes.RequestQuery{Data: callQuery})
+	assert.Equal(t, abciTypes.ErrInternalError.Code, queryRes.Code)
+	assert.Contains(t, queryRes.Log, ethereum.ErrStateCopiesBusy.Error())
+
+	// a waiting simulation proceeds once a slot is freed
+	done := make(chan abciTypes.Result)
+	go func() { done <- app.CheckTx(encodedTx) }()
+	time.Sleep(10 * time.Millisecond)
+	releases[0]()
+	assert.Equal(t, abciTypes.OK, <-done)
+
+	releases[1]()
+	assert.Equal(t, abciTypes.OK, app.CheckTx(encodedTx))
+	assert.Equal(t, abciTypes.OK.Code, app.Query(abciTypes.RequestQuery{Data: callQuery}).Code)
+}
+
+func TestMempoolPosition(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	addrs := make([]common.Address, 3)
+	for i := range keys {
+		privateKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Errorf("Error generating key %v", err)
+		}
+		keys[i] = privateKey
+		addrs[i] = crypto.PubkeyToAddress(privateKey.PublicKey)
+	}
+	ctx := context.Background()
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, _, err := makeTestApp(tempDatadir, addrs, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the first sender pays the lowest price for two transactions
+	var txs []*types.Transaction
+	for _, c := range []struct {
+		key      *ecdsa.PrivateKey
+		nonce    uint64
+		gasPrice int64
+	}{
+		{keys[0], 0, 10},
+		{keys[0], 1, 50},
+		{keys[1], 0, 20},
+		{keys[2], 0, 30},
+	} {
+		tx, err := createTransactionWithGasPrice(c.key, c.nonce, big.NewInt(c.gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		assert.Nil(t, backend.Ethereum().ApiBackend.SendTx(ctx, tx))
+		txs = append(txs, tx)
+	}
+
+	for i, txsAhead := range []int{2, 1, 2, 1} {
+		position, err := backend.MempoolPosition(txs[i].Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, txsAhead, position.TxsAhead, "transaction %d", i)
+		assert.Equal(t, 0, big.NewInt(int64(txsAhead)*21000).Cmp(position.GasAhead))
+		assert.Equal(t, uint64(1), position.Blocks)
+	}
+
+	other, err := createTransaction(keys[1], 5)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	_, err = backend.MempoolPosition(other.Hash())
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestGasPriceGranularity(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{GasPriceGranularity: big.NewInt(5)}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	for _, c := range []struct {
+		gasPrice int64
+		code     abciTypes.CodeType
+	}{
+		{0, abciTypes.OK.Code},
+		{5, abciTypes.OK.Code},
+		{10, abciTypes.OK.Code},
+		{11, abciTypes.ErrBaseInvalidInput.Code},
+		{14, abciTypes.ErrBaseInvalidInput.Code},
+	} {
+		tx, err := createTransactionWithGasPrice(privateKey, 0, big.NewInt(c.gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		assert.Equal(t, c.code, app.CheckTx(encodedTx).Code, "gas price %d", c.gasPrice)
+	}
+
+	node.Stop()
+}
+
+func TestExecutionErrors(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	codes := [][]byte{gasBurnerContractCode, storageContractCode, stackUnderflowContractCode, invalidJumpContractCode}
+	var deployTxs []*types.Transaction
+	for i, code := range codes {
+		tx, err := createContractTransaction(privateKey, uint64(i), code)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deployTxs = append(deployTxs, tx)
+	}
+	deliverBlock(t, app, 1, deployTxs...)
+
+	counts, err := backend.ExecutionErrors(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(counts))
+
+	nonce := uint64(len(codes))
+	var callTxs []*types.Transaction
+	for i := range codes {
+		contract := crypto.CreateAddress(addr, uint64(i))
+		// not enough gas for the first SSTORE of the storage contract
+		tx, err := types.SignTx(
+			types.NewTransaction(nonce, contract, big.NewInt(0), big.NewInt(25000), big.NewInt(10), nil),
+			types.HomesteadSigner{},
+			privateKey,
+		)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		callTxs = append(callTxs, tx)
+		nonce++
+	}
+	transferTx, err := createTransaction(privateKey, nonce)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, append(callTxs, transferTx)...)
+
+	counts, err = backend.ExecutionErrors(2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]uint64{
+		ethereum.ExecErrorInvalidOpcode: 1,
+		ethereum.ExecErrorOutOfGas:      1,
+		ethereum.ExecErrorStack:         1,
+		ethereum.ExecErrorInvalidJump:   1,
+	}, counts)
+
+	node.Stop()
+}
+
+func TestStateAccountLimit(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{StateAccountLimit: 4}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the dev account and addr
+	assert.Equal(t, uint64(2), backend.StateSize().Accounts)
+
+	// adds the recipient and the coinbase
+	transferTx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, transferTx)
+	assert.Equal(t, uint64(4), backend.StateSize().Accounts)
+
+	deployTx, err := createContractTransaction(privateKey, 1, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedDeploy, err := rlp.EncodeToBytes(deployTx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+	transferTx, err = createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTransfer, err := rlp.EncodeToBytes(transferTx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+
+	// the creation is kept out of the mempool while the transfer proceeds
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, app.CheckTx(encodedDeploy).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.CheckTx(encodedTransfer).Code)
+
+	// blocks still execute the creation
+	deliverBlock(t, app, 2, deployTx)
+	assert.Equal(t, uint64(5), backend.StateSize().Accounts)
+
+	node.Stop()
+}
+
+func TestRewardDistribution(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// the fees are split between the coinbase and the treasury
+	coinbase := common.StringToAddress("0x7777777777777777777777777777777777777777")
+	treasury := common.StringToAddress("0x5555555555555555555555555555555555555555")
+	emtConfig := &ethereum.Config{TreasuryAddress: treasury, TreasuryFeePercent: 10}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		emtConfig, newTestStrategy(coinbase))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+
+	distribution, err := backend.RewardDistribution(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, blockReward.Cmp(distribution.BlockReward))
+	assert.Equal(t, 0, big.NewInt(21000*10).Cmp(distribution.Fees))
+	assert.Equal(t, 0, distribution.Burned.Sign())
+	assert.Equal(t, 3, len(distribution.Events))
+
+	assert.Equal(t, 2, len(distribution.Recipients))
+	assert.Equal(t, coinbase, distribution.Recipients[0].Address)
+	assert.Equal(t, 0, new(big.Int).Add(blockReward, big.NewInt(21000*9)).Cmp(distribution.Recipients[0].Amount))
+	assert.Equal(t, treasury, distribution.Recipients[1].Address)
+	assert.Equal(t, 0, big.NewInt(21000).Cmp(distribution.Recipients[1].Amount))
+
+	_, err = backend.RewardDistribution(2)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestContractCreation(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+	contractAddr := crypto.CreateAddress(addr, 0)
+
+	creation, err := backend.ContractCreation(contractAddr)
+	assert.Nil(t, err)
+	assert.Equal(t, deployTx.Hash(), creation.TxHash)
+	assert.Equal(t, uint64(1), creation.BlockNumber)
+	assert.Equal(t, backend.Ethereum().BlockChain().GetBlockByNumber(1).Hash(), creation.BlockHash)
+
+	// externally owned accounts were not created by a transaction
+	_, err = backend.ContractCreation(addr)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestDeliverTxGasUsed(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// the address of a later creation follows the nonce of its transaction
+	laterDeployTx, err := createContractTransaction(privateKey, 2, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 3})
+	var results []map[string]interface{}
+	for _, tx := range []*types.Transaction{deployTx, transferTx, laterDeployTx} {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		res := app.DeliverTx(encodedTx)
+		assert.Equal(t, abciTypes.OK.Code, res.Code, res.Log)
+
+		var result map[string]interface{}
+		assert.Nil(t, json.Unmarshal(res.Data, &result))
+		results = append(results, result)
+	}
+	app.EndBlock(1)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// the results report the gas used of the receipts, and the address of a
+	// created contract
+	receipts := backend.Receipts([]common.Hash{deployTx.Hash(), transferTx.Hash(), laterDeployTx.Hash()})
+	for i, receipt := range receipts {
+		assert.Equal(t, hexutil.EncodeUint64(receipt.GasUsed.Uint64()), results[i]["gasUsed"])
+	}
+	assert.Equal(t, "0x5208", results[1]["gasUsed"])
+	assert.Equal(t, strings.ToLower(crypto.CreateAddress(addr, 0).Hex()), results[0]["contractAddress"])
+	_, ok := results[1]["contractAddress"]
+	assert.False(t, ok)
+	contract := crypto.CreateAddress(addr, 2)
+	assert.Equal(t, strings.ToLower(contract.Hex()), results[2]["contractAddress"])
+	assert.Equal(t, contract, receipts[2].ContractAddress)
+
+	node.Stop()
+}
+
+func TestLogTransactionIndexes(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// a rejected transaction between the two does not take an index
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{0, 5, 1} {
+		tx, err := createContractTransaction(privateKey, nonce, logEmittingContractCode)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: uint64(len(txs))})
+	var logs []*types.Log
+	for i, tx := range txs {
+		receipt, err := backend.DeliverTx(tx)
+		if i == 1 {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(receipt.Logs))
+		logs = append(logs, receipt.Logs...)
+	}
+
+	// the indexes are final before the block hash is known
+	for i, tx := range []*types.Transaction{txs[0], txs[2]} {
+		assert.Equal(t, tx.Hash(), logs[i].TxHash)
+		assert.Equal(t, uint(i), logs[i].TxIndex)
+		assert.Equal(t, uint(i), logs[i].Index)
+		assert.Equal(t, common.Hash{}, logs[i].BlockHash)
+	}
+
+	app.EndBlock(1)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+	for i, receipt := range backend.Receipts([]common.Hash{txs[0].Hash(), txs[2].Hash()}) {
+		assert.Equal(t, 1, len(receipt.Logs))
+		assert.Equal(t, uint(i), receipt.Logs[0].TxIndex)
+		assert.Equal(t, block.Hash(), receipt.Logs[0].BlockHash)
+	}
+
+	node.Stop()
+}
+
+func TestBlockBatching(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	for _, c := range []struct {
+		batchSize uint64
+		blockTxs  []int // transactions per ethereum block
+	}{
+		{0, []int{1, 1, 1, 0}},
+		{1, []int{1, 1, 1, 0}},
+		{2, []int{2, 1}},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{BlockBatchSize: c.batchSize}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+		blockchain := backend.Ethereum().BlockChain()
+
+		// one transaction in each of the first three heights
+		for height := uint64(1); height <= 4; height++ {
+			var txs []*types.Transaction
+			if height <= 3 {
+				tx, err := createTransaction(privateKey, height-1)
+				if err != nil {
+					t.Errorf("Error creating transaction: %v", err)
+				}
+				txs = append(txs, tx)
+			}
+			deliverBlock(t, app, height, txs...)
+		}
+
+		assert.Equal(t, uint64(len(c.blockTxs)), blockchain.CurrentBlock().NumberU64(), "batch size %d", c.batchSize)
+		for i, count := range c.blockTxs {
+			block := blockchain.GetBlockByNumber(uint64(i + 1))
+			assert.Equal(t, count, len(block.Transactions()), "batch size %d, block %d", c.batchSize, i+1)
+		}
+		assert.Equal(t, uint64(4), app.Info().LastBlockHeight)
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestSelfDestructs(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{ExecutionIndex: true}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, selfDestructContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+	contract := crypto.CreateAddress(addr, 0)
+
+	destructs, err := backend.SelfDestructs(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(destructs))
+
+	callTx, err := createCallTransaction(privateKey, 1, contract, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx)
+
+	destructs, err = backend.SelfDestructs(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []*ethereum.SelfDestruct{
+		{Contract: contract, Beneficiary: selfDestructBeneficiary, TxHash: callTx.Hash()},
+	}, destructs)
+
+	node.Stop()
+}
+
+func TestRefundRouting(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	treasury := common.StringToAddress("0x5555555555555555555555555555555555555555")
+
+	// the call clears a slot: 21000 + 3 + 3 + 5000 gas, of which half is refunded
+	// as the 15000 gas refund exceeds the cap
+	consumed := int64(26006)
+	refund := consumed / 2
+
+	for _, c := range []struct {
+		percent        uint64
+		treasuryAmount int64
+	}{
+		{0, 0},
+		{50, refund * 10 / 2},
+		{100, refund * 10},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{TreasuryAddress: treasury, RefundTreasuryPercent: c.percent}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+
+		deployTx, err := createContractTransaction(privateKey, 0, clearStorageContractCode)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 1, deployTx)
+		contract := crypto.CreateAddress(addr, 0)
+
+		state, err := backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		balance := state.GetBalance(addr)
+		// the deployment has no refund to route
+		assert.Equal(t, 0, state.GetBalance(treasury).Sign())
+
+		callTx, err := createCallTransaction(privateKey, 1, contract, nil)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 2, callTx)
+
+		state, err = backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		treasuryAmount := big.NewInt(c.treasuryAmount)
+		assert.Equal(t, 0, treasuryAmount.Cmp(state.GetBalance(treasury)), "percent %d", c.percent)
+
+		// the sender pays the gas it was charged for and the routed refund
+		paid := new(big.Int).Add(big.NewInt((consumed-refund)*10), treasuryAmount)
+		assert.Equal(t, 0, new(big.Int).Sub(balance, paid).Cmp(state.GetBalance(addr)), "percent %d", c.percent)
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestRefundRoutingCallingOut(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	treasury := common.StringToAddress("0x5555555555555555555555555555555555555555")
+	emtConfig := &ethereum.Config{TreasuryAddress: treasury, RefundTreasuryPercent: 100}
+	_, backend, app, cleanup := newTestApp(t, []common.Address{addr}, emtConfig, nil)
+	defer cleanup()
+
+	deployTx, err := createContractTransaction(privateKey, 0, clearAndCallContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+	contract := crypto.CreateAddress(addr, 0)
+
+	// the outermost frame ends with a CALL whose unused gas is returned to it
+	callTx, err := createCallTransaction(privateKey, 1, contract, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(2)
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), 2)
+	assert.Equal(t, 1, len(receipts))
+	gasUsed := receipts[0].GasUsed.Int64()
+
+	// the 15000 gas refund exceeds the cap, so half of the consumed gas is
+	// refunded and the sender is charged the other half, rounded up
+	state, err := backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	refund := new(big.Int).Div(state.GetBalance(treasury), big.NewInt(10)).Int64()
+	assert.True(t, refund == gasUsed || refund == gasUsed-1, "refund %d, gas used %d", refund, gasUsed)
+}
+
+func TestAccessList(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the caller loads its slot 1, then calls the storage contract writing slots 0 and 1
+	storage := crypto.CreateAddress(addr, 0)
+	callerCode := "600154506000600060006000600073" + storage.Hex()[2:] + "5af15000"
+	deployStorage, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deployCaller, err := createContractTransaction(privateKey, 1,
+		common.FromHex(fmt.Sprintf("0x60%02x600c60003960%02x6000f3", len(callerCode)/2, len(callerCode)/2)+callerCode))
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployStorage, deployCaller)
+	caller := crypto.CreateAddress(addr, 1)
+
+	callTx, err := createCallTransaction(privateKey, 2, caller, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx)
+
+	list, err := backend.AccessList(callTx.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, []*ethereum.AccessTuple{
+		{Address: caller, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1))}},
+		{Address: storage, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(0)), common.BigToHash(big.NewInt(1))}},
+	}, list)
+
+	_, err = backend.AccessList(common.Hash{})
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestRewardHistory(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	coinbase := common.StringToAddress("0x7777777777777777777777777777777777777777")
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, newTestStrategy(coinbase))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deliverBlock(t, app, 1)
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, tx)
+	deliverBlock(t, app, 3)
+
+	history := backend.RewardHistory(coinbase, 0, 10)
+	assert.Equal(t, 3, len(history))
+	for i, entry := range history {
+		assert.Equal(t, uint64(i+1), entry.BlockNumber)
+	}
+	assert.Equal(t, 0, blockReward.Cmp(history[0].Amount))
+	// block 2 also paid the fee of the transfer
+	assert.Equal(t, 0, new(big.Int).Add(blockReward, big.NewInt(21000*10)).Cmp(history[1].Amount))
+
+	page := backend.RewardHistory(coinbase, 1, 1)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, uint64(2), page[0].BlockNumber)
+
+	assert.Equal(t, 0, len(backend.RewardHistory(addr, 0, 10)))
+
+	node.Stop()
+}
+
+func TestReorderTxs(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{2, 0, 1} {
+		tx, err := createTransaction(privateKey, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+
+	for _, c := range []struct {
+		reorder bool
+		codes   []abciTypes.CodeType // DeliverTx result per transaction
+		nonce   uint64               // of the sender after the block
+	}{
+		// delivered as proposed the transactions after the gap are rejected
+		{false, []abciTypes.CodeType{abciTypes.ErrBaseInvalidInput.Code, abciTypes.OK.Code, abciTypes.OK.Code}, 2},
+		{true, []abciTypes.CodeType{abciTypes.OK.Code, abciTypes.OK.Code, abciTypes.OK.Code}, 3},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+		defer os.RemoveAll(tempDatadir)
+
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+			&ethereum.Config{ReorderTxs: c.reorder}, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: uint64(len(txs))})
+		for i, tx := range txs {
+			encodedTx, err := rlp.EncodeToBytes(tx)
+			if err != nil {
+				t.Errorf("Error encoding transaction: %v", err)
+			}
+			result := app.DeliverTx(encodedTx)
+			assert.Equal(t, c.codes[i], result.Code, "reorder %v, tx %d", c.reorder, i)
+			if c.reorder {
+				// queued transactions have no results yet
+				assert.Empty(t, result.Data)
+			}
+		}
+		app.EndBlock(1)
+		assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+		block := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+		assert.Equal(t, int(c.nonce), len(block.Transactions()), "reorder %v", c.reorder)
+		for i, tx := range block.Transactions() {
+			assert.Equal(t, uint64(i), tx.Nonce())
+		}
+		nonce, err := backend.PendingSnapshot().Nonce(addr)
+		assert.Nil(t, err)
+		assert.Equal(t, c.nonce, nonce, "reorder %v", c.reorder)
+
+		node.Stop()
+	}
+}
+
+func TestVerifyCommittedRoot(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{VerifyCommittedRoot: true}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// sound commits pass the verification
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+	deliverBlock(t, app, 2)
+	assert.Equal(t, uint64(2), backend.Ethereum().BlockChain().CurrentBlock().NumberU64())
+	assert.Empty(t, backend.CommitFailures())
+
+	node.Stop()
+}
+
+func TestAccountTransactions(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{AccountTxIndex: true}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	var txs []*types.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, err := createTransaction(privateKey, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	deploy, err := createContractTransaction(privateKey, 3, sumContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, txs[0])
+	deliverBlock(t, app, 2)
+	deliverBlock(t, app, 3, txs[1], txs[2], deploy)
+
+	sent := backend.SentTransactions(addr, 0, 10)
+	assert.Equal(t, 4, len(sent))
+	for i, c := range []struct {
+		number, index uint64
+		tx            *types.Transaction
+	}{
+		{1, 0, txs[0]},
+		{3, 0, txs[1]},
+		{3, 1, txs[2]},
+		{3, 2, deploy},
+	} {
+		assert.Equal(t, c.number, sent[i].BlockNumber)
+		assert.Equal(t, backend.Ethereum().BlockChain().GetBlockByNumber(c.number).Hash(), sent[i].BlockHash)
+		assert.Equal(t, c.tx.Hash(), sent[i].TxHash)
+		assert.Equal(t, c.index, sent[i].TxIndex)
+	}
+
+	received := backend.ReceivedTransactions(receiverAddress, 0, 10)
+	assert.Equal(t, sent[:3], received)
+	contract := crypto.CreateAddress(addr, 3)
+	assert.Equal(t, sent[3:], backend.ReceivedTransactions(contract, 0, 10))
+	assert.Equal(t, 0, len(backend.ReceivedTransactions(addr, 0, 10)))
+
+	page := backend.SentTransactions(addr, 1, 2)
+	assert.Equal(t, sent[1:3], page)
+	assert.Equal(t, 0, len(backend.SentTransactions(addr, 4, 10)))
+
+	node.Stop()
+}
+
+// deliverBlock runs a full BeginBlock, DeliverTx, EndBlock, Commit cycle,
+func TestMinBlockTransactions(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	for _, c := range []struct {
+		batchSize uint64
+		maxWait   uint64
+		heightTxs []int // transactions delivered per tendermint height
+		blockTxs  []int // transactions per ethereum block
+	}{
+		// below the threshold the block is held, at the threshold it is committed
+		{1, 0, []int{1, 1, 0, 1, 3, 0}, []int{3, 3, 0}},
+		// empty blocks are not held
+		{1, 0, []int{0, 0}, []int{0, 0}},
+		// the wait bounds how long a block is held
+		{1, 2, []int{1, 0, 1, 1, 0}, []int{1, 2, 0}},
+		// past the end of its batch a held block is committed at the first height
+		// that releases it, not at the end of the next batch
+		{2, 0, []int{1, 1, 1, 0, 0}, []int{3, 0}},
+		{2, 3, []int{1, 0, 0, 0, 0}, []int{1, 0}},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{BlockBatchSize: c.batchSize, MinBlockTransactions: 3, MaxBlockWait: c.maxWait}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+		blockchain := backend.Ethereum().BlockChain()
+
+		nonce := uint64(0)
+		for i, count := range c.heightTxs {
+			var txs []*types.Transaction
+			for j := 0; j < count; j++ {
+				tx, err := createTransaction(privateKey, nonce)
+				if err != nil {
+					t.Errorf("Error creating transaction: %v", err)
+				}
+				txs = append(txs, tx)
+				nonce++
+			}
+			deliverBlock(t, app, uint64(i+1), txs...)
+		}
+
+		assert.Equal(t, uint64(len(c.blockTxs)), blockchain.CurrentBlock().NumberU64(), "heights %v", c.heightTxs)
+		for i, count := range c.blockTxs {
+			block := blockchain.GetBlockByNumber(uint64(i + 1))
+			assert.Equal(t, count, len(block.Transactions()), "heights %v, block %d", c.heightTxs, i+1)
+		}
+		lastHeight, err := backend.TendermintHeight(blockchain.CurrentBlock().NumberU64())
+		assert.Nil(t, err)
+		assert.Equal(t, lastHeight, app.Info().LastBlockHeight)
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+// TestInfoWithoutRecordedHeight restarts on a head committed before the
+// tendermint heights were recorded in the headers
+func TestInfoWithoutRecordedHeight(t *testing.T) {
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	emtConfig := &ethereum.Config{BlockBatchSize: 2}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, nil, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	for height := uint64(1); height <= 4; height++ {
+		deliverBlock(t, app, height)
+	}
+	assert.Equal(t, uint64(4), app.Info().LastBlockHeight)
+
+	// replace the head with the same block without extra data
+	db := backend.Ethereum().ChainDb()
+	original := backend.Ethereum().BlockChain().CurrentBlock()
+	header := types.CopyHeader(original.Header())
+	header.Extra = nil
+	head := types.NewBlockWithHeader(header)
+	td := backend.Ethereum().BlockChain().GetTd(original.Hash(), original.NumberU64())
+	assert.Nil(t, core.WriteTd(db, head.Hash(), head.NumberU64(), td))
+	assert.Nil(t, core.WriteBlock(db, head))
+	assert.Nil(t, core.WriteCanonicalHash(db, head.Hash(), head.NumberU64()))
+	assert.Nil(t, core.WriteHeadHeaderHash(db, head.Hash()))
+	assert.Nil(t, core.WriteHeadBlockHash(db, head.Hash()))
+	node.Stop()
+
+	// the height is derived from the block number and the batch size
+	node, backend, app, err = makeTestAppWithConfig(tempDatadir, nil, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	assert.Equal(t, head.Hash(), backend.Ethereum().BlockChain().CurrentBlock().Hash())
+	info := app.Info()
+	assert.Equal(t, uint64(4), info.LastBlockHeight)
+	assert.Equal(t, head.Hash().Bytes(), info.LastBlockAppHash)
+
+	node.Stop()
+}
+
+func TestPendingFees(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 2})
+	assert.Equal(t, 0, backend.PendingFees().Sign())
+
+	// plain transfers use 21000 gas each
+	for nonce, gasPrice := range []int64{10, 30} {
+		tx, err := createTransactionWithGasPrice(privateKey, uint64(nonce), big.NewInt(gasPrice))
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	}
+	assert.Equal(t, 0, backend.PendingFees().Cmp(big.NewInt(21000*10+21000*30)))
+
+	app.EndBlock(1)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	assert.Equal(t, 0, backend.PendingFees().Sign())
+
+	node.Stop()
+}
+
+func TestCommitFailures(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	assert.Equal(t, 0, len(backend.CommitFailures()))
+
+	deliverBlock(t, app, 1)
+
+	// the block time is corrected to follow the parent, so the block is failed
+	// by an invariant instead of the chain
+	backend.RegisterInvariant("second block", func(header *types.Header, statedb *state.StateDB) error {
+		if header.Number.Uint64() == 2 {
+			return errors.New("injected failure")
+		}
+		return nil
+	})
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 1, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.ErrInternalError.Code, app.Commit().Code)
+
+	failures := backend.CommitFailures()
+	if assert.Equal(t, 1, len(failures)) {
+		assert.Equal(t, uint64(2), failures[0].BlockNumber)
+		assert.Equal(t, 1, failures[0].TxCount)
+		assert.Equal(t, 0, failures[0].GasUsed.Cmp(big.NewInt(21000)))
+		assert.Equal(t, ethereum.CommitStageInvariant, failures[0].Stage)
+		assert.NotEmpty(t, failures[0].Error)
+	}
+
+	node.Stop()
+}
+
+func TestSubscribeCommittedBlocks(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	committed := make(chan ethereum.CommittedBlockEvent, 2)
+	sub := backend.SubscribeCommittedBlocks(committed)
+	defer sub.Unsubscribe()
+
+	deployTx, err := createContractTransaction(privateKey, 0, logEmittingContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx, transferTx)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+	select {
+	case ev := <-committed:
+		assert.Equal(t, block.Hash(), ev.Block.Hash())
+		if assert.Equal(t, 1, len(ev.Logs)) {
+			assert.Equal(t, crypto.CreateAddress(addr, 0), ev.Logs[0].Address)
+			assert.Equal(t, deployTx.Hash(), ev.Logs[0].TxHash)
+			assert.Equal(t, block.Hash(), ev.Logs[0].BlockHash)
+			assert.Equal(t, uint64(1), ev.Logs[0].BlockNumber)
+		}
+	default:
+		t.Error("Committed block was not announced")
+	}
+
+	// blocks that are not inserted are not announced
+	backend.RegisterInvariant("failing", func(header *types.Header, statedb *state.StateDB) error {
+		return errors.New("injected failure")
+	})
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2})
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.ErrInternalError.Code, app.Commit().Code)
+	select {
+	case ev := <-committed:
+		t.Errorf("Unexpected committed block %d", ev.Block.NumberU64())
+	default:
+	}
+
+	node.Stop()
+}
+
+func TestStateCheckpoints(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+
+	// commits the block with the transaction on a new node
+	commitBlock := func(checkpoints map[uint64]common.Hash, check func(*ethereum.Backend, *app.EthermintApplication)) {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+		defer os.RemoveAll(tempDatadir)
+
+		emtConfig := &ethereum.Config{StateCheckpoints: checkpoints}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+		check(backend, app)
+		node.Stop()
+	}
+
+	var root common.Hash
+	commitBlock(nil, func(backend *ethereum.Backend, app *app.EthermintApplication) {
+		deliverBlock(t, app, 1, tx)
+		root = backend.Ethereum().BlockChain().GetBlockByNumber(1).Root()
+	})
+
+	// a matching checkpoint lets the block through
+	commitBlock(map[uint64]common.Hash{1: root}, func(backend *ethereum.Backend, app *app.EthermintApplication) {
+		deliverBlock(t, app, 1, tx)
+		assert.Equal(t, root, backend.Ethereum().BlockChain().CurrentBlock().Root())
+		assert.Equal(t, 0, len(backend.CommitFailures()))
+	})
+
+	// a mismatching checkpoint halts block processing before the block is inserted
+	commitBlock(map[uint64]common.Hash{1: {1}}, func(backend *ethereum.Backend, app *app.EthermintApplication) {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 1})
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+		app.EndBlock(1)
+		res := app.Commit()
+		assert.Equal(t, abciTypes.ErrInternalError.Code, res.Code)
+		assert.Contains(t, res.Log, "does not match the checkpoint")
+
+		assert.Equal(t, uint64(0), backend.Ethereum().BlockChain().CurrentBlock().NumberU64())
+		failures := backend.CommitFailures()
+		if assert.Equal(t, 1, len(failures)) {
+			assert.Equal(t, ethereum.CommitStageCheckpoint, failures[0].Stage)
+		}
+
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2})
+		app.EndBlock(2)
+		assert.Contains(t, app.Commit().Log, "halted")
+	})
+}
+
+func TestInvariants(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// sees the state after the transactions of the block
+	var nonces []uint64
+	backend.RegisterInvariant("nonces", func(header *types.Header, statedb *state.StateDB) error {
+		nonces = append(nonces, statedb.GetNonce(addr))
+		return nil
+	})
+	backend.RegisterInvariant("receiver", func(header *types.Header, statedb *state.StateDB) error {
+		if header.Number.Uint64() > 1 && statedb.GetBalance(receiverAddress).Sign() > 0 {
+			return errors.New("receiver has a balance")
+		}
+		return nil
+	})
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+	assert.Equal(t, []uint64{1}, nonces)
+	assert.Equal(t, 0, len(backend.CommitFailures()))
+
+	tx, err = createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.ErrInternalError.Code, app.Commit().Code)
+
+	assert.Equal(t, uint64(1), backend.Ethereum().BlockChain().CurrentBlock().NumberU64())
+	failures := backend.CommitFailures()
+	if assert.Equal(t, 1, len(failures)) {
+		assert.Equal(t, ethereum.CommitStageInvariant, failures[0].Stage)
+		assert.Contains(t, failures[0].Error, "receiver")
+	}
+
+	// the failed work is neither extended nor committed again
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 3, Time: 3, NumTxs: 1})
+	assert.Equal(t, abciTypes.ErrInternalError.Code, app.DeliverTx(encodedTx).Code)
+	app.EndBlock(3)
+	assert.Equal(t, abciTypes.ErrInternalError.Code, app.Commit().Code)
+	assert.Equal(t, uint64(1), backend.Ethereum().BlockChain().CurrentBlock().NumberU64())
+	assert.Equal(t, 1, len(backend.CommitFailures()))
+
+	node.Stop()
+}
+
+func TestFailureRefunds(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	contract := crypto.CreateAddress(addr, 0)
+
+	// the failing call consumes all of its 1000000 gas at price 10
+	for _, c := range []struct {
+		refunds map[common.Address]uint64
+		spent   *big.Int
+	}{
+		{nil, big.NewInt(10000000)},
+		{map[common.Address]uint64{contract: 40}, big.NewInt(6000000)},
+		{map[common.Address]uint64{receiverAddress: 40}, big.NewInt(10000000)},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{FailureRefunds: c.refunds}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+
+		deployTx, err := createContractTransaction(privateKey, 0, invalidJumpContractCode)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 1, deployTx)
+
+		state, err := backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		before := state.GetBalance(addr)
+
+		callTx, err := createCallTransaction(privateKey, 1, contract, nil)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 2, callTx)
+
+		state, err = backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		spent := new(big.Int).Sub(before, state.GetBalance(addr))
+		assert.Equal(t, 0, c.spent.Cmp(spent), "refunds %v, spent %v", c.refunds, spent)
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestGasSubsidies(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	pool := common.HexToAddress("0x6666666666666666666666666666666666666666")
+
+	// a transfer of 10 wei costs 21000 gas at price 10
+	for _, c := range []struct {
+		subsidies map[common.Address]uint64
+		funded    bool
+		spent     *big.Int
+	}{
+		{map[common.Address]uint64{receiverAddress: 50}, true, big.NewInt(10 + 105000)},
+		{map[common.Address]uint64{receiverAddress: 50}, false, big.NewInt(10 + 210000)},
+		{map[common.Address]uint64{pool: 50}, true, big.NewInt(10 + 210000)},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		alloc := []common.Address{addr}
+		if c.funded {
+			alloc = append(alloc, pool)
+		}
+		emtConfig := &ethereum.Config{GasSubsidies: c.subsidies, SubsidyPool: pool}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, alloc, mockclient, emtConfig, nil)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+
+		state, err := backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		before, poolBefore := state.GetBalance(addr), state.GetBalance(pool)
+
+		tx, err := createTransaction(privateKey, 0)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 1, tx)
+
+		state, err = backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		spent := new(big.Int).Sub(before, state.GetBalance(addr))
+		assert.Equal(t, 0, c.spent.Cmp(spent), "subsidies %v, funded %v, spent %v", c.subsidies, c.funded, spent)
+		paid := new(big.Int).Sub(poolBefore, state.GetBalance(pool))
+		assert.Equal(t, 0, paid.Cmp(new(big.Int).Sub(big.NewInt(10+210000), spent)))
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	for height := uint64(1); height <= 3; height++ {
+		deliverBlock(t, app, height)
+	}
+	assert.Nil(t, backend.VerifyChain())
+
+	// replace the canonical block 2 with one linked to nothing
+	db := backend.Ethereum().ChainDb()
+	original := backend.Ethereum().BlockChain().GetBlockByNumber(2)
+	header := types.CopyHeader(original.Header())
+	header.ParentHash = common.HexToHash("0x1234")
+	broken := types.NewBlockWithHeader(header)
+	assert.Nil(t, core.WriteBlock(db, broken))
+	assert.Nil(t, core.WriteCanonicalHash(db, broken.Hash(), 2))
+
+	err = backend.VerifyChain()
+	if assert.NotNil(t, err) {
+		linkErr, ok := err.(*ethereum.ChainLinkError)
+		if assert.True(t, ok) {
+			assert.Equal(t, uint64(2), linkErr.Number)
+		}
+	}
+
+	node.Stop()
+}
+
+func TestPendingBlockHash(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 1})
+	empty := backend.PendingBlockHash()
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	assert.NotEqual(t, empty, backend.PendingBlockHash())
+	app.EndBlock(1)
+
+	provisional := backend.PendingBlockHash()
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	assert.Equal(t, backend.Ethereum().BlockChain().CurrentBlock().Hash(), provisional)
+
+	node.Stop()
+}
+
+func TestCreationCollision(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// the first contract of addr would be created over an existing account
+	collision := crypto.CreateAddress(addr, 0)
+	alloc := core.GenesisAlloc{collision: {Balance: big.NewInt(1), Nonce: 1}}
+	node, backend, app, err := makeTestAppWithGenesis(tempDatadir, []common.Address{addr}, alloc, mockclient, &ethereum.Config{}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	state, err := backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	balance := state.GetBalance(addr)
+
+	// the creation is included as a failure that consumes all its gas
+	tx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+
+	state, err = backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), state.GetNonce(addr))
+	assert.Equal(t, 0, new(big.Int).Sub(balance, big.NewInt(1000000*10)).Cmp(state.GetBalance(addr)))
+	assert.Equal(t, uint64(1), state.GetNonce(collision))
+	assert.Equal(t, 0, state.GetCodeSize(collision))
+
+	block := backend.Ethereum().BlockChain().CurrentBlock()
+	assert.Equal(t, 1, len(block.Transactions()))
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), 1)
+	if assert.Equal(t, 1, len(receipts)) {
+		assert.Equal(t, 0, receipts[0].GasUsed.Cmp(tx.Gas()))
+		assert.Equal(t, 0, len(receipts[0].PostState), "failure status")
+	}
+
+	node.Stop()
+}
+
+func TestCallToAccountWithoutCode(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the call data is ignored, only its intrinsic gas is charged
+	data := common.FromHex("0xa9059cbb0000")
+	target := common.StringToAddress("0x5678567856785678567856785678567856785678")
+	callTx, err := createCallTransaction(privateKey, 0, target, data)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	valueTx, err := types.SignTx(
+		types.NewTransaction(1, target, big.NewInt(1000), big.NewInt(1000000), big.NewInt(10), data),
+		types.HomesteadSigner{},
+		privateKey,
+	)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, callTx, valueTx)
+
+	intrinsicGas := core.IntrinsicGas(data, false, true)
+	for _, receipt := range backend.Receipts([]common.Hash{callTx.Hash(), valueTx.Hash()}) {
+		assert.NotEqual(t, 0, len(receipt.PostState), "failure status")
+		assert.Equal(t, 0, receipt.GasUsed.Cmp(intrinsicGas))
+	}
+
+	state, err := backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, state.GetBalance(target).Cmp(big.NewInt(1000)))
+	assert.Equal(t, uint64(2), state.GetNonce(addr))
+
+	executionErrors, err := backend.ExecutionErrors(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(executionErrors))
+
+	node.Stop()
+}
+
+func TestCallToAccountWithCode(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	codeKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	codeAddr := crypto.PubkeyToAddress(codeKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// an account with a key that also carries code storing 1 in slot 0
+	balance, _ := new(big.Int).SetString("10000000000000000000000000000000000", 10)
+	alloc := core.GenesisAlloc{codeAddr: {Balance: balance, Code: common.FromHex("0x600160005500")}}
+	node, backend, app, err := makeTestAppWithGenesis(tempDatadir, []common.Address{addr}, alloc, mockclient, &ethereum.Config{}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// calls run the code and the account can still send transactions
+	callTx, err := createCallTransaction(privateKey, 0, codeAddr, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	sendTx, err := createTransaction(codeKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, callTx, sendTx)
+
+	state, err := backend.Ethereum().BlockChain().State()
+	assert.Nil(t, err)
+	assert.Equal(t, common.BigToHash(big.NewInt(1)), state.GetState(codeAddr, common.Hash{}))
+	assert.Equal(t, uint64(1), state.GetNonce(codeAddr))
+	assert.Equal(t, 6, state.GetCodeSize(codeAddr))
+
+	node.Stop()
+}
+
+func TestProposerCoinbase(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	strategy := &testStrategy{}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, &emtTypes.Strategy{MinerRewardStrategy: strategy, ValidatorsStrategy: strategy})
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the proposer of the strategy at a commit is the coinbase of the next block
+	for i, proposer := range []common.Address{
+		common.HexToAddress("0x7777777777777777777777777777777777777777"),
+		common.HexToAddress("0x9999999999999999999999999999999999999999"),
+	} {
+		strategy.receiver = proposer
+		deliverBlock(t, app, uint64(i+1))
+		assert.Equal(t, proposer, backend.PendingSnapshot().Header.Coinbase)
+	}
+	assert.Equal(t, common.HexToAddress("0x7777777777777777777777777777777777777777"),
+		backend.Ethereum().BlockChain().GetBlockByNumber(2).Coinbase())
+
+	node.Stop()
+}
+
+// stakingStrategy promotes the candidate to a validator once it has a balance
+type stakingStrategy struct {
+	testStrategy
+	candidate common.Address
+	pubKey    []byte
+	promoted  bool
+}
+
+func (s *stakingStrategy) CollectValidatorUpdates(statedb *state.StateDB) []*abciTypes.Validator {
+	if s.promoted || statedb.GetBalance(s.candidate).Sign() == 0 {
+		return nil
+	}
+	s.promoted = true
+	return []*abciTypes.Validator{{PubKey: s.pubKey, Power: 10}}
+}
+
+func TestValidatorUpdatesFromState(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	pubKey := []byte{1, 2, 3}
+	strategy := &stakingStrategy{candidate: receiverAddress, pubKey: pubKey}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, &ethereum.Config{},
+		&emtTypes.Strategy{MinerRewardStrategy: strategy, ValidatorsStrategy: strategy, ValidatorUpdateStrategy: strategy})
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the candidate has no balance yet
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1})
+	assert.Empty(t, app.EndBlock(1).Diffs)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// a transfer to the candidate makes it a validator at the end of the block
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	assert.Equal(t, []*abciTypes.Validator{{PubKey: pubKey, Power: 10}}, app.EndBlock(2).Diffs)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	node.Stop()
+}
+
+func TestFallbackCoinbase(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	proposer := common.HexToAddress("0x7777777777777777777777777777777777777777")
+	fallback := common.HexToAddress("0x8888888888888888888888888888888888888888")
+
+	// collect the diagnostics
+	var mtx sync.Mutex
+	var warned bool
+	handler := log.Root().GetHandler()
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Lvl == log.LvlWarn && strings.HasPrefix(r.Msg, "No ethereum address for the block proposer") {
+			mtx.Lock()
+			warned = true
+			mtx.Unlock()
+		}
+		return nil
+	}))
+	defer log.Root().SetHandler(handler)
+
+	for _, c := range []struct {
+		strategy *emtTypes.Strategy
+		fallback common.Address
+		coinbase common.Address
+		warned   bool
+	}{
+		{newTestStrategy(proposer), fallback, proposer, false},
+		{newTestStrategy(common.Address{}), fallback, fallback, true},
+		{nil, fallback, fallback, true},
+		{nil, common.Address{}, common.Address{}, true},
+	} {
+		mtx.Lock()
+		warned = false
+		mtx.Unlock()
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{FallbackCoinbase: c.fallback}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, c.strategy)
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+		deliverBlock(t, app, 1)
+
+		block := backend.Ethereum().BlockChain().CurrentBlock()
+		assert.Equal(t, c.coinbase, block.Coinbase())
+		mtx.Lock()
+		assert.Equal(t, c.warned, warned, "coinbase %x", c.coinbase)
+		mtx.Unlock()
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestReceipts(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createContractTransaction(privateKey, 1, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1)
+	deliverBlock(t, app, 2, tx2)
+
+	unknown := common.HexToHash("0x1234")
+	receipts := backend.Receipts([]common.Hash{tx2.Hash(), unknown, tx1.Hash(), unknown})
+	if assert.Equal(t, 4, len(receipts)) {
+		assert.Equal(t, tx2.Hash(), receipts[0].TxHash)
+		assert.Equal(t, crypto.CreateAddress(addr, 1), receipts[0].ContractAddress)
+		assert.Nil(t, receipts[1])
+		assert.Equal(t, tx1.Hash(), receipts[2].TxHash)
+		assert.Equal(t, 0, receipts[2].GasUsed.Cmp(big.NewInt(21000)))
+		assert.Nil(t, receipts[3])
+	}
+
+	assert.Equal(t, 0, len(backend.Receipts(nil)))
+
+	node.Stop()
+}
+
+func TestFeeOnlyRewards(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	coinbase := common.HexToAddress("0x9999999999999999999999999999999999999999")
+
+	for _, c := range []struct {
+		feeOnly bool
+		minted  *big.Int
+	}{
+		{false, blockReward},
+		{true, big.NewInt(0)},
+	} {
+		mockclient := NewMockClient()
+
+		tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+		if err != nil {
+			t.Error("unable to create temporary datadir")
+		}
+
+		emtConfig := &ethereum.Config{FeeOnlyRewards: c.feeOnly}
+		node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, newTestStrategy(coinbase))
+		if err != nil {
+			t.Errorf("Error making test EthermintApplication: %v", err)
+		}
+
+		// the transaction only moves value and fees between these accounts
+		supply := func() *big.Int {
+			state, err := backend.Ethereum().BlockChain().State()
+			assert.Nil(t, err)
+			total := new(big.Int)
+			for _, account := range []common.Address{addr, receiverAddress, coinbase} {
+				total.Add(total, state.GetBalance(account))
+			}
+			return total
+		}
+		before := supply()
+
+		tx, err := createTransaction(privateKey, 0)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		deliverBlock(t, app, 1, tx)
+
+		assert.Equal(t, 0, new(big.Int).Sub(supply(), before).Cmp(c.minted), "fee only %v", c.feeOnly)
+		state, err := backend.Ethereum().BlockChain().State()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, state.GetBalance(coinbase).Cmp(new(big.Int).Add(c.minted, big.NewInt(21000*10))))
+
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+func TestPendingBlock(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	client, err := node.Attach()
+	if err != nil {
+		t.Errorf("Error attaching rpc client: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createContractTransaction(privateKey, 1, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 2})
+	for _, tx := range []*types.Transaction{tx1, tx2} {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	}
+	app.EndBlock(1)
+
+	var pending map[string]interface{}
+	assert.Nil(t, client.Call(&pending, "eth_getBlockByNumber", "pending", false))
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	block := backend.Ethereum().BlockChain().CurrentBlock()
+	assert.Equal(t, block.Hash().Hex(), pending["hash"])
+	assert.Equal(t, block.Root().Hex(), pending["stateRoot"])
+	assert.Equal(t, block.TxHash().Hex(), pending["transactionsRoot"])
+	assert.Equal(t, block.ReceiptHash().Hex(), pending["receiptsRoot"])
+	assert.Equal(t, hexutil.EncodeBig(block.GasUsed()), pending["gasUsed"])
+	assert.Equal(t, []interface{}{tx1.Hash().Hex(), tx2.Hash().Hex()}, pending["transactions"])
+
+	node.Stop()
+}
+
+func TestPendingNonce(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	client, err := node.Attach()
+	if err != nil {
+		t.Errorf("Error attaching rpc client: %v", err)
+	}
+	assert.Equal(t, uint64(0), backend.PendingNonce(addr))
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 1, NumTxs: 2})
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := createTransaction(privateKey, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	}
+
+	// both transactions count before the block is committed
+	assert.Equal(t, uint64(2), backend.PendingNonce(addr))
+	var pendingNonce hexutil.Uint64
+	assert.Nil(t, client.Call(&pendingNonce, "ethermint_pendingNonce", addr))
+	assert.Equal(t, hexutil.Uint64(2), pendingNonce)
+	var count hexutil.Uint64
+	assert.Nil(t, client.Call(&count, "eth_getTransactionCount", addr, "pending"))
+	assert.Equal(t, hexutil.Uint64(2), count)
+
+	app.EndBlock(1)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	assert.Equal(t, uint64(2), backend.PendingNonce(addr))
+
+	node.Stop()
+}
+
+func TestMinAccountAge(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	poorKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	richKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	ether := big.NewInt(1e18)
+	emtConfig := &ethereum.Config{MinAccountAge: 3, YoungAccountBalance: ether}
+	node, _, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient, emtConfig, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the genesis account creates an under-funded and a well-funded account in block 1
+	fund := func(nonce uint64, key *ecdsa.PrivateKey, value *big.Int) *types.Transaction {
+		tx, err := types.SignTx(
+			types.NewTransaction(nonce, crypto.PubkeyToAddress(key.PublicKey), value, big.NewInt(21000), big.NewInt(10), nil),
+			types.HomesteadSigner{},
+			privateKey,
+		)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		return tx
+	}
+	deliverBlock(t, app, 1, fund(0, poorKey, new(big.Int).Div(ether, big.NewInt(2))), fund(1, richKey, ether))
+
+	encode := func(key *ecdsa.PrivateKey, nonce uint64) []byte {
+		tx, err := createTransaction(key, nonce)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		return encodedTx
+	}
+
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, app.CheckTx(encode(poorKey, 0)).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.CheckTx(encode(richKey, 0)).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.CheckTx(encode(privateKey, 2)).Code)
+
+	// blocks reject the transactions of young accounts too
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 3})
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, app.DeliverTx(encode(poorKey, 0)).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encode(richKey, 0)).Code)
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encode(privateKey, 2)).Code)
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// three blocks after its creation the under-funded account is established
+	deliverBlock(t, app, 3)
+	assert.Equal(t, abciTypes.OK.Code, app.CheckTx(encode(poorKey, 0)).Code)
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 4, Time: 4, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encode(poorKey, 0)).Code)
+	app.EndBlock(4)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	node.Stop()
+}
+
+func TestLogCounts(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the init codes emit one and two logs from the created contracts
+	tx1, err := createContractTransaction(privateKey, 0, logEmittingContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createContractTransaction(privateKey, 1, common.FromHex("0x60006000a060006000a000"))
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1, tx2)
+	deliverBlock(t, app, 2)
+
+	counts, err := backend.LogCounts(1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{
+		crypto.CreateAddress(addr, 0): 1,
+		crypto.CreateAddress(addr, 1): 2,
+	}, counts)
+
+	counts, err = backend.LogCounts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(counts))
+
+	_, err = backend.LogCounts(3)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestCheckTxState(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+	deliverBlock(t, app, 1, func() *types.Transaction {
+		tx, err := createTransaction(privateKey, 0)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		return tx
+	}())
+
+	encode := func(nonce uint64, value *big.Int) []byte {
+		tx, err := types.SignTx(
+			types.NewTransaction(nonce, receiverAddress, value, big.NewInt(21000), big.NewInt(10), nil),
+			types.HomesteadSigner{},
+			privateKey,
+		)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		return encodedTx
+	}
+
+	// a transaction of the started block doesn't move the check state
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encode(1, big.NewInt(10))).Code)
+	snapshot := backend.PendingSnapshot()
+	balance, err := snapshot.Balance(addr)
+	assert.Nil(t, err)
+
+	assert.Equal(t, abciTypes.ErrBadNonce.Code, app.CheckTx(encode(0, big.NewInt(10))).Code)
+	assert.Equal(t, abciTypes.OK, app.CheckTx(encode(1, big.NewInt(10))))
+	underfunded := new(big.Int).Mul(balance, big.NewInt(2))
+	assert.Equal(t, abciTypes.ErrInsufficientFunds.Code, app.CheckTx(encode(2, underfunded)).Code)
+
+	// the checks left the work untouched
+	assert.Equal(t, uint64(2), backend.PendingNonce(addr))
+	pendingBalance, err := snapshot.Balance(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, balance.Cmp(pendingBalance))
+	assert.Equal(t, 1, backend.PendingTxCount())
+
+	// the check state follows the committed block
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	assert.Equal(t, abciTypes.ErrBadNonce.Code, app.CheckTx(encode(1, big.NewInt(10))).Code)
+	assert.Equal(t, abciTypes.OK, app.CheckTx(encode(2, big.NewInt(10))))
+
+	node.Stop()
+}
+
+func TestSenderCounts(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr1, addr2}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey1, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createTransaction(privateKey2, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx3, err := createTransaction(privateKey1, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1, tx2, tx3)
+	deliverBlock(t, app, 2)
+
+	counts, err := backend.SenderCounts(1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{addr1: 2, addr2: 1}, counts)
+
+	counts, err = backend.SenderCounts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(counts))
+
+	_, err = backend.SenderCounts(3)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestSlotWriter(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{ExecutionIndex: true}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, counterContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+
+	contractAddr := crypto.CreateAddress(addr, 0)
+	callTx1, err := createCallTransaction(privateKey, 1, contractAddr, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx1)
+
+	callTx2, err := createCallTransaction(privateKey, 2, contractAddr, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 3, callTx2)
+	deliverBlock(t, app, 4)
+
+	slot := common.BigToHash(big.NewInt(0))
+	write, err := backend.SlotWriter(contractAddr, slot, 2)
+	if assert.Nil(t, err) {
+		assert.Equal(t, callTx1.Hash(), write.TxHash)
+		assert.Equal(t, uint64(2), write.BlockNumber)
+	}
+
+	write, err = backend.SlotWriter(contractAddr, slot, 4)
+	if assert.Nil(t, err) {
+		assert.Equal(t, callTx2.Hash(), write.TxHash)
+		assert.Equal(t, uint64(3), write.BlockNumber)
+	}
+
+	// the slot was not written before the first call, nor is slot 1 ever
+	_, err = backend.SlotWriter(contractAddr, slot, 1)
+	assert.NotNil(t, err)
+	_, err = backend.SlotWriter(contractAddr, common.BigToHash(big.NewInt(1)), 4)
+	assert.NotNil(t, err)
+
+	_, err = backend.SlotWriter(contractAddr, slot, 5)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestTransactionShards(t *testing.T) {
+	privateKey1, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr1 := crypto.PubkeyToAddress(privateKey1.PublicKey)
+	privateKey2, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr2 := crypto.PubkeyToAddress(privateKey2.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr1, addr2}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx1, err := createTransaction(privateKey1, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx2, err := createTransaction(privateKey2, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	tx3, err := createTransaction(privateKey1, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx1, tx2, tx3)
+
+	shards, err := backend.TransactionShards(1, 4)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(shards.Transactions)) {
+		assert.Equal(t, tx1.Hash(), shards.Transactions[0].TxHash)
+		assert.Equal(t, addr1, shards.Transactions[0].Sender)
+		assert.Equal(t, addr2, shards.Transactions[1].Sender)
+		// the transactions of a sender share its shard
+		assert.Equal(t, shards.Transactions[0].Shard, shards.Transactions[2].Shard)
+	}
+	var txs, gasUsed uint64
+	for _, load := range shards.Loads {
+		txs += load.Transactions
+		gasUsed += load.GasUsed
+	}
+	assert.Equal(t, uint64(3), txs)
+	assert.Equal(t, uint64(3*21000), gasUsed)
+
+	// the assignment is a function of the block alone
+	again, err := backend.TransactionShards(1, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, shards, again)
+
+	single, err := backend.TransactionShards(1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), single.CrossShard)
+	for _, tx := range single.Transactions {
+		assert.Equal(t, uint64(0), tx.Shard)
+	}
+
+	_, err = backend.TransactionShards(1, 0)
+	assert.NotNil(t, err)
+	_, err = backend.TransactionShards(2, 4)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestDeliverTxFailures(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	burnerTx, err := createContractTransaction(privateKey, 0, gasBurnerContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	storageTx, err := createContractTransaction(privateKey, 1, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, burnerTx, storageTx)
+
+	sign := func(nonce uint64, to common.Address, gas int64) *types.Transaction {
+		tx, err := types.SignTx(
+			types.NewTransaction(nonce, to, big.NewInt(0), big.NewInt(gas), big.NewInt(10), nil),
+			types.HomesteadSigner{},
+			privateKey,
+		)
+		if err != nil {
+			t.Errorf("Error creating transaction: %v", err)
+		}
+		return tx
+	}
+	deliver := func(tx *types.Transaction) abciTypes.Result {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		return app.DeliverTx(encodedTx)
+	}
+
+	// hits an invalid opcode, the only way to throw without REVERT
+	revertTx := sign(2, crypto.CreateAddress(addr, 0), 100000)
+	// not enough gas for the first SSTORE of the storage contract
+	outOfGasTx := sign(3, crypto.CreateAddress(addr, 1), 25000)
+	validTx := sign(4, receiverAddress, 21000)
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 5})
+	assert.Equal(t, abciTypes.OK.Code, deliver(revertTx).Code)
+	assert.Equal(t, abciTypes.OK.Code, deliver(outOfGasTx).Code)
+	assert.Equal(t, abciTypes.OK.Code, deliver(validTx).Code)
+
+	// invalid transactions are rejected as bad input, not as node faults
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, deliver(validTx).Code)
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, deliver(sign(5, receiverAddress, 20000)).Code)
+	assert.Equal(t, abciTypes.ErrBaseInvalidInput.Code, deliver(sign(7, receiverAddress, 21000)).Code)
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	// the failed executions are in the block and consumed all their gas
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(2)
+	if assert.NotNil(t, block) {
+		assert.Equal(t, 3, len(block.Transactions()))
+	}
+	receipts := backend.Receipts([]common.Hash{revertTx.Hash(), outOfGasTx.Hash(), validTx.Hash()})
+	if assert.Equal(t, 3, len(receipts)) && assert.NotNil(t, receipts[0]) && assert.NotNil(t, receipts[1]) && assert.NotNil(t, receipts[2]) {
+		assert.Equal(t, 0, receipts[0].GasUsed.Cmp(big.NewInt(100000)))
+		assert.Equal(t, 0, receipts[1].GasUsed.Cmp(big.NewInt(25000)))
+		assert.Equal(t, 0, receipts[2].GasUsed.Cmp(big.NewInt(21000)))
+	}
+
+	node.Stop()
+}
+
+// metricValue returns the value of the counter, or the sample count of the
+// histogram, registered with the default prometheus registry under the name
+func metricValue(t *testing.T, name string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		metric := family.GetMetric()[0]
+		if histogram := metric.GetHistogram(); histogram != nil {
+			return float64(histogram.GetSampleCount())
+		}
+		return metric.GetCounter().GetValue()
+	}
+	t.Fatalf("Metric %s is not registered", name)
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, _, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	names := []string{
+		"ethermint_transactions_delivered_total",
+		"ethermint_transactions_failed_total",
+		"ethermint_block_gas_used",
+		"ethermint_block_commit_seconds",
+		"ethermint_state_commit_seconds",
+	}
+	before := make(map[string]float64)
+	for _, name := range names {
+		before[name] = metricValue(t, name)
+	}
+
+	storageTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, storageTx)
+
+	// not enough gas for the first SSTORE of the storage contract
+	outOfGasTx, err := types.SignTx(
+		types.NewTransaction(1, crypto.CreateAddress(addr, 0), big.NewInt(0), big.NewInt(25000), big.NewInt(10), nil),
+		types.HomesteadSigner{},
+		privateKey,
+	)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 2)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, outOfGasTx, transferTx)
+
+	for name, delta := range map[string]float64{
+		"ethermint_transactions_delivered_total": 3,
+		"ethermint_transactions_failed_total":    1,
+		"ethermint_block_gas_used":               2,
+		"ethermint_block_commit_seconds":         2,
+		"ethermint_state_commit_seconds":         2,
+	} {
+		assert.Equal(t, before[name]+delta, metricValue(t, name), name)
+	}
+
+	node.Stop()
+}
+
+func TestBlockTime(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	// the test genesis has timestamp 0
+	for height, timestamp := range []uint64{5, 7, 13, 14} {
+		app.BeginBlock([]byte{}, &abciTypes.Header{Height: uint64(height + 1), Time: timestamp})
+		app.EndBlock(uint64(height + 1))
+		assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+	}
+
+	blockTime, err := backend.BlockTime(1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.BlockTime{Number: 1, Delta: 5, Average: 5, Window: 1}, blockTime)
+
+	blockTime, err = backend.BlockTime(3, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.BlockTime{Number: 3, Delta: 6, Average: 6, Window: 1}, blockTime)
+
+	blockTime, err = backend.BlockTime(4, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, &ethereum.BlockTime{Number: 4, Delta: 1, Average: 3, Window: 3}, blockTime)
+
+	_, err = backend.BlockTime(0, 1)
+	assert.NotNil(t, err)
+	_, err = backend.BlockTime(5, 1)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestFirstBlockHeader(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{}, newTestStrategy(receiverAddress))
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createTransaction(privateKey, 0)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	encodedTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Errorf("Error encoding transaction: %v", err)
+	}
+
+	// the first tendermint block has the timestamp of the test genesis
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 1, Time: 0, NumTxs: 1})
+	assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	app.EndBlock(1)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	blockchain := backend.Ethereum().BlockChain()
+	genesis := blockchain.Genesis()
+	header := blockchain.GetHeaderByNumber(1)
+	if assert.NotNil(t, header) {
+		assert.Equal(t, uint64(1), header.Number.Uint64())
+		assert.Equal(t, genesis.Hash(), header.ParentHash)
+		assert.Equal(t, genesis.Time().Uint64()+1, header.Time.Uint64())
+		assert.Equal(t, receiverAddress, header.Coinbase)
+		assert.Equal(t, 0, header.GasLimit.Cmp(core.CalcGasLimit(genesis)))
+		assert.Equal(t, 0, header.GasUsed.Cmp(big.NewInt(21000)))
+		difficulty := ethash.CalcDifficulty(backend.Ethereum().ApiBackend.ChainConfig(),
+			header.Time.Uint64(), genesis.Time().Uint64(), genesis.Number(), genesis.Difficulty())
+		assert.Equal(t, 0, header.Difficulty.Cmp(difficulty))
+	}
+	assert.Nil(t, backend.VerifyChain())
+
+	node.Stop()
+}
+
+func TestFailureReceipts(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, gasBurnerContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+
+	transferTx1, err := createTransaction(privateKey, 1)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	failingTx, err := createCallTransaction(privateKey, 2, crypto.CreateAddress(addr, 0), nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx2, err := createTransaction(privateKey, 3)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, transferTx1, failingTx, transferTx2)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(2)
+	if !assert.NotNil(t, block) {
+		return
+	}
+	assert.Equal(t, 3, len(block.Transactions()))
+	assert.Equal(t, failingTx.Hash(), block.Transactions()[1].Hash())
+
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), 2)
+	if assert.Equal(t, 3, len(receipts)) {
+		// the failed call is charged all its gas and carries the failure status
+		assert.Equal(t, 0, receipts[1].GasUsed.Cmp(failingTx.Gas()))
+		assert.Equal(t, 0, len(receipts[1].PostState))
+		assert.Equal(t, common.HashLength, len(receipts[0].PostState))
+		assert.Equal(t, common.HashLength, len(receipts[2].PostState))
+
+		total := new(big.Int)
+		for _, receipt := range receipts {
+			total.Add(total, receipt.GasUsed)
+			assert.Equal(t, 0, receipt.CumulativeGasUsed.Cmp(total))
+		}
+		assert.Equal(t, 0, new(big.Int).Add(big.NewInt(2*21000), failingTx.Gas()).Cmp(total))
+		assert.Equal(t, 0, block.GasUsed().Cmp(total))
+	}
+
+	node.Stop()
+}
+
+// init code that returns 5000 bytes of zeroes as the runtime code, whose storage
+// costs more than the gas left by createContractTransaction
+var oversizedDepositContractCode = common.FromHex("0x6113886000f3")
+
+func TestUntracedFailureReceipts(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	_, backend, app, cleanup := newTestApp(t, []common.Address{addr}, &ethereum.Config{}, nil)
+	defer cleanup()
+
+	// the code store runs out of gas after the init code returned
+	deployTx, err := createContractTransaction(privateKey, 0, oversizedDepositContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// the sha256 precompile needs 60 gas for the empty input
+	precompile := common.BytesToAddress([]byte{2})
+	precompileTx, err := types.SignTx(
+		types.NewTransaction(1, precompile, big.NewInt(0), big.NewInt(21010), big.NewInt(10), nil),
+		types.HomesteadSigner{},
+		privateKey,
+	)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 2)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx, precompileTx, transferTx)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(1)
+	if !assert.NotNil(t, block) {
+		return
+	}
+	assert.Equal(t, 3, len(block.Transactions()))
+
+	receipts := core.GetBlockReceipts(backend.Ethereum().ChainDb(), block.Hash(), 1)
+	if assert.Equal(t, 3, len(receipts)) {
+		// both failures are charged all their gas and carry the failure status
+		assert.Equal(t, 0, receipts[0].GasUsed.Cmp(deployTx.Gas()))
+		assert.Equal(t, 0, len(receipts[0].PostState))
+		assert.Equal(t, 0, receipts[1].GasUsed.Cmp(precompileTx.Gas()))
+		assert.Equal(t, 0, len(receipts[1].PostState))
+		assert.Equal(t, common.HashLength, len(receipts[2].PostState))
+	}
+
+	statedb, err := backend.Ethereum().BlockChain().State()
+	if err != nil {
+		t.Fatalf("Error getting state %v", err)
+	}
+	assert.Equal(t, 0, statedb.GetCodeSize(crypto.CreateAddress(addr, 0)))
+}
+
+func TestCallGraph(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	contractB := crypto.CreateAddress(addr, 0)
+	contractA := crypto.CreateAddress(addr, 1)
+	deployB, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deployA, err := createContractTransaction(privateKey, 1, callerContractCode(contractB))
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployB, deployA)
+
+	callTx, err := createCallTransaction(privateKey, 2, contractA, nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 3)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 2, callTx, transferTx)
+
+	edges, err := backend.CallGraph(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []*ethereum.CallEdge{{From: contractA, To: contractB, Calls: 1}}, edges)
+
+	// the call from A reached B
+	changes, err := backend.StorageChanges(callTx.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(changes))
+
+	edges, err = backend.CallGraph(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(edges))
+
+	_, err = backend.CallGraph(3)
+	assert.NotNil(t, err)
+
+	node.Stop()
+}
+
+func TestBlockFull(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	blockFull := app.CodeTypeBlockFull
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, gasBurnerContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx)
+
+	deliver := func(tx *types.Transaction) abciTypes.Result {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		return app.DeliverTx(encodedTx)
+	}
+
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: 2, Time: 2, NumTxs: 3})
+	gasLimit := backend.GasLimit()
+
+	// the burner consumes all its gas, leaving room for exactly one transfer
+	burnTx, err := types.SignTx(
+		types.NewTransaction(1, crypto.CreateAddress(addr, 0), big.NewInt(0),
+			new(big.Int).Sub(&gasLimit, big.NewInt(21000)), big.NewInt(10), nil),
+		types.HomesteadSigner{},
+		privateKey,
+	)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	transferTx, err := createTransaction(privateKey, 2)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	overflowTx, err := createTransaction(privateKey, 3)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	assert.Equal(t, abciTypes.OK.Code, deliver(burnTx).Code)
+	assert.Equal(t, abciTypes.OK.Code, deliver(transferTx).Code)
+
+	res := deliver(overflowTx)
+	assert.Equal(t, blockFull, res.Code)
+	assert.Contains(t, res.Log, ethereum.ErrBlockFull.Error())
+	app.EndBlock(2)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+
+	block := backend.Ethereum().BlockChain().GetBlockByNumber(2)
+	if assert.NotNil(t, block) {
+		assert.Equal(t, 2, len(block.Transactions()))
+		assert.Equal(t, 0, block.GasUsed().Cmp(&gasLimit))
+	}
+
+	// the rejected transaction fits in the next block
+	deliverBlock(t, app, 3, overflowTx)
+
+	node.Stop()
+}
+
+// pretending to be Tendermint, and asserts every step succeeds
+// counts the steps of the delivered transactions
+type stepCounter struct {
+	steps int
+}
+
+func (c *stepCounter) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	c.steps++
+	return nil
+}
+
+func TestTraceTransaction(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	node, backend, app, err := makeTestApp(tempDatadir, []common.Address{addr}, mockclient)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	deployTx, err := createContractTransaction(privateKey, 0, storageContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	// the init code hits an invalid opcode
+	failingTx, err := createContractTransaction(privateKey, 1, common.FromHex("0xfe"))
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, deployTx, failingTx)
+
+	transferTx, err := createTransaction(privateKey, 2)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	callTx, err := createCallTransaction(privateKey, 3, crypto.CreateAddress(addr, 0), nil)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	counter := &stepCounter{}
+	backend.SetTracer(counter)
+	deliverBlock(t, app, 2, transferTx, callTx)
+	backend.SetTracer(nil)
+
+	// the call is the last transaction of its block
+	trace, err := backend.TraceTransaction(callTx.Hash())
+	assert.Nil(t, err)
+	assert.False(t, trace.Failed)
+	receipt := backend.Receipts([]common.Hash{callTx.Hash()})[0]
+	assert.Equal(t, receipt.GasUsed.Uint64(), trace.Gas)
+	var ops []string
+	for _, step := range trace.Steps {
+		ops = append(ops, step.Op)
+	}
+	assert.Equal(t, []string{"PUSH1", "PUSH1", "SSTORE", "PUSH1", "PUSH1", "SSTORE", "STOP"}, ops)
+	assert.Equal(t, len(trace.Steps), counter.steps)
+
+	trace, err = backend.TraceTransaction(failingTx.Hash())
+	assert.Nil(t, err)
+	assert.True(t, trace.Failed)
+	assert.NotEqual(t, "", trace.Steps[len(trace.Steps)-1].Error)
+
+	trace, err = backend.TraceTransaction(transferTx.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(trace.Steps))
+
+	node.Stop()
+}
+
+func TestWebhooks(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("Error generating key %v", err)
+	}
+	addr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	mockclient := NewMockClient()
+
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Error("unable to create temporary datadir")
+	}
+	defer os.RemoveAll(tempDatadir)
+
+	// the first post fails and is retried
+	payloads := make(chan map[string]interface{}, 1)
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if posts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Error decoding webhook payload: %v", err)
+		}
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	webhooks := &ethereum.WebhookConfig{URLs: []string{server.URL}, Backoff: 10 * time.Millisecond}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, []common.Address{addr}, mockclient,
+		&ethereum.Config{Webhooks: webhooks}, nil)
+	if err != nil {
+		t.Errorf("Error making test EthermintApplication: %v", err)
+	}
+
+	tx, err := createContractTransaction(privateKey, 0, logEmittingContractCode)
+	if err != nil {
+		t.Errorf("Error creating transaction: %v", err)
+	}
+	deliverBlock(t, app, 1, tx)
+
+	block := backend.Ethereum().BlockChain().CurrentBlock()
+	receipt := backend.Receipts([]common.Hash{tx.Hash()})[0]
+
+	select {
+	case payload := <-payloads:
+		assert.Equal(t, tx.Hash().Hex(), payload["transactionHash"])
+		assert.Equal(t, ethereum.TxStatusSuccess, payload["status"])
+		assert.Equal(t, float64(1), payload["blockNumber"])
+		assert.Equal(t, block.Hash().Hex(), payload["blockHash"])
+		assert.Equal(t, float64(receipt.GasUsed.Uint64()), payload["gasUsed"])
+		assert.Equal(t, 1, len(payload["logs"].([]interface{})))
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the webhook")
+	}
+	assert.Equal(t, 2, posts)
+
+	node.Stop()
+}
+
+func deliverBlock(t testing.TB, app *app.EthermintApplication, height uint64, txs ...*types.Transaction) {
+	app.BeginBlock([]byte{}, &abciTypes.Header{Height: height, Time: height, NumTxs: uint64(len(txs))})
+	for _, tx := range txs {
+		encodedTx, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Errorf("Error encoding transaction: %v", err)
+		}
+		assert.Equal(t, abciTypes.OK.Code, app.DeliverTx(encodedTx).Code)
+	}
+	app.EndBlock(height)
+	assert.Equal(t, abciTypes.OK.Code, app.Commit().Code)
+}
+
+// newTestApp makes a test app with the config and strategy in a temporary
+// datadir. The returned function stops the node and removes the datadir.
+func newTestApp(t testing.TB, addresses []common.Address, emtConfig *ethereum.Config,
+	strategy *emtTypes.Strategy) (*node.Node, *ethereum.Backend, *app.EthermintApplication, func()) {
+	tempDatadir, err := ioutil.TempDir("", "ethermint_test")
+	if err != nil {
+		t.Fatal("unable to create temporary datadir")
+	}
+	node, backend, app, err := makeTestAppWithConfig(tempDatadir, addresses, NewMockClient(), emtConfig, strategy)
+	if err != nil {
+		os.RemoveAll(tempDatadir)
+		t.Fatalf("Error making test EthermintApplication: %v", err)
+	}
+	return node, backend, app, func() {
+		node.Stop()
+		os.RemoveAll(tempDatadir)
+	}
+}
+
+// mimics abciEthereumAction from cmd/ethermint/main.go
+func makeTestApp(tempDatadir string, addresses []common.Address, mockclient *MockClient) (*node.Node, *ethereum.Backend, *app.EthermintApplication, error) {
+	return makeTestAppWithConfig(tempDatadir, addresses, mockclient, &ethereum.Config{}, nil)
+}
+
+// makeTestApp with custom block processing settings and validator strategy
+func makeTestAppWithConfig(tempDatadir string, addresses []common.Address, mockclient *MockClient,
+	emtConfig *ethereum.Config, strategy *emtTypes.Strategy) (*node.Node, *ethereum.Backend, *app.EthermintApplication, error) {
+	return makeTestAppWithGenesis(tempDatadir, addresses, nil, mockclient, emtConfig, strategy)
+}
+
+// makeTestAppWithConfig with additional genesis accounts
+func makeTestAppWithGenesis(tempDatadir string, addresses []common.Address, alloc core.GenesisAlloc, mockclient *MockClient,
+	emtConfig *ethereum.Config, strategy *emtTypes.Strategy) (*node.Node, *ethereum.Backend, *app.EthermintApplication, error) {
+	stack, err := makeTestSystemNode(tempDatadir, addresses, alloc, mockclient, emtConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ethUtils.StartNode(stack)
+
+	var backend *ethereum.Backend
+	if err = stack.Service(&backend); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// In-proc RPC connection so Query can be forwarded as in ethermintCmd
+	rpcClient, err := stack.Attach()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	app, err := app.NewEthermintApplication(backend, rpcClient, strategy)
+
+	return stack, backend, app, err
+}
+
+func makeTestGenesis(addresses []common.Address) (*core.Genesis, error) {
+	gopath := os.Getenv("GOPATH")
+	genesisPath := filepath.Join(gopath, "src/github.com/tendermint/ethermint/dev/genesis.json")
+
+	file, err := os.Open(genesisPath)
+	defer file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		ethUtils.Fatalf("invalid genesis file: %v", err)
+	}
+
+	balance, result := new(big.Int).SetString("10000000000000000000000000000000000", 10)
+	if !result {
+		return nil, errors.New("BigInt convertation error")
+	}
+
+	for _, addr := range addresses {
+		genesis.Alloc[addr] = core.GenesisAccount{Balance: balance}
+	}
+
+	return genesis, nil
+}
+
+// mimics MakeSystemNode from ethereum/node.go
+func makeTestSystemNode(tempDatadir string, addresses []common.Address, alloc core.GenesisAlloc, mockclient *MockClient,
+	emtConfig *ethereum.Config) (*node.Node, error) {
+	// Configure the node's service container
+	nodeConf := emtUtils.DefaultNodeConfig()
+	emtUtils.SetEthermintNodeConfig(&nodeConf)
+	nodeConf.DataDir = tempDatadir
+
+	// Configure the Ethereum service
+	ethConf := eth.DefaultConfig
+	emtUtils.SetEthermintEthConfig(&ethConf)
+
+	genesis, err := makeTestGenesis(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	for addr, account := range alloc {
+		genesis.Alloc[addr] = account
+	}
+	ethConf.Genesis = genesis
+
+	// Assemble and return the protocol stack
+	stack, err := node.New(&nodeConf)
+	if err != nil {
+		return nil, err
+	}
+	return stack, stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return ethereum.NewBackend(ctx, &ethConf, emtConfig, mockclient)
+	})
+}
+
+// init code that emits an empty LOG0 and deploys no runtime code
+var logEmittingContractCode = common.FromHex("0x60006000a000")
+
+// deploys a contract that stores 1 in slot 0 and 2 in slot 1 when called
+var storageContractCode = common.FromHex("0x600b600c600039600b6000f3" + "6001600055600260015500")
+
+// deploys a contract that stores the sum of slots 0, 1 and 2 plus 1 in slot 0 when called
+var sumContractCode = common.FromHex("0x6012600c60003960126000f3" + "600054600154016002540160010160005500")
+
+// deploys a contract that increments slot 0 when called
+var counterContractCode = common.FromHex("0x600a600c600039600a6000f3" + "60005460010160005500")
+
+// deploys a contract that calls the given contract with 65535 gas when called
+func callerContractCode(callee common.Address) []byte {
+	return common.FromHex("0x6025600c60003960256000f3" + "60006000600060006000" + "73" + common.Bytes2Hex(callee[:]) + "61fffff15000")
+}
+
+// deploys a contract that reads its own balance when called
+var balanceContractCode = common.FromHex("0x6004600c60003960046000f3" + "30315000")
+
+// deploys a contract that returns the number of the block it is called in
+var blockNumberContractCode = common.FromHex("0x6009600c60003960096000f3" + "4360005260206000f3")
+
+// deploys a contract that adds with an empty stack when called
+var stackUnderflowContractCode = common.FromHex("0x6001600c60003960016000f3" + "01")
+
+// deploys a contract that jumps to a position that is no JUMPDEST when called
+var invalidJumpContractCode = common.FromHex("0x6003600c60003960036000f3" + "600056")
+
+var selfDestructBeneficiary = common.HexToAddress("0x5555555555555555555555555555555555555555")
+
+// deploys a contract that self-destructs to selfDestructBeneficiary when called
+var selfDestructContractCode = common.FromHex("0x6016600c60003960166000f3" + "73" + selfDestructBeneficiary.Hex()[2:] + "ff")
+
+// deploys a contract that stores 1 in slot 0 on creation and clears it when called
+var clearStorageContractCode = common.FromHex("0x60016000556006601160003960066000f3" + "600060005500")
+
+// deploys a contract that sets slot 0, clearing it and then calling the identity
+// precompile with 100 gas as its last step when called
+var clearAndCallContractCode = common.FromHex("0x60016000556014601160003960146000f3" +
+	"6000600055" + "600060006000600060006004" + "6064f1")
+
+// deploys a contract that hits an invalid opcode, consuming all gas, when called
+var gasBurnerContractCode = common.FromHex("0x6001600c60003960016000f3" + "fe")
+
+func createCallTransaction(key *ecdsa.PrivateKey, nonce uint64, to common.Address, data []byte) (*types.Transaction, error) {
+	signer := types.HomesteadSigner{}
+
+	return types.SignTx(
+		types.NewTransaction(nonce, to, big.NewInt(0), big.NewInt(1000000), big.NewInt(10), data),
+		signer,
+		key,
+	)
+}
+
+func createContractTransaction(key *ecdsa.PrivateKey, nonce uint64, code []byte) (*types.Transaction, error) {
+	signer := types.HomesteadSigner{}
+
+	return types.SignTx(
+		types.NewContractCreation(nonce, big.NewInt(0), big.NewInt(1000000), big.NewInt(10), code),
+		signer,
+		key,
+	)
+}
+
+func createTransaction(key *ecdsa.PrivateKey, nonce uint64) (*types.Transaction, error) {
+	return createTransactionWithGasPrice(key, nonce, big.NewInt(10))
+}
+
+func createTransactionWithGasPrice(key *ecdsa.PrivateKey, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
 	signer := types.HomesteadSigner{}
 
 	return types.SignTx(
-		types.NewTransaction(nonce, receiverAddress, big.NewInt(10), big.NewInt(21000), big.NewInt(10),
+		types.NewTransaction(nonce, receiverAddress, big.NewInt(10), big.NewInt(21000), gasPrice,
 			nil),
 		signer,
 		key,
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// callCache keeps the results of read-only calls forwarded by Query.
+// All entries belong to the latest block with the given hash and are dropped
+// as soon as a call against another block is looked up. The state root is no
+// key: it stays the same over empty blocks, whose number and time still change
+// the results of calls.
+type callCache struct {
+	mtx  sync.Mutex
+	size int
+	ttl  time.Duration
+
+	block   common.Hash
+	entries map[string]callCacheEntry
+	order   []string // keys in insertion order, oldest first
+}
+
+type callCacheEntry struct {
+	result  []byte
+	expires time.Time
+}
+
+func newCallCache(size int, ttl time.Duration) *callCache {
+	return &callCache{size: size, ttl: ttl, entries: make(map[string]callCacheEntry)}
+}
+
+// switchBlock drops all entries if block is not the hash of the cached block
+func (c *callCache) switchBlock(block common.Hash) {
+	if c.block != block {
+		c.block = block
+		c.entries = make(map[string]callCacheEntry)
+		c.order = nil
+	}
+}
+
+// get returns the cached result of the call key against the block
+func (c *callCache) get(block common.Hash, key string) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.switchBlock(block)
+	entry, ok := c.entries[key]
+	if !ok || (c.ttl > 0 && time.Now().After(entry.expires)) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// put caches the result of the call key against the block, evicting the
+// oldest entries beyond the size of the cache
+func (c *callCache) put(block common.Hash, key string, result []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.switchBlock(block)
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = callCacheEntry{result: result, expires: time.Now().Add(c.ttl)}
+
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
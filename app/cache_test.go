@@ -0,0 +1,78 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCallCacheEntries(t *testing.T) {
+	cache := newCallCache(2, time.Minute)
+	block := common.Hash{1}
+
+	cache.put(block, "a", []byte{1})
+	cache.put(block, "b", []byte{2})
+	result, ok := cache.get(block, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1}, result)
+
+	// the oldest entry is evicted beyond the size
+	cache.put(block, "c", []byte{3})
+	_, ok = cache.get(block, "a")
+	assert.False(t, ok)
+	_, ok = cache.get(block, "c")
+	assert.True(t, ok)
+
+	// a lookup against another block drops the entries
+	_, ok = cache.get(common.Hash{2}, "c")
+	assert.False(t, ok)
+	_, ok = cache.get(block, "c")
+	assert.False(t, ok)
+}
+
+func TestCallCacheExpiry(t *testing.T) {
+	cache := newCallCache(2, time.Millisecond)
+	block := common.Hash{1}
+
+	cache.put(block, "a", []byte{1})
+	time.Sleep(5 * time.Millisecond)
+	_, ok := cache.get(block, "a")
+	assert.False(t, ok)
+}
+
+func TestIsCommittedCall(t *testing.T) {
+	call := map[string]interface{}{"to": "0x1"}
+	for _, c := range []struct {
+		in        jsonRequest
+		committed bool
+	}{
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call, "latest"}}, true},
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call, "0x1"}}, true},
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call, "pending"}}, false},
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call}}, false},
+		{jsonRequest{Method: "eth_getBalance", Params: []interface{}{"0x1", "latest"}}, false},
+	} {
+		assert.Equal(t, c.committed, isCommittedCall(c.in), "%v", c.in)
+	}
+}
+
+func TestIsPendingQuery(t *testing.T) {
+	call := map[string]interface{}{"to": "0x1"}
+	for _, c := range []struct {
+		in      jsonRequest
+		pending bool
+	}{
+		{jsonRequest{Method: "eth_getBalance", Params: []interface{}{"0x1", "pending"}}, true},
+		{jsonRequest{Method: "eth_getCode", Params: []interface{}{"0x1", "pending"}}, true},
+		// bounded by the rpc service
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call, "pending"}}, false},
+		{jsonRequest{Method: "eth_estimateGas", Params: []interface{}{call}}, false},
+		{jsonRequest{Method: "eth_call", Params: []interface{}{call, "latest"}}, false},
+		{jsonRequest{Method: "eth_blockNumber"}, false},
+	} {
+		assert.Equal(t, c.pending, isPendingQuery(c.in), "%v", c.in)
+	}
+}
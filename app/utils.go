@@ -6,6 +6,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 
 	abciTypes "github.com/tendermint/abci/types"
@@ -18,6 +19,31 @@ type jsonRequest struct {
 	Params []interface{}   `json:"params,omitempty"`
 }
 
+// isCommittedCall reports whether the request is an eth_call against a
+// committed block, whose result only changes with the chain head
+func isCommittedCall(in jsonRequest) bool {
+	if in.Method != "eth_call" || len(in.Params) != 2 {
+		return false
+	}
+	block, ok := in.Params[1].(string)
+	return !ok || block != "pending"
+}
+
+// isPendingQuery reports whether the request reads the pending block, which
+// copies the pending state. eth_call and eth_estimateGas hold a state copy slot
+// of their own, see ethereum.PendingCallRPCService.
+func isPendingQuery(in jsonRequest) bool {
+	if in.Method == "eth_call" || in.Method == "eth_estimateGas" {
+		return false
+	}
+	for _, param := range in.Params {
+		if param == "pending" {
+			return true
+		}
+	}
+	return false
+}
+
 // rlp decode an etherum transaction
 func decodeTx(txBytes []byte) (*types.Transaction, error) {
 	tx := new(types.Transaction)
@@ -31,11 +57,26 @@ func decodeTx(txBytes []byte) (*types.Transaction, error) {
 //-------------------------------------------------------
 // convenience methods for validators
 
+// Receiver returns the coinbase of the next ethereum block, which Commit passes
+// on to the header of the new pending block. The abci header of BeginBlock does
+// not name the proposer, so the strategy maps the validators it tracks to the
+// ethereum address of the proposer.
 func (app *EthermintApplication) Receiver() common.Address {
+	var receiver common.Address
 	if app.strategy != nil {
-		return app.strategy.Receiver()
+		receiver = app.strategy.Receiver()
+	}
+	if receiver != (common.Address{}) {
+		return receiver
+	}
+
+	fallback := app.backend.EthermintConfig().FallbackCoinbase
+	if fallback == (common.Address{}) {
+		log.Warn("No ethereum address for the block proposer, the block rewards are burned")
+	} else {
+		log.Warn("No ethereum address for the block proposer, rewarding the fallback coinbase", "coinbase", fallback)
 	}
-	return common.Address{}
+	return fallback
 }
 
 func (app *EthermintApplication) SetValidators(validators []*abciTypes.Validator) {
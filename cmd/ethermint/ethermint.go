@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/accounts"
@@ -68,6 +70,10 @@ func ethermintCmd(ctx *cli.Context) error {
 		os.Exit(1)
 	}
 
+	if metricsAddr := ctx.GlobalString(emtUtils.MetricsAddrFlag.Name); metricsAddr != "" {
+		startMetricsServer(metricsAddr)
+	}
+
 	cmn.TrapSignal(func() {
 		srv.Stop()
 	})
@@ -75,6 +81,18 @@ func ethermintCmd(ctx *cli.Context) error {
 	return nil
 }
 
+// startMetricsServer serves the prometheus metrics of the default registry for scraping
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error("Metrics server stopped", "addr", addr, "err", err)
+		}
+	}()
+	log.Info("Serving metrics", "addr", addr)
+}
+
 func startNode(ctx *cli.Context, stack *node.Node) {
 	ethUtils.StartNode(stack)
 
@@ -12,6 +12,8 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/tendermint/ethermint/ethereum"
 )
 
 func initCmd(ctx *cli.Context) error {
@@ -42,6 +44,9 @@ func initCmd(ctx *cli.Context) error {
 	if err != nil {
 		ethUtils.Fatalf("failed to write genesis block: %v", err)
 	}
+	if err := ethereum.WriteGenesisAlloc(chainDb, genesis); err != nil {
+		ethUtils.Fatalf("failed to write genesis allocation: %v", err)
+	}
 
 	log.Info("successfully wrote genesis block and/or chain rule set", "hash", hash)
 	return nil
@@ -55,8 +55,66 @@ var (
 		utils.TendermintAddrFlag,
 		utils.ABCIAddrFlag,
 		utils.ABCIProtocolFlag,
+		utils.MetricsAddrFlag,
 		utils.VerbosityFlag,
 		utils.ConfigFileFlag,
+		utils.TreasuryAddrFlag,
+		utils.FeeOnlyRewardsFlag,
+		utils.FallbackCoinbaseFlag,
+		utils.TreasuryFeePercentFlag,
+		utils.RefundTreasuryPercentFlag,
+		utils.FailureRefundsFlag,
+		utils.GasSubsidiesFlag,
+		utils.SubsidyPoolFlag,
+		utils.GasLimitPIDTargetFlag,
+		utils.GasLimitPIDKpFlag,
+		utils.GasLimitPIDKiFlag,
+		utils.GasLimitPIDKdFlag,
+		utils.GasLimitPIDWindowFlag,
+		utils.GasLimitBumpThresholdFlag,
+		utils.GasLimitBumpBlocksFlag,
+		utils.GasLimitBumpStepFlag,
+		utils.GasLimitBumpCeilingFlag,
+		utils.GasLimitFixedFlag,
+		utils.GasLimitMinFlag,
+		utils.GasLimitMaxFlag,
+		utils.UtilizationAlertFlag,
+		utils.UtilizationAlertWindowFlag,
+		utils.CoinbaseMaturityFlag,
+		utils.SenderGasPercentFlag,
+		utils.MinGasPriceFlag,
+		utils.MaxGasPriceFlag,
+		utils.GasPriceFloorFlag,
+		utils.GasPriceFloorStepFlag,
+		utils.GasPriceFloorWindowFlag,
+		utils.GasPricePercentilesFlag,
+		utils.GasPricePercentilesBlocksFlag,
+		utils.GasPricePercentilesMinSamplesFlag,
+		utils.GasPricePercentilesMinFlag,
+		utils.GasPriceGranularityFlag,
+		utils.MaxFeeValueMultipleFlag,
+		utils.MinAccountAgeFlag,
+		utils.YoungAccountBalanceFlag,
+		utils.StateAccountLimitFlag,
+		utils.BlockBatchSizeFlag,
+		utils.MinBlockTxsFlag,
+		utils.MaxBlockWaitFlag,
+		utils.RequireReplayProtectionFlag,
+		utils.ReorderTxsFlag,
+		utils.CanonicalTxOrderFlag,
+		utils.SimulateCheckTxFlag,
+		utils.CallCacheSizeFlag,
+		utils.CallCacheTTLFlag,
+		utils.MaxStateCopiesFlag,
+		utils.StateCopyWaitFlag,
+		utils.StateCheckpointsFlag,
+		utils.VerifyCommittedRootFlag,
+		utils.AccountTxIndexFlag,
+		utils.ExecutionIndexFlag,
+		utils.WebhookURLsFlag,
+		utils.WebhookQueueSizeFlag,
+		utils.WebhookRetriesFlag,
+		utils.WebhookBackoffFlag,
 	}
 )
 
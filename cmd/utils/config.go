@@ -1,11 +1,18 @@
 package utils
 
 import (
+	"math/big"
+	"strconv"
+	"strings"
+
 	cli "gopkg.in/urfave/cli.v1"
 
 	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
 
 	"github.com/tendermint/ethermint/ethereum"
 
@@ -29,10 +36,11 @@ type gethConfig struct {
 
 func MakeFullNode(ctx *cli.Context) *node.Node {
 	stack, cfg := makeConfigNode(ctx)
+	emtConfig := MakeEthermintConfig(ctx)
 
 	tendermintLAddr := ctx.GlobalString(TendermintAddrFlag.Name)
 	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return ethereum.NewBackend(ctx, &cfg.Eth, rpcClient.NewURIClient(tendermintLAddr))
+		return ethereum.NewBackend(ctx, &cfg.Eth, emtConfig, rpcClient.NewURIClient(tendermintLAddr))
 	}); err != nil {
 		ethUtils.Fatalf("Failed to register the ABCI application service: %v", err)
 	}
@@ -59,11 +67,273 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	return stack, cfg
 }
 
+// MakeEthermintConfig creates the block processing config from the command line flags
+func MakeEthermintConfig(ctx *cli.Context) *ethereum.Config {
+	cfg := &ethereum.Config{}
+
+	if addr := ctx.GlobalString(TreasuryAddrFlag.Name); addr != "" {
+		if !common.IsHexAddress(addr) {
+			ethUtils.Fatalf("Invalid treasury address: %v", addr)
+		}
+		cfg.TreasuryAddress = common.HexToAddress(addr)
+	}
+	cfg.TreasuryFeePercent = ctx.GlobalUint64(TreasuryFeePercentFlag.Name)
+	if cfg.TreasuryFeePercent > 100 {
+		ethUtils.Fatalf("Treasury fee percent must be between 0 and 100, got %d", cfg.TreasuryFeePercent)
+	}
+	if cfg.TreasuryFeePercent > 0 && cfg.TreasuryAddress == (common.Address{}) {
+		ethUtils.Fatalf("Treasury fee percent %d requires a treasury address", cfg.TreasuryFeePercent)
+	}
+
+	cfg.FeeOnlyRewards = ctx.GlobalBool(FeeOnlyRewardsFlag.Name)
+
+	if addr := ctx.GlobalString(FallbackCoinbaseFlag.Name); addr != "" {
+		if !common.IsHexAddress(addr) {
+			ethUtils.Fatalf("Invalid fallback coinbase: %v", addr)
+		}
+		cfg.FallbackCoinbase = common.HexToAddress(addr)
+	}
+
+	cfg.RefundTreasuryPercent = ctx.GlobalUint64(RefundTreasuryPercentFlag.Name)
+	if cfg.RefundTreasuryPercent > 100 {
+		ethUtils.Fatalf("Refund treasury percent must be between 0 and 100, got %d", cfg.RefundTreasuryPercent)
+	}
+	if cfg.RefundTreasuryPercent > 0 && cfg.TreasuryAddress == (common.Address{}) {
+		ethUtils.Fatalf("Refund treasury percent %d requires a treasury address", cfg.RefundTreasuryPercent)
+	}
+
+	if refunds := ctx.GlobalString(FailureRefundsFlag.Name); refunds != "" {
+		cfg.FailureRefunds = parseAddressPercents(refunds, "failure refund")
+	}
+
+	if subsidies := ctx.GlobalString(GasSubsidiesFlag.Name); subsidies != "" {
+		cfg.GasSubsidies = parseAddressPercents(subsidies, "gas subsidy")
+		addr := ctx.GlobalString(SubsidyPoolFlag.Name)
+		if !common.IsHexAddress(addr) {
+			ethUtils.Fatalf("Invalid subsidy pool address: %v", addr)
+		}
+		cfg.SubsidyPool = common.HexToAddress(addr)
+	}
+
+	if target := ctx.GlobalInt64(GasLimitPIDTargetFlag.Name); target > 0 {
+		if target > 1000 {
+			ethUtils.Fatalf("PID gas limit target must be at most 1000 per mille, got %d", target)
+		}
+		cfg.GasLimitPID = &ethereum.PIDGasLimitConfig{
+			Target: target,
+			Kp:     ctx.GlobalInt64(GasLimitPIDKpFlag.Name),
+			Ki:     ctx.GlobalInt64(GasLimitPIDKiFlag.Name),
+			Kd:     ctx.GlobalInt64(GasLimitPIDKdFlag.Name),
+			Window: ctx.GlobalUint64(GasLimitPIDWindowFlag.Name),
+		}
+	}
+
+	if threshold := ctx.GlobalInt64(GasLimitBumpThresholdFlag.Name); threshold > 0 {
+		if threshold > 1000 {
+			ethUtils.Fatalf("Gas limit bump threshold must be at most 1000 per mille, got %d", threshold)
+		}
+		step, ok := new(big.Int).SetString(ctx.GlobalString(GasLimitBumpStepFlag.Name), 10)
+		if !ok || step.Sign() <= 0 {
+			ethUtils.Fatalf("Invalid gas limit bump step: %v", ctx.GlobalString(GasLimitBumpStepFlag.Name))
+		}
+		ceiling, ok := new(big.Int).SetString(ctx.GlobalString(GasLimitBumpCeilingFlag.Name), 10)
+		if !ok || ceiling.Sign() <= 0 {
+			ethUtils.Fatalf("Invalid gas limit bump ceiling: %v", ctx.GlobalString(GasLimitBumpCeilingFlag.Name))
+		}
+		cfg.GasLimitBump = &ethereum.GasLimitBumpConfig{
+			Threshold: threshold,
+			Blocks:    ctx.GlobalUint64(GasLimitBumpBlocksFlag.Name),
+			Step:      step,
+			Ceiling:   ceiling,
+		}
+	}
+
+	policy := &ethereum.GasLimitPolicyConfig{
+		Fixed: parseGasLimit(ctx, GasLimitFixedFlag.Name),
+		Min:   parseGasLimit(ctx, GasLimitMinFlag.Name),
+		Max:   parseGasLimit(ctx, GasLimitMaxFlag.Name),
+	}
+	if policy.Min != nil && policy.Max != nil && policy.Min.Cmp(policy.Max) > 0 {
+		ethUtils.Fatalf("Minimum gas limit %v is above the maximum %v", policy.Min, policy.Max)
+	}
+	if policy.Fixed != nil || policy.Min != nil || policy.Max != nil {
+		cfg.GasLimitPolicy = policy
+	}
+
+	if threshold := ctx.GlobalInt64(UtilizationAlertFlag.Name); threshold > 0 {
+		if threshold > 1000 {
+			ethUtils.Fatalf("Utilization alert threshold must be at most 1000 per mille, got %d", threshold)
+		}
+		cfg.UtilizationAlert = &ethereum.UtilizationAlertConfig{
+			Threshold: threshold,
+			Window:    ctx.GlobalUint64(UtilizationAlertWindowFlag.Name),
+		}
+	}
+
+	cfg.CoinbaseMaturity = ctx.GlobalUint64(CoinbaseMaturityFlag.Name)
+
+	cfg.SenderGasPercent = ctx.GlobalUint64(SenderGasPercentFlag.Name)
+	if cfg.SenderGasPercent > 100 {
+		ethUtils.Fatalf("Sender gas percent must be between 0 and 100, got %d", cfg.SenderGasPercent)
+	}
+
+	if price := ctx.GlobalString(MinGasPriceFlag.Name); price != "" {
+		minGasPrice, ok := new(big.Int).SetString(price, 10)
+		if !ok || minGasPrice.Sign() < 0 {
+			ethUtils.Fatalf("Invalid minimum gas price: %v", price)
+		}
+		cfg.MinGasPrice = minGasPrice
+	}
+
+	if price := ctx.GlobalString(MaxGasPriceFlag.Name); price != "" {
+		maxGasPrice, ok := new(big.Int).SetString(price, 10)
+		if !ok || maxGasPrice.Sign() < 0 {
+			ethUtils.Fatalf("Invalid maximum gas price: %v", price)
+		}
+		cfg.MaxGasPrice = maxGasPrice
+	}
+
+	if price := ctx.GlobalString(GasPriceFloorFlag.Name); price != "" {
+		minGasPrice, ok := new(big.Int).SetString(price, 10)
+		if !ok || minGasPrice.Sign() < 0 {
+			ethUtils.Fatalf("Invalid gas price floor: %v", price)
+		}
+		cfg.GasPriceFloor = &ethereum.GasPriceFloorConfig{
+			Min:    minGasPrice,
+			Step:   ctx.GlobalUint64(GasPriceFloorStepFlag.Name),
+			Window: ctx.GlobalUint64(GasPriceFloorWindowFlag.Name),
+		}
+	}
+
+	cfg.GasPriceOracle = &ethereum.GasPriceOracleConfig{
+		Blocks:     ctx.GlobalUint64(GasPricePercentilesBlocksFlag.Name),
+		MinSamples: ctx.GlobalUint64(GasPricePercentilesMinSamplesFlag.Name),
+	}
+	if percentiles := ctx.GlobalString(GasPricePercentilesFlag.Name); percentiles != "" {
+		for _, value := range strings.Split(percentiles, ",") {
+			percentile, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || percentile > 100 {
+				ethUtils.Fatalf("Gas price percentiles must be between 0 and 100, got %v", value)
+			}
+			cfg.GasPriceOracle.Percentiles = append(cfg.GasPriceOracle.Percentiles, percentile)
+		}
+	}
+	if price := ctx.GlobalString(GasPricePercentilesMinFlag.Name); price != "" {
+		minGasPrice, ok := new(big.Int).SetString(price, 10)
+		if !ok || minGasPrice.Sign() < 0 {
+			ethUtils.Fatalf("Invalid minimum suggested gas price: %v", price)
+		}
+		cfg.GasPriceOracle.Min = minGasPrice
+	}
+
+	if step := ctx.GlobalString(GasPriceGranularityFlag.Name); step != "" {
+		granularity, ok := new(big.Int).SetString(step, 10)
+		if !ok || granularity.Sign() <= 0 {
+			ethUtils.Fatalf("Invalid gas price granularity: %v", step)
+		}
+		cfg.GasPriceGranularity = granularity
+	}
+
+	cfg.MaxFeeValueMultiple = ctx.GlobalUint64(MaxFeeValueMultipleFlag.Name)
+
+	cfg.RequireReplayProtection = ctx.GlobalBool(RequireReplayProtectionFlag.Name)
+
+	cfg.MinAccountAge = ctx.GlobalUint64(MinAccountAgeFlag.Name)
+	if value := ctx.GlobalString(YoungAccountBalanceFlag.Name); value != "" {
+		balance, ok := new(big.Int).SetString(value, 10)
+		if !ok || balance.Sign() < 0 {
+			ethUtils.Fatalf("Invalid young account balance: %v", value)
+		}
+		cfg.YoungAccountBalance = balance
+	}
+
+	cfg.StateAccountLimit = ctx.GlobalUint64(StateAccountLimitFlag.Name)
+
+	cfg.BlockBatchSize = ctx.GlobalUint64(BlockBatchSizeFlag.Name)
+	cfg.MinBlockTransactions = ctx.GlobalUint64(MinBlockTxsFlag.Name)
+	cfg.MaxBlockWait = ctx.GlobalUint64(MaxBlockWaitFlag.Name)
+
+	cfg.ReorderTxs = ctx.GlobalBool(ReorderTxsFlag.Name)
+	cfg.CanonicalTxOrder = ctx.GlobalBool(CanonicalTxOrderFlag.Name)
+
+	cfg.SimulateCheckTx = ctx.GlobalBool(SimulateCheckTxFlag.Name)
+
+	cfg.CallCacheSize = ctx.GlobalUint64(CallCacheSizeFlag.Name)
+	cfg.CallCacheTTL = ctx.GlobalDuration(CallCacheTTLFlag.Name)
+
+	cfg.MaxStateCopies = ctx.GlobalUint64(MaxStateCopiesFlag.Name)
+	cfg.StateCopyWait = ctx.GlobalDuration(StateCopyWaitFlag.Name)
+
+	if checkpoints := ctx.GlobalString(StateCheckpointsFlag.Name); checkpoints != "" {
+		cfg.StateCheckpoints = make(map[uint64]common.Hash)
+		for _, pair := range strings.Split(checkpoints, ",") {
+			parts := strings.Split(pair, "=")
+			if len(parts) != 2 {
+				ethUtils.Fatalf("Invalid state checkpoint: %v", pair)
+			}
+			number, err := strconv.ParseUint(parts[0], 10, 64)
+			if err != nil {
+				ethUtils.Fatalf("Invalid state checkpoint block number: %v", parts[0])
+			}
+			root, err := hexutil.Decode(parts[1])
+			if err != nil || len(root) != common.HashLength {
+				ethUtils.Fatalf("Invalid state checkpoint root: %v", parts[1])
+			}
+			cfg.StateCheckpoints[number] = common.BytesToHash(root)
+		}
+	}
+
+	cfg.VerifyCommittedRoot = ctx.GlobalBool(VerifyCommittedRootFlag.Name)
+	cfg.AccountTxIndex = ctx.GlobalBool(AccountTxIndexFlag.Name)
+	cfg.ExecutionIndex = ctx.GlobalBool(ExecutionIndexFlag.Name)
+
+	if urls := ctx.GlobalString(WebhookURLsFlag.Name); urls != "" {
+		cfg.Webhooks = &ethereum.WebhookConfig{
+			URLs:      strings.Split(urls, ","),
+			QueueSize: ctx.GlobalInt(WebhookQueueSizeFlag.Name),
+			Retries:   ctx.GlobalInt(WebhookRetriesFlag.Name),
+			Backoff:   ctx.GlobalDuration(WebhookBackoffFlag.Name),
+		}
+	}
+
+	return cfg
+}
+
+// parseAddressPercents parses comma separated address=percent pairs
+func parseAddressPercents(value, name string) map[common.Address]uint64 {
+	percents := make(map[common.Address]uint64)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.Split(pair, "=")
+		if len(parts) != 2 || !common.IsHexAddress(parts[0]) {
+			ethUtils.Fatalf("Invalid %s: %v", name, pair)
+		}
+		percent, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || percent > 100 {
+			ethUtils.Fatalf("The %s percent must be between 0 and 100, got %v", name, parts[1])
+		}
+		percents[common.HexToAddress(parts[0])] = percent
+	}
+	return percents
+}
+
+// parseGasLimit parses the gas limit of the flag, nil if it is not set
+func parseGasLimit(ctx *cli.Context, name string) *big.Int {
+	value := ctx.GlobalString(name)
+	if value == "" {
+		return nil
+	}
+	limit, ok := new(big.Int).SetString(value, 10)
+	if !ok || limit.Cmp(params.MinGasLimit) < 0 {
+		ethUtils.Fatalf("Invalid %s, must be a gas limit of at least %v: %v", name, params.MinGasLimit, value)
+	}
+	return limit
+}
+
 func DefaultNodeConfig() node.Config {
 	cfg := node.DefaultConfig
 	cfg.Name = clientIdentifier
-	cfg.HTTPModules = append(cfg.HTTPModules, "eth")
-	cfg.WSModules = append(cfg.WSModules, "eth")
+	cfg.HTTPModules = append(cfg.HTTPModules, "eth", "ethermint")
+	cfg.WSModules = append(cfg.WSModules, "eth", "ethermint")
 	cfg.IPCPath = "geth.ipc"
 	return cfg
 }
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"time"
+
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -26,6 +28,12 @@ var (
 		Usage: "socket | grpc",
 	}
 
+	MetricsAddrFlag = cli.StringFlag{
+		Name:  "metrics_laddr",
+		Value: "",
+		Usage: "Address the prometheus metrics are served on at /metrics, e.g. 0.0.0.0:46660. Empty disables the metrics server.",
+	}
+
 	VerbosityFlag = cli.IntFlag{
 		Name:  "verbosity",
 		Value: 3,
@@ -36,4 +44,336 @@ var (
 		Name:  "config",
 		Usage: "TOML configuration file",
 	}
+
+	// ----------------------------
+	// Block processing Flags
+
+	TreasuryAddrFlag = cli.StringFlag{
+		Name:  "treasury_addr",
+		Value: "",
+		Usage: "Address that receives the treasury share of the transaction fees.",
+	}
+
+	FeeOnlyRewardsFlag = cli.BoolFlag{
+		Name:  "fee_only_rewards",
+		Usage: "Reward validators with the transaction fees only and mint no block rewards",
+	}
+
+	FallbackCoinbaseFlag = cli.StringFlag{
+		Name:  "fallback_coinbase",
+		Value: "",
+		Usage: "Address that receives the block rewards if the proposer has no ethereum address.",
+	}
+
+	TreasuryFeePercentFlag = cli.Uint64Flag{
+		Name:  "treasury_fee_percent",
+		Value: 0,
+		Usage: "Percentage [0-100] of every block's transaction fees that is skimmed to the treasury.",
+	}
+
+	RefundTreasuryPercentFlag = cli.Uint64Flag{
+		Name:  "refund_treasury_percent",
+		Value: 0,
+		Usage: "Percentage [0-100] of every gas refund that goes to the treasury instead of the sender",
+	}
+
+	GasSubsidiesFlag = cli.StringFlag{
+		Name:  "gas_subsidies",
+		Usage: "Comma separated address=percent pairs, paying the percentage of the fee of transactions to the address from the subsidy pool",
+	}
+
+	SubsidyPoolFlag = cli.StringFlag{
+		Name:  "subsidy_pool",
+		Usage: "Account paying the gas subsidies",
+	}
+
+	FailureRefundsFlag = cli.StringFlag{
+		Name:  "failure_refunds",
+		Usage: "Comma separated address=percent pairs, refunding the percentage of the fee of failed transactions to the address",
+	}
+
+	GasLimitPIDTargetFlag = cli.Int64Flag{
+		Name:  "gaslimit_pid_target",
+		Value: 0,
+		Usage: "Target block utilization in per mille for the PID gas limit controller. 0 disables the controller.",
+	}
+
+	GasLimitPIDKpFlag = cli.Int64Flag{
+		Name:  "gaslimit_pid_kp",
+		Value: 500,
+		Usage: "Proportional gain of the PID gas limit controller, in thousandths",
+	}
+
+	GasLimitPIDKiFlag = cli.Int64Flag{
+		Name:  "gaslimit_pid_ki",
+		Value: 100,
+		Usage: "Integral gain of the PID gas limit controller, in thousandths",
+	}
+
+	GasLimitPIDKdFlag = cli.Int64Flag{
+		Name:  "gaslimit_pid_kd",
+		Value: 0,
+		Usage: "Derivative gain of the PID gas limit controller, in thousandths",
+	}
+
+	GasLimitPIDWindowFlag = cli.Uint64Flag{
+		Name:  "gaslimit_pid_window",
+		Value: 16,
+		Usage: "Number of recent blocks integrated by the PID gas limit controller",
+	}
+
+	GasLimitBumpThresholdFlag = cli.Int64Flag{
+		Name:  "gaslimit_bump_threshold",
+		Value: 0,
+		Usage: "Utilization in per mille from which a block counts as congested for the gas limit bump. 0 disables the bump.",
+	}
+
+	GasLimitBumpBlocksFlag = cli.Uint64Flag{
+		Name:  "gaslimit_bump_blocks",
+		Value: 4,
+		Usage: "Number of consecutive congested blocks that raise the gas limit",
+	}
+
+	GasLimitBumpStepFlag = cli.StringFlag{
+		Name:  "gaslimit_bump_step",
+		Value: "",
+		Usage: "Gas added to the gas limit after sustained congestion",
+	}
+
+	GasLimitBumpCeilingFlag = cli.StringFlag{
+		Name:  "gaslimit_bump_ceiling",
+		Value: "",
+		Usage: "Highest gas limit reached by the gas limit bump",
+	}
+
+	GasLimitFixedFlag = cli.StringFlag{
+		Name:  "gaslimit_fixed",
+		Value: "",
+		Usage: "Gas limit targeted by every block, replacing the adjustment to the gas usage (consensus setting)",
+	}
+
+	GasLimitMinFlag = cli.StringFlag{
+		Name:  "gaslimit_min",
+		Value: "",
+		Usage: "Lowest gas limit the adjustment of the gas limit reaches (consensus setting)",
+	}
+
+	GasLimitMaxFlag = cli.StringFlag{
+		Name:  "gaslimit_max",
+		Value: "",
+		Usage: "Highest gas limit the adjustment of the gas limit reaches (consensus setting)",
+	}
+
+	UtilizationAlertFlag = cli.Int64Flag{
+		Name:  "utilization_alert",
+		Value: 0,
+		Usage: "Warn when blocks use at least this per mille of the gas limit for utilization_alert_window blocks. 0 disables the alert.",
+	}
+
+	UtilizationAlertWindowFlag = cli.Uint64Flag{
+		Name:  "utilization_alert_window",
+		Value: 16,
+		Usage: "Number of consecutive blocks above utilization_alert that raise an alert",
+	}
+
+	CoinbaseMaturityFlag = cli.Uint64Flag{
+		Name:  "coinbase_maturity",
+		Value: 0,
+		Usage: "Number of blocks before a minted block reward can be spent by the coinbase",
+	}
+
+	SenderGasPercentFlag = cli.Uint64Flag{
+		Name:  "sender_gas_percent",
+		Value: 0,
+		Usage: "Maximum percentage [0-100] of the block gas limit a single sender may use. 0 disables the limit.",
+	}
+
+	MinGasPriceFlag = cli.StringFlag{
+		Name:  "min_gasprice",
+		Value: "",
+		Usage: "Reject transactions with a lower gas price (wei) in CheckTx. Empty accepts all gas prices.",
+	}
+
+	MaxGasPriceFlag = cli.StringFlag{
+		Name:  "max_gasprice",
+		Value: "",
+		Usage: "Reject transactions with a higher gas price (wei) in CheckTx. Empty disables the cap.",
+	}
+
+	GasPriceFloorFlag = cli.StringFlag{
+		Name:  "gasprice_floor",
+		Value: "",
+		Usage: "Minimum gas price (wei) accepted in CheckTx, rising after full blocks. Empty disables the floor.",
+	}
+
+	GasPriceFloorStepFlag = cli.Uint64Flag{
+		Name:  "gasprice_floor_step",
+		Value: 10,
+		Usage: "Percentage the gas price floor moves per full or empty block",
+	}
+
+	GasPriceFloorWindowFlag = cli.Uint64Flag{
+		Name:  "gasprice_floor_window",
+		Value: 16,
+		Usage: "Number of recent blocks the gas price floor is computed from",
+	}
+
+	GasPricePercentilesFlag = cli.StringFlag{
+		Name:  "gasprice_percentiles",
+		Value: "10,50,90",
+		Usage: "Comma separated percentiles of the recent gas prices suggested by ethermint_gasPricePercentiles",
+	}
+
+	GasPricePercentilesBlocksFlag = cli.Uint64Flag{
+		Name:  "gasprice_percentiles_blocks",
+		Value: 20,
+		Usage: "Number of recent blocks whose lowest gas price is sampled for the percentiles",
+	}
+
+	GasPricePercentilesMinSamplesFlag = cli.Uint64Flag{
+		Name:  "gasprice_percentiles_min_samples",
+		Value: 1,
+		Usage: "Number of sampled blocks with transactions below which all percentiles are the minimum",
+	}
+
+	GasPricePercentilesMinFlag = cli.StringFlag{
+		Name:  "gasprice_percentiles_min",
+		Value: "",
+		Usage: "Lowest gas price (wei) suggested at any percentile. Empty is 0.",
+	}
+
+	GasPriceGranularityFlag = cli.StringFlag{
+		Name:  "gasprice_granularity",
+		Value: "",
+		Usage: "Reject transactions whose gas price (wei) is not a multiple of this value in CheckTx. Empty disables the check.",
+	}
+
+	MaxFeeValueMultipleFlag = cli.Uint64Flag{
+		Name:  "max_fee_value_multiple",
+		Value: 0,
+		Usage: "Reject value transfers whose maximum fee exceeds this multiple of the value in CheckTx. 0 disables the check.",
+	}
+
+	MinAccountAgeFlag = cli.Uint64Flag{
+		Name:  "min_account_age",
+		Value: 0,
+		Usage: "Number of blocks before a new account may send transactions, unless it holds young_account_balance. 0 disables the check.",
+	}
+
+	YoungAccountBalanceFlag = cli.StringFlag{
+		Name:  "young_account_balance",
+		Value: "",
+		Usage: "Balance (wei) from which accounts younger than min_account_age may send transactions",
+	}
+
+	StateAccountLimitFlag = cli.Uint64Flag{
+		Name:  "state_account_limit",
+		Value: 0,
+		Usage: "Number of state accounts after which the mempool of this node only accepts plain transfers. Blocks are not checked. 0 disables the limit.",
+	}
+
+	BlockBatchSizeFlag = cli.Uint64Flag{
+		Name:  "block_batch_size",
+		Value: 1,
+		Usage: "Number of tendermint heights batched into one ethereum block",
+	}
+
+	MinBlockTxsFlag = cli.Uint64Flag{
+		Name:  "min_block_txs",
+		Usage: "Hold non-empty ethereum blocks open until they have this many transactions",
+	}
+
+	MaxBlockWaitFlag = cli.Uint64Flag{
+		Name:  "max_block_wait",
+		Usage: "Maximum number of tendermint heights a block is held for min_block_txs (0 = no limit)",
+	}
+
+	RequireReplayProtectionFlag = cli.BoolFlag{
+		Name:  "require_replay_protection",
+		Usage: "Reject transactions without an EIP155 chain id once EIP155 is active",
+	}
+
+	ReorderTxsFlag = cli.BoolFlag{
+		Name:  "reorder_txs",
+		Usage: "Apply the transactions of every height sorted by nonce per sender at its end, DeliverTx then returns no execution results (consensus setting)",
+	}
+
+	CanonicalTxOrderFlag = cli.BoolFlag{
+		Name:  "canonical_tx_order",
+		Usage: "With reorder_txs, sort the transactions of every height by sender, nonce and hash (consensus setting)",
+	}
+
+	SimulateCheckTxFlag = cli.BoolFlag{
+		Name:  "simulate_checktx",
+		Usage: "Execute transactions against the pending state in CheckTx and reject those that would fail",
+	}
+
+	CallCacheSizeFlag = cli.Uint64Flag{
+		Name:  "call_cache_size",
+		Value: 0,
+		Usage: "Number of eth_call query results cached for the latest block. 0 disables the cache.",
+	}
+
+	CallCacheTTLFlag = cli.DurationFlag{
+		Name:  "call_cache_ttl",
+		Value: 0,
+		Usage: "Maximum age of a cached eth_call query result. 0 keeps results until the next block.",
+	}
+
+	MaxStateCopiesFlag = cli.Uint64Flag{
+		Name:  "max_state_copies",
+		Value: 0,
+		Usage: "Maximum number of pending state copies held by simulations and pending queries at once. 0 is unlimited.",
+	}
+
+	StateCopyWaitFlag = cli.DurationFlag{
+		Name:  "state_copy_wait",
+		Value: 0,
+		Usage: "How long a simulation waits for a free state copy before it is rejected as busy",
+	}
+
+	StateCheckpointsFlag = cli.StringFlag{
+		Name:  "state_checkpoints",
+		Value: "",
+		Usage: "Comma separated number=root pairs of trusted state roots. Block processing halts at a block whose state root differs.",
+	}
+
+	VerifyCommittedRootFlag = cli.BoolFlag{
+		Name:  "verify_committed_root",
+		Usage: "Reload the state after every commit and halt if its root differs from the committed block",
+	}
+
+	AccountTxIndexFlag = cli.BoolFlag{
+		Name:  "account_tx_index",
+		Usage: "Index the committed transactions by sender and recipient for account history queries",
+	}
+
+	ExecutionIndexFlag = cli.BoolFlag{
+		Name:  "execution_index",
+		Usage: "Index the contracts destroyed and the storage slots changed by the committed transactions",
+	}
+
+	WebhookURLsFlag = cli.StringFlag{
+		Name:  "webhook_urls",
+		Value: "",
+		Usage: "Comma separated URLs the result of every committed transaction is posted to as JSON",
+	}
+
+	WebhookQueueSizeFlag = cli.IntFlag{
+		Name:  "webhook_queue_size",
+		Value: 1024,
+		Usage: "Number of transaction results buffered per webhook URL before results are dropped",
+	}
+
+	WebhookRetriesFlag = cli.IntFlag{
+		Name:  "webhook_retries",
+		Value: 3,
+		Usage: "Number of times a failed webhook post is retried",
+	}
+
+	WebhookBackoffFlag = cli.DurationFlag{
+		Name:  "webhook_backoff",
+		Value: time.Second,
+		Usage: "Wait before the first webhook retry, doubled with every further retry",
+	}
 )
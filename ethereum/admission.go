@@ -0,0 +1,262 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	errSenderGasBudget = errors.New("sender gas budget of the block exhausted")
+	errWrongChainID    = errors.New("transaction signed for another chain")
+	errUnprotectedTx   = errors.New("transaction without replay protection")
+
+	// ErrBlockFull rejects transactions whose gas limit exceeds the gas left in the
+	// block. They are valid and can be delivered again in a later block.
+	ErrBlockFull = errors.New("block is full")
+
+	// ErrYoungAccount rejects transactions of senders created less than
+	// MinAccountAge blocks ago
+	ErrYoungAccount = errors.New("sender account too young")
+
+	// ErrStateSizeLimit rejects all but plain transfers from the mempool once the
+	// state holds StateAccountLimit accounts. Blocks are not checked against it.
+	ErrStateSizeLimit = errors.New("state size limit reached, only plain transfers are accepted")
+)
+
+//----------------------------------------------------------------------
+// Admission checks run by deliverTx before a transaction is applied.
+// A rejected transaction does not touch the work and can be retried in a later block.
+
+// TxRejectedError is returned by DeliverTx for a transaction that is invalid
+// against the pending state or not admitted to the block. Other errors of
+// DeliverTx are failures of the node.
+type TxRejectedError struct {
+	Err error
+}
+
+func (e *TxRejectedError) Error() string {
+	return e.Err.Error()
+}
+
+// CheckTxError is a transaction failing the checks against the check state.
+// Err is one of core.ErrInvalidSender, core.ErrNonce, core.ErrInsufficientFunds,
+// core.ErrIntrinsicGas, ErrYoungAccount and ErrStateSizeLimit, Log has the details.
+type CheckTxError struct {
+	Err error
+	Log string
+}
+
+func (e *CheckTxError) Error() string {
+	if e.Log == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Log
+}
+
+// resetCheckState copies the state of a new work for checkTx. It must be called
+// with the pending lock held.
+func (p *pending) resetCheckState() {
+	checkState := p.work.state.Copy()
+	var checkAgeState *state.StateDB
+	if p.work.ageState != nil {
+		checkAgeState = p.work.ageState.Copy()
+	}
+	checkAccounts := p.work.stateSize.Accounts
+
+	p.checkMtx.Lock()
+	defer p.checkMtx.Unlock()
+
+	p.checkState = checkState
+	p.checkAgeState = checkAgeState
+	p.checkAccounts = checkAccounts
+}
+
+// checkTx validates the transaction against the check state only. Transactions
+// with future nonces pass, they may follow others in the mempool.
+func (p *pending) checkTx(from common.Address, tx *ethTypes.Transaction) error {
+	p.checkMtx.Lock()
+	defer p.checkMtx.Unlock()
+
+	// non existent accounts haven't got funds and would never pass
+	if !p.checkState.Exist(from) {
+		return &CheckTxError{Err: core.ErrInvalidSender}
+	}
+	if nonce := p.checkState.GetNonce(from); nonce > tx.Nonce() {
+		return &CheckTxError{core.ErrNonce, fmt.Sprintf("Got: %d, Current: %d", tx.Nonce(), nonce)}
+	}
+	// cost == V + GP * GL
+	if balance := p.checkState.GetBalance(from); balance.Cmp(tx.Cost()) < 0 {
+		return &CheckTxError{core.ErrInsufficientFunds, fmt.Sprintf("Current balance: %s, tx cost: %s", balance, tx.Cost())}
+	}
+	if tx.Gas().Cmp(core.IntrinsicGas(tx.Data(), tx.To() == nil, true)) < 0 { // homestead == true
+		return &CheckTxError{Err: core.ErrIntrinsicGas}
+	}
+	if isYoungAccount(p.config, p.checkAgeState, p.checkState, from) {
+		return &CheckTxError{ErrYoungAccount, fmt.Sprintf("created less than %d blocks ago", p.config.MinAccountAge)}
+	}
+	return p.checkStateGrowth(tx)
+}
+
+// admitTx runs the ethermint specific admission checks in order
+func (w *work) admitTx(config *Config, from common.Address, tx *ethTypes.Transaction) error {
+	if err := w.checkMaturity(from, tx); err != nil {
+		return err
+	}
+	if err := w.checkSenderGasBudget(config, from, tx); err != nil {
+		return err
+	}
+	if isYoungAccount(config, w.ageState, w.state, from) {
+		return fmt.Errorf("%v: created less than %d blocks ago", ErrYoungAccount, config.MinAccountAge)
+	}
+	return nil
+}
+
+// checkReplayProtection rejects transactions signed for another chain once EIP155
+// is active at the given block, and transactions without a chain id if the config
+// requires replay protection
+func checkReplayProtection(config *Config, chainConfig *params.ChainConfig, number *big.Int, tx *ethTypes.Transaction) error {
+	if !chainConfig.IsEIP155(number) {
+		return nil
+	}
+	if !tx.Protected() {
+		if config.RequireReplayProtection {
+			return errUnprotectedTx
+		}
+		return nil
+	}
+	if tx.ChainId().Cmp(chainConfig.ChainId) != 0 {
+		return fmt.Errorf("%v: chain id %v, expected %v", errWrongChainID, tx.ChainId(), chainConfig.ChainId)
+	}
+	return nil
+}
+
+// CheckReplayProtection checks the chain id of a transaction for the next block
+func (b *Backend) CheckReplayProtection(tx *ethTypes.Transaction) error {
+	next := new(big.Int).Add(b.ethereum.BlockChain().CurrentBlock().Number(), big.NewInt(1))
+	return checkReplayProtection(b.emtConfig, b.ChainConfig(), next, tx)
+}
+
+// checkTransaction repeats the checks of the state transition that make
+// core.ApplyTransaction fail without including the transaction, so the
+// errors left to ApplyTransaction come from the node, see rejectedExecution.
+//
+// There is no base fee to check the gas price against: the headers of
+// go-ethereum 1.6.1 carry none and it knows only legacy transactions, so a gas
+// price floor is enforced in CheckTx by GasPriceFloor instead.
+func (w *work) checkTransaction(from common.Address, tx *ethTypes.Transaction) error {
+	if nonce := w.state.GetNonce(from); nonce != tx.Nonce() {
+		return fmt.Errorf("%v: got %d, current %d", core.ErrNonce, tx.Nonce(), nonce)
+	}
+	if (*big.Int)(w.gp).Cmp(tx.Gas()) < 0 {
+		return ErrBlockFull
+	}
+	if tx.Gas().Cmp(core.IntrinsicGas(tx.Data(), tx.To() == nil, true)) < 0 { // homestead == true
+		return core.ErrIntrinsicGas
+	}
+	if w.state.GetBalance(from).Cmp(tx.Cost()) < 0 {
+		return core.ErrInsufficientFunds
+	}
+	return nil
+}
+
+// rejectedExecution returns the admission error for an error of
+// ApplyTransaction that is due to the transaction rather than the node, or nil.
+// These are the gas pool of the block not holding the gas of the transaction,
+// which is reported as ErrBlockFull, a nonce that does not follow the one of the
+// sender, and a sender that can't transfer the value, which the state transition
+// returns instead of failing the execution. The state transition of go-ethereum
+// 1.6.1 formats its nonce errors, so they are matched by message.
+func rejectedExecution(err error) error {
+	switch {
+	case err == core.ErrGasLimitReached:
+		return ErrBlockFull
+	case err == vm.ErrInsufficientBalance:
+		return core.ErrInsufficientFunds
+	case strings.Contains(strings.ToLower(err.Error()), "nonce"):
+		return err
+	}
+	return nil
+}
+
+// checkSenderGasBudget defers transactions whose gas limit does not fit in
+// the share of the block gas limit the sender has left
+func (w *work) checkSenderGasBudget(config *Config, from common.Address, tx *ethTypes.Transaction) error {
+	if config.SenderGasPercent == 0 {
+		return nil
+	}
+
+	budget := new(big.Int).SetUint64(config.SenderGasPercent)
+	budget.Mul(budget, w.header.GasLimit)
+	budget.Div(budget, big.NewInt(100))
+
+	used, ok := w.senderGas[from]
+	if !ok {
+		used = new(big.Int)
+	}
+	if new(big.Int).Add(used, tx.Gas()).Cmp(budget) > 0 {
+		return fmt.Errorf("%v: used %v, tx gas %v, budget %v", errSenderGasBudget, used, tx.Gas(), budget)
+	}
+	return nil
+}
+
+// chargeSenderGas accounts the gas used by a delivered transaction to its sender
+func (w *work) chargeSenderGas(from common.Address, gasUsed *big.Int) {
+	if used, ok := w.senderGas[from]; ok {
+		used.Add(used, gasUsed)
+	} else {
+		w.senderGas[from] = new(big.Int).Set(gasUsed)
+	}
+}
+
+// accountAgeState returns the committed state MinAccountAge blocks before the
+// block with the given number, or the genesis state for the first blocks. The
+// accounts in it are established. nil if the check is disabled.
+func accountAgeState(blockchain *core.BlockChain, config *Config, number uint64) (*state.StateDB, error) {
+	if config.MinAccountAge == 0 {
+		return nil, nil
+	}
+	var established uint64
+	if number > config.MinAccountAge {
+		established = number - config.MinAccountAge
+	}
+	block := blockchain.GetBlockByNumber(established)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	return blockchain.StateAt(block.Root())
+}
+
+// isYoungAccount reports whether the sender did not exist in the state of
+// accountAgeState and holds less than YoungAccountBalance. Both states are
+// consensus data, so every validator decides alike.
+func isYoungAccount(config *Config, ageState, statedb *state.StateDB, from common.Address) bool {
+	if ageState == nil || ageState.Exist(from) {
+		return false
+	}
+	balance := config.YoungAccountBalance
+	return balance == nil || statedb.GetBalance(from).Cmp(balance) < 0
+}
+
+// checkStateGrowth keeps all but plain value transfers to accounts without code
+// out of the mempool once the state holds StateAccountLimit accounts. The size
+// of the state is tracked approximately and best-effort, so unlike the account
+// age, which is checked again when delivering, it is a mempool policy only. It
+// must be called with the check lock held.
+func (p *pending) checkStateGrowth(tx *ethTypes.Transaction) error {
+	if p.config.StateAccountLimit == 0 || p.checkAccounts < p.config.StateAccountLimit {
+		return nil
+	}
+	if to := tx.To(); to != nil && len(tx.Data()) == 0 && p.checkState.GetCodeSize(*to) == 0 {
+		return nil
+	}
+	return &CheckTxError{ErrStateSizeLimit, fmt.Sprintf("%d accounts", p.checkAccounts)}
+}
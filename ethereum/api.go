@@ -1,9 +1,17 @@
 package ethereum
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"reflect"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // We must implement our own net service since we don't have access to `internal/ethapi`
@@ -31,3 +39,355 @@ func (n *NetRPCService) PeerCount() hexutil.Uint {
 func (n *NetRPCService) Version() string {
 	return fmt.Sprintf("%d", n.networkVersion)
 }
+
+//----------------------------------------------------------------------
+// PendingCallRPCService takes eth_call and eth_estimateGas over from the eth
+// namespace of go-ethereum, to hold a state copy slot while they run on a copy
+// of the pending state. The API types of go-ethereum are internal too, so the
+// arguments are decoded into the types of its methods, which are called by
+// reflection.
+
+type PendingCallRPCService struct {
+	backend     *Backend
+	call        reflect.Value
+	estimateGas reflect.Value
+}
+
+// NewPendingCallRPCService wraps the Call and EstimateGas methods of the
+// go-ethereum service, or returns nil if it lacks one of them.
+func NewPendingCallRPCService(backend *Backend, service interface{}) *PendingCallRPCService {
+	methods := reflect.ValueOf(service)
+	call, estimateGas := methods.MethodByName("Call"), methods.MethodByName("EstimateGas")
+	if !call.IsValid() || !estimateGas.IsValid() {
+		return nil
+	}
+	return &PendingCallRPCService{backend, call, estimateGas}
+}
+
+// Call executes a message on the state of the given block without changing it.
+// A call on the pending block holds a state copy slot.
+func (s *PendingCallRPCService) Call(args json.RawMessage, number rpc.BlockNumber) (interface{}, error) {
+	if number == rpc.PendingBlockNumber {
+		release, err := s.backend.AcquireStateCopy()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	return callWithArgs(s.call, args, reflect.ValueOf(number))
+}
+
+// EstimateGas returns the gas the message needs on the pending state. It holds
+// a state copy slot.
+func (s *PendingCallRPCService) EstimateGas(args json.RawMessage) (interface{}, error) {
+	release, err := s.backend.AcquireStateCopy()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return callWithArgs(s.estimateGas, args)
+}
+
+// callWithArgs calls a go-ethereum API method taking a context, the call
+// arguments and the given values, with args decoded into the arguments type
+func callWithArgs(method reflect.Value, args json.RawMessage, in ...reflect.Value) (interface{}, error) {
+	decoded := reflect.New(method.Type().In(1))
+	if err := json.Unmarshal(args, decoded.Interface()); err != nil {
+		return nil, err
+	}
+	in = append([]reflect.Value{reflect.ValueOf(context.Background()), decoded.Elem()}, in...)
+	out := method.Call(in)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return out[0].Interface(), nil
+}
+
+//----------------------------------------------------------------------
+// EthermintRPCService exposes ethermint specific queries over the
+// committed chain under the "ethermint" namespace
+
+type EthermintRPCService struct {
+	backend *Backend
+}
+
+// NewEthermintRPCService creates a new ethermint API instance.
+func NewEthermintRPCService(backend *Backend) *EthermintRPCService {
+	return &EthermintRPCService{backend}
+}
+
+// HasAddressActivity reports whether the address sent or received a transaction,
+// or emitted a log, in the given block.
+func (e *EthermintRPCService) HasAddressActivity(addr common.Address, number hexutil.Uint64) (bool, error) {
+	return e.backend.HasAddressActivity(addr, uint64(number))
+}
+
+// BlockAddresses returns the distinct addresses that appeared as sender,
+// recipient, created contract or log emitter in the given block.
+func (e *EthermintRPCService) BlockAddresses(number hexutil.Uint64) ([]common.Address, error) {
+	return e.backend.BlockAddresses(uint64(number))
+}
+
+// BlockStats returns the gas, fee, transaction, sender and contract creation totals
+// of the given block.
+func (e *EthermintRPCService) BlockStats(number hexutil.Uint64) (*BlockStats, error) {
+	return e.backend.BlockStats(uint64(number))
+}
+
+// BlockSize returns the size in bytes of the rlp encoding of the given block and
+// the average size of its transactions.
+func (e *EthermintRPCService) BlockSize(number hexutil.Uint64) (*BlockSize, error) {
+	return e.backend.BlockSize(uint64(number))
+}
+
+// StateGrowth returns the accounts added and removed by the given block and
+// the resulting change of the code size.
+func (e *EthermintRPCService) StateGrowth(number hexutil.Uint64) (*StateGrowth, error) {
+	return e.backend.StateGrowth(uint64(number))
+}
+
+// ExecutionErrors returns the number of transactions of the given block that
+// failed, per vm error category.
+func (e *EthermintRPCService) ExecutionErrors(number hexutil.Uint64) (map[string]uint64, error) {
+	return e.backend.ExecutionErrors(uint64(number))
+}
+
+// PendingNonce returns the next nonce of the account, counting the transactions
+// delivered to the pending block but not committed yet.
+func (e *EthermintRPCService) PendingNonce(addr common.Address) hexutil.Uint64 {
+	return hexutil.Uint64(e.backend.PendingNonce(addr))
+}
+
+// PendingFees estimates the fees the pending block yields if it is committed now.
+func (e *EthermintRPCService) PendingFees() *hexutil.Big {
+	return (*hexutil.Big)(e.backend.PendingFees())
+}
+
+// GasPriceFloor returns the minimum gas price accepted for the next block, or
+// null if there is no floor.
+func (e *EthermintRPCService) GasPriceFloor() *hexutil.Big {
+	return (*hexutil.Big)(e.backend.GasPriceFloor())
+}
+
+// GasPricePercentiles returns the gas price at the configured percentiles of the
+// lowest prices paid in the recent blocks.
+func (e *EthermintRPCService) GasPricePercentiles() *GasPriceCurve {
+	return e.backend.GasPricePercentiles()
+}
+
+// PendingBlockHash returns the provisional hash of the pending block, which
+// changes with every transaction added to it.
+func (e *EthermintRPCService) PendingBlockHash() common.Hash {
+	return e.backend.PendingBlockHash()
+}
+
+// LogCounts returns the number of logs emitted in the given block per contract.
+func (e *EthermintRPCService) LogCounts(number hexutil.Uint64) (map[common.Address]uint64, error) {
+	return e.backend.LogCounts(uint64(number))
+}
+
+// TopFeeTransaction returns the transaction of the given block that paid the
+// highest gas price and its fee, or null for an empty block.
+func (e *EthermintRPCService) TopFeeTransaction(number hexutil.Uint64) (*TopFeeTransaction, error) {
+	return e.backend.TopFeeTransaction(uint64(number))
+}
+
+// SenderCounts returns the number of transactions sent per sender in the given block.
+func (e *EthermintRPCService) SenderCounts(number hexutil.Uint64) (map[common.Address]uint64, error) {
+	return e.backend.SenderCounts(uint64(number))
+}
+
+// TransactionShards assigns the transactions of the given block to the given
+// number of shards by sender and reports the transactions crossing shards.
+func (e *EthermintRPCService) TransactionShards(number, shards hexutil.Uint64) (*BlockShards, error) {
+	return e.backend.TransactionShards(uint64(number), uint64(shards))
+}
+
+// BlockTime returns the seconds between the given block and its parent, and
+// the average over the window of blocks ending at it.
+func (e *EthermintRPCService) BlockTime(number, window hexutil.Uint64) (*BlockTime, error) {
+	return e.backend.BlockTime(uint64(number), uint64(window))
+}
+
+// StateRoot returns the state root of the block with the given number.
+func (e *EthermintRPCService) StateRoot(number hexutil.Uint64) (common.Hash, error) {
+	return e.backend.StateRoot(uint64(number))
+}
+
+// StateRootByHash returns the state root of the block with the given hash.
+func (e *EthermintRPCService) StateRootByHash(hash common.Hash) (common.Hash, error) {
+	return e.backend.StateRootByHash(hash)
+}
+
+// StateSize returns the number of accounts and the code size of the latest state.
+func (e *EthermintRPCService) StateSize() *StateSize {
+	return e.backend.StateSize()
+}
+
+// SelfDestructs returns the contracts destroyed in the given block and the
+// beneficiaries of their balances, if the node runs with the execution index.
+func (e *EthermintRPCService) SelfDestructs(number hexutil.Uint64) ([]*SelfDestruct, error) {
+	return e.backend.SelfDestructs(uint64(number))
+}
+
+// TraceTransaction returns the opcodes executed by a committed transaction, in
+// a summary format next to the full debug_traceTransaction of go-ethereum.
+func (e *EthermintRPCService) TraceTransaction(txHash common.Hash) (*TransactionTrace, error) {
+	return e.backend.TraceTransaction(txHash)
+}
+
+// ContractCreation returns the transaction and block that deployed the contract.
+func (e *EthermintRPCService) ContractCreation(addr common.Address) (*ContractCreation, error) {
+	return e.backend.ContractCreation(addr)
+}
+
+// RewardHistory returns up to limit block rewards credited to the address,
+// oldest first, skipping the first offset entries.
+func (e *EthermintRPCService) RewardHistory(addr common.Address, offset, limit hexutil.Uint64) []*RewardHistoryEntry {
+	return e.backend.RewardHistory(addr, uint64(offset), uint64(limit))
+}
+
+// SentTransactions returns up to limit committed transactions sent by the
+// address, oldest first, skipping the first offset entries.
+func (e *EthermintRPCService) SentTransactions(addr common.Address, offset, limit hexutil.Uint64) []*AccountTransaction {
+	return e.backend.SentTransactions(addr, uint64(offset), uint64(limit))
+}
+
+// ReceivedTransactions returns up to limit committed transactions received by
+// the address, oldest first, skipping the first offset entries.
+func (e *EthermintRPCService) ReceivedTransactions(addr common.Address, offset, limit hexutil.Uint64) []*AccountTransaction {
+	return e.backend.ReceivedTransactions(addr, uint64(offset), uint64(limit))
+}
+
+// RewardDistribution returns the minted reward, the fees, the burned amount and
+// the share of every recipient of the given block.
+func (e *EthermintRPCService) RewardDistribution(number hexutil.Uint64) (*RewardDistribution, error) {
+	return e.backend.RewardDistribution(uint64(number))
+}
+
+// GetTransactionReceipts returns the receipts of the given transactions in the
+// same order, null for unknown transactions.
+func (e *EthermintRPCService) GetTransactionReceipts(hashes []common.Hash) []*ethTypes.Receipt {
+	return e.backend.Receipts(hashes)
+}
+
+// GasHistogram returns the number of transactions of the given block per gas used
+// bucket. bounds are the increasing upper bounds of the buckets, defaults are
+// used if empty.
+func (e *EthermintRPCService) GasHistogram(number hexutil.Uint64, bounds []hexutil.Uint64) ([]GasHistogramBucket, error) {
+	upperBounds := make([]uint64, len(bounds))
+	for i, bound := range bounds {
+		upperBounds[i] = uint64(bound)
+	}
+	return e.backend.GasHistogram(uint64(number), upperBounds)
+}
+
+// MempoolPosition estimates how many transactions go before the pending pool
+// transaction and in how many blocks it is included.
+func (e *EthermintRPCService) MempoolPosition(hash common.Hash) (*MempoolPosition, error) {
+	return e.backend.MempoolPosition(hash)
+}
+
+// InclusionProbability estimates the chance that a transfer paying the given gas
+// price is included in the next block.
+func (e *EthermintRPCService) InclusionProbability(gasPrice hexutil.Big) (*InclusionEstimate, error) {
+	return e.backend.InclusionProbability((*big.Int)(&gasPrice))
+}
+
+// UtilizationAlerts returns the blocks that completed a window of gas limit
+// utilization above the configured threshold.
+func (e *EthermintRPCService) UtilizationAlerts() []*UtilizationAlert {
+	return e.backend.UtilizationAlerts()
+}
+
+// CommitFailures returns the block number, transaction count, gas used, stage
+// and error of the latest failed commits.
+func (e *EthermintRPCService) CommitFailures() []*CommitFailure {
+	return e.backend.CommitFailures()
+}
+
+// GenesisAlloc returns the account allocations of the genesis block.
+func (e *EthermintRPCService) GenesisAlloc() (core.GenesisAlloc, error) {
+	return e.backend.GenesisAlloc()
+}
+
+// EstimateGasArgs are the arguments of a gas estimation, like those of eth_call
+type EstimateGasArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Big    `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+// EstimateGas returns the lowest gas limit with which the message succeeds on
+// the pending state. A missing gas searches up to the gas limit of the block.
+func (e *EthermintRPCService) EstimateGas(args EstimateGasArgs) (hexutil.Uint64, error) {
+	msg := ethTypes.NewMessage(args.From, args.To, 0, (*big.Int)(&args.Value), (*big.Int)(args.Gas),
+		(*big.Int)(&args.GasPrice), args.Data, false)
+	gas, err := e.backend.EstimateGas(msg)
+	return hexutil.Uint64(gas), err
+}
+
+//----------------------------------------------------------------------
+
+// DebugRPCService exposes replay based debugging of committed transactions
+// next to the go-ethereum debug namespace
+type DebugRPCService struct {
+	backend *Backend
+}
+
+// NewDebugRPCService creates a new debug API instance.
+func NewDebugRPCService(backend *Backend) *DebugRPCService {
+	return &DebugRPCService{backend}
+}
+
+// StorageChanges returns the storage slots changed by a committed transaction.
+func (d *DebugRPCService) StorageChanges(txHash common.Hash) ([]StorageChange, error) {
+	return d.backend.StorageChanges(txHash)
+}
+
+// StorageReads returns the storage slots read by a committed transaction and
+// the values it read.
+func (d *DebugRPCService) StorageReads(txHash common.Hash) ([]StorageRead, error) {
+	return d.backend.StorageReads(txHash)
+}
+
+// SlotWriter returns the latest transaction that changed the storage slot of the
+// contract in the given block or before.
+func (d *DebugRPCService) SlotWriter(addr common.Address, slot common.Hash, number hexutil.Uint64) (*SlotWrite, error) {
+	return d.backend.SlotWriter(addr, slot, uint64(number))
+}
+
+// AccessList returns the addresses and storage keys a committed transaction
+// accessed, as an EIP-2930 access list.
+func (d *DebugRPCService) AccessList(txHash common.Hash) ([]*AccessTuple, error) {
+	return d.backend.AccessList(txHash)
+}
+
+// CallGraph returns the contract to contract calls made in the given block.
+func (d *DebugRPCService) CallGraph(number hexutil.Uint64) ([]*CallEdge, error) {
+	return d.backend.CallGraph(uint64(number))
+}
+
+// VerifyChain checks that the committed blocks are contiguously numbered and
+// linked by their parent hashes, and returns the first inconsistency.
+func (d *DebugRPCService) VerifyChain() error {
+	return d.backend.VerifyChain()
+}
+
+// GetRawBlock returns the rlp encoding of the block with the given number.
+func (d *DebugRPCService) GetRawBlock(number hexutil.Uint64) (hexutil.Bytes, error) {
+	return d.backend.RawBlockByNumber(uint64(number))
+}
+
+// GetRawBlockByHash returns the rlp encoding of the block with the given hash.
+func (d *DebugRPCService) GetRawBlockByHash(hash common.Hash) (hexutil.Bytes, error) {
+	return d.backend.RawBlockByHash(hash)
+}
+
+// GetRawTransaction returns the rlp encoding of the transaction with the given hash.
+func (d *DebugRPCService) GetRawTransaction(hash common.Hash) (hexutil.Bytes, error) {
+	return d.backend.RawTransaction(hash)
+}
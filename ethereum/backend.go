@@ -7,10 +7,13 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 
 	abciTypes "github.com/tendermint/abci/types"
@@ -30,19 +33,33 @@ type Backend struct {
 	config   *eth.Config
 	ethereum *eth.Ethereum
 
+	// ethermint specific block processing settings
+	emtConfig *Config
+
 	// txBroadcastLoop subscription
 	txSub *event.TypeMuxSubscription
 
 	// pending ...
 	pending *pending
 
+	// posts the results of committed transactions. nil if webhooks are disabled
+	webhooks *webhooks
+
+	// announces the committed blocks and their logs
+	committedFeed committedFeed
+
 	// client for forwarding txs to tendermint
 	client rpcClient.HTTPClient
 }
 
 // NewBackend creates a new Backend
-func NewBackend(ctx *node.ServiceContext, config *eth.Config, client rpcClient.HTTPClient) (*Backend, error) {
-	p := newPending()
+func NewBackend(ctx *node.ServiceContext, config *eth.Config, emtConfig *Config,
+	client rpcClient.HTTPClient) (*Backend, error) {
+	p := newPending(emtConfig)
+
+	if err := restoreForkUpgrades(ctx, config); err != nil {
+		return nil, err
+	}
 
 	// eth.New takes a ServiceContext for the EventMux, the AccountManager,
 	// and some basic functions around the DataDir.
@@ -58,11 +75,29 @@ func NewBackend(ctx *node.ServiceContext, config *eth.Config, client rpcClient.H
 	// We don't need PoW/Uncle validation
 	ethereum.BlockChain().SetValidator(NullBlockProcessor{})
 
+	// the chain database only exists once the ethereum object is created
+	p.chainDb = ethereum.ChainDb()
+	if p.chainConfig, err = scheduledChainConfig(ethereum.BlockChain().Config(), p.chainDb); err != nil {
+		return nil, err
+	}
+	if config.Genesis != nil {
+		if err := WriteGenesisAlloc(p.chainDb, config.Genesis); err != nil {
+			return nil, err
+		}
+	}
+	if err := initStateSize(ethereum.BlockChain(), p.chainDb); err != nil {
+		return nil, err
+	}
+
 	ethBackend := &Backend{
-		ethereum: ethereum,
-		pending:  p,
-		client:   client,
-		config:   config,
+		ethereum:  ethereum,
+		pending:   p,
+		client:    client,
+		config:    config,
+		emtConfig: emtConfig,
+	}
+	if emtConfig.Webhooks != nil && len(emtConfig.Webhooks.URLs) > 0 {
+		ethBackend.webhooks = newWebhooks(emtConfig.Webhooks)
 	}
 	return ethBackend, nil
 }
@@ -77,29 +112,151 @@ func (b *Backend) Config() *eth.Config {
 	return b.config
 }
 
+// EthermintConfig returns the ethermint specific Config
+func (b *Backend) EthermintConfig() *Config {
+	return b.emtConfig
+}
+
+// ChainConfig returns the rules of the pending block, with the fork upgrades
+// scheduled so far. The go-ethereum services only see the upgrades after a
+// restart, see applyForkUpgrades.
+func (b *Backend) ChainConfig() *params.ChainConfig {
+	return b.pending.currentChainConfig()
+}
+
 //----------------------------------------------------------------------
 // Handle block processing
 
-func (b *Backend) DeliverTx(tx *ethTypes.Transaction) error {
-	return b.pending.deliverTx(b.ethereum.BlockChain(), b.config, b.ethereum.ApiBackend.ChainConfig(), tx)
+// DeliverTx applies the transaction to the pending block and returns its receipt.
+// Transactions that can't be included are rejected with a *TxRejectedError.
+func (b *Backend) DeliverTx(tx *ethTypes.Transaction) (*ethTypes.Receipt, error) {
+	return b.pending.deliverTx(b.ethereum.BlockChain(), b.config, tx)
+}
+
+// DeliverTxs applies the transactions to the pending block in order, holding the
+// pending block once for all of them, and returns the receipt or the error of each.
+func (b *Backend) DeliverTxs(txs []*ethTypes.Transaction) ([]*ethTypes.Receipt, []error) {
+	return b.pending.deliverTxs(b.ethereum.BlockChain(), b.config, txs)
+}
+
+// EndBlock returns the validator set changes the strategy reads from the
+// pending state
+func (b *Backend) EndBlock(strategy *emtTypes.Strategy) []*abciTypes.Validator {
+	return b.pending.endBlock(strategy)
 }
 
 func (b *Backend) AccumulateRewards(strategy *emtTypes.Strategy) {
 	b.pending.accumulateRewards(strategy)
 }
 
+// CommittedBlockEvent is sent to the subscribers of SubscribeCommittedBlocks
+// once a block is inserted into the chain
+type CommittedBlockEvent struct {
+	Block *ethTypes.Block
+	Logs  []*ethTypes.Log
+}
+
 func (b *Backend) Commit(receiver common.Address) (common.Hash, error) {
-	return b.pending.commit(b.ethereum.BlockChain(), receiver)
+	committed, err := b.pending.commit(b.ethereum.BlockChain(), receiver)
+	if committed == nil {
+		return common.Hash{}, err
+	}
+
+	// sent after the pending mutex is released, so subscribers may query the backend
+	b.committedFeed.send(*committed)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if b.webhooks != nil {
+		block := committed.Block
+		receipts := core.GetBlockReceipts(b.ethereum.ChainDb(), block.Hash(), block.NumberU64())
+		b.webhooks.blockCommitted(block, receipts)
+	}
+	return committed.Block.Hash(), nil
+}
+
+// SubscribeCommittedBlocks sends every block inserted into the chain, with its
+// logs, to the channel. Commits never wait for the subscriber: the events that
+// don't fit into the buffer of the channel are dropped, so it should be
+// buffered for the blocks the subscriber may lag behind.
+func (b *Backend) SubscribeCommittedBlocks(ch chan<- CommittedBlockEvent) event.Subscription {
+	return b.committedFeed.subscribe(ch)
 }
 
 func (b *Backend) ResetWork(receiver common.Address) error {
 	work, err := b.pending.resetWork(b.ethereum.BlockChain(), receiver)
 	b.pending.work = work
+	if err == nil {
+		b.pending.resetCheckState()
+	}
 	return err
 }
 
 func (b *Backend) UpdateHeaderWithTimeInfo(tmHeader *abciTypes.Header) {
-	b.pending.updateHeaderWithTimeInfo(b.ethereum.ApiBackend.ChainConfig(), tmHeader.Time, tmHeader.GetNumTxs())
+	b.pending.updateHeaderWithTimeInfo(tmHeader.Time)
+}
+
+// SetTracer sets a tracer that follows the execution of every transaction
+// delivered from now on, or stops tracing if nil. It runs under the lock of the
+// pending block, so it has to be fast and must not call back into the Backend.
+func (b *Backend) SetTracer(tracer vm.Tracer) {
+	b.pending.setTracer(tracer)
+}
+
+// BeginBlock prepares the pending block for the transactions of a tendermint height
+func (b *Backend) BeginBlock(tmHeader *abciTypes.Header) {
+	b.pending.beginBlock(int(tmHeader.GetNumTxs()))
+}
+
+// SetTendermintHeight records the tendermint height delivering to the pending block
+func (b *Backend) SetTendermintHeight(height uint64) {
+	b.pending.setHeight(height)
+}
+
+// PendingTxCount returns the number of transactions in the pending block
+func (b *Backend) PendingTxCount() int {
+	return b.pending.txCount()
+}
+
+// PendingNonce returns the next nonce of the account in the pending block, after
+// the transactions delivered so far
+func (b *Backend) PendingNonce(addr common.Address) uint64 {
+	return b.pending.GetNonce(addr)
+}
+
+// PendingFees estimates the fee revenue of the pending block if it was committed now
+func (b *Backend) PendingFees() *big.Int {
+	return b.pending.fees()
+}
+
+// PendingBlockHash returns the hash of the pending block if it was committed now.
+// It is provisional: every delivered transaction and the rewards of EndBlock change it.
+func (b *Backend) PendingBlockHash() common.Hash {
+	return b.pending.provisionalHash()
+}
+
+// PendingSnapshot returns a read-only view of the pending block. It is cheaper
+// than Pending for single account lookups, see PendingSnapshot for when its
+// reads go stale.
+func (b *Backend) PendingSnapshot() *PendingSnapshot {
+	return b.pending.snapshot()
+}
+
+// UtilizationAlerts returns the latest alerts on sustained gas limit utilization, oldest first
+func (b *Backend) UtilizationAlerts() []*UtilizationAlert {
+	return b.pending.utilizationAlerts()
+}
+
+// IntermediateRoot returns the root of the pending state
+func (b *Backend) IntermediateRoot() common.Hash {
+	return b.pending.intermediateRoot()
+}
+
+// CheckTx validates the nonce, the balance and the intrinsic gas of a transaction
+// from the sender against the state of the latest committed block. It returns a
+// *CheckTxError for invalid transactions and never modifies the pending block.
+func (b *Backend) CheckTx(from common.Address, tx *ethTypes.Transaction) error {
+	return b.pending.checkTx(from, tx)
 }
 
 // GasLimit returns the maximum gas per block
@@ -114,10 +271,14 @@ func (b *Backend) GasLimit() big.Int {
 func (b *Backend) APIs() []rpc.API {
 	apis := b.Ethereum().APIs()
 	retApis := []rpc.API{}
+	var pendingCalls *PendingCallRPCService
 	for _, v := range apis {
 		if v.Namespace == "net" {
 			v.Service = NewNetRPCService(b.config.NetworkId)
 		}
+		if v.Namespace == "eth" && pendingCalls == nil {
+			pendingCalls = NewPendingCallRPCService(b, v.Service)
+		}
 		if v.Namespace == "miner" {
 			continue
 		}
@@ -126,6 +287,25 @@ func (b *Backend) APIs() []rpc.API {
 		}
 		retApis = append(retApis, v)
 	}
+	retApis = append(retApis, rpc.API{
+		Namespace: "ethermint",
+		Version:   "1.0",
+		Service:   NewEthermintRPCService(b),
+		Public:    true,
+	}, rpc.API{
+		Namespace: "debug",
+		Version:   "1.0",
+		Service:   NewDebugRPCService(b),
+	})
+	// registered last, so its methods replace those of go-ethereum
+	if pendingCalls != nil {
+		retApis = append(retApis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   pendingCalls,
+			Public:    true,
+		})
+	}
 	return retApis
 }
 
@@ -140,6 +320,13 @@ func (b *Backend) Start(srvr *p2p.Server) error {
 // Ethereum protocol.
 func (b *Backend) Stop() error {
 	b.txSub.Unsubscribe()
+	// stop processing blocks before the chain is closed under the pending work
+	if err := b.pending.Close(); err != nil {
+		log.Error("Error discarding the pending block", "err", err)
+	}
+	if b.webhooks != nil {
+		b.webhooks.stop()
+	}
 	b.ethereum.Stop()
 	return nil
 }
@@ -0,0 +1,265 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	errBlockNotFound  = errors.New("block not found")
+	errInvalidBuckets = errors.New("bucket bounds must be strictly increasing")
+	errGenesisParent  = errors.New("the genesis block has no parent")
+)
+
+// defaultGasBuckets are the upper bounds used by GasHistogram if none are given
+var defaultGasBuckets = []uint64{21000, 50000, 100000, 500000, 1000000}
+
+//----------------------------------------------------------------------
+// Queries over the committed ethereum chain
+
+// HasAddressActivity reports whether addr sent or received a transaction,
+// or emitted a log, in the committed block with the given number
+func (b *Backend) HasAddressActivity(addr common.Address, number uint64) (bool, error) {
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return false, errBlockNotFound
+	}
+
+	signer := ethTypes.MakeSigner(b.ChainConfig(), block.Number())
+	for _, tx := range block.Transactions() {
+		if to := tx.To(); to != nil && *to == addr {
+			return true, nil
+		}
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			return false, err
+		}
+		if from == addr {
+			return true, nil
+		}
+	}
+
+	receipts := core.GetBlockReceipts(b.ethereum.ChainDb(), block.Hash(), number)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			if log.Address == addr {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// GasHistogramBucket counts the transactions of a block whose gas used is
+// at most UpperBound and above the bound of the previous bucket
+type GasHistogramBucket struct {
+	UpperBound uint64 `json:"upperBound"`
+	Count      uint64 `json:"count"`
+}
+
+// GasHistogram buckets the gas used by each transaction of the committed block
+// with the given number. bounds are the increasing upper bounds of the buckets,
+// a last bucket bounded by math.MaxUint64 collects the rest.
+func (b *Backend) GasHistogram(number uint64, bounds []uint64) ([]GasHistogramBucket, error) {
+	if len(bounds) == 0 {
+		bounds = defaultGasBuckets
+	}
+	buckets := make([]GasHistogramBucket, 0, len(bounds)+1)
+	for i, bound := range bounds {
+		if i > 0 && bound <= bounds[i-1] {
+			return nil, errInvalidBuckets
+		}
+		buckets = append(buckets, GasHistogramBucket{UpperBound: bound})
+	}
+	buckets = append(buckets, GasHistogramBucket{UpperBound: math.MaxUint64})
+
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	for _, receipt := range core.GetBlockReceipts(b.ethereum.ChainDb(), block.Hash(), number) {
+		gasUsed := receipt.GasUsed.Uint64()
+		i := sort.Search(len(buckets), func(i int) bool { return gasUsed <= buckets[i].UpperBound })
+		buckets[i].Count++
+	}
+	return buckets, nil
+}
+
+// TopFeeTransaction is the transaction of a block paying the highest gas price
+type TopFeeTransaction struct {
+	TxHash   common.Hash `json:"transactionHash"`
+	GasPrice *big.Int    `json:"gasPrice"`
+	GasUsed  *big.Int    `json:"gasUsed"`
+	Fee      *big.Int    `json:"fee"`
+}
+
+// TopFeeTransaction returns the transaction of the committed block with the given
+// number that paid the highest gas price, the earliest one of equal prices, or
+// nil if the block has no transactions. Without dynamic fees the effective gas
+// price of a transaction is its gas price.
+func (b *Backend) TopFeeTransaction(number uint64) (*TopFeeTransaction, error) {
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	receipts := core.GetBlockReceipts(b.ethereum.ChainDb(), block.Hash(), number)
+
+	var top *TopFeeTransaction
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) {
+			break
+		}
+		if top != nil && tx.GasPrice().Cmp(top.GasPrice) <= 0 {
+			continue
+		}
+		top = &TopFeeTransaction{
+			TxHash:   tx.Hash(),
+			GasPrice: tx.GasPrice(),
+			GasUsed:  receipts[i].GasUsed,
+			Fee:      new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()),
+		}
+	}
+	return top, nil
+}
+
+// BlockTime is the time a committed block took after its parent
+type BlockTime struct {
+	Number uint64 `json:"number"`
+	Delta  uint64 `json:"delta"` // seconds since the parent
+	// Average is the mean delta of the Window blocks up to Number, fewer near genesis
+	Average float64 `json:"average"`
+	Window  uint64  `json:"window"`
+}
+
+// BlockTime returns the time between the committed block with the given number
+// and its parent, and the average over the window of blocks ending at it. The
+// delta of block 1 is taken from the timestamp of the genesis block.
+func (b *Backend) BlockTime(number, window uint64) (*BlockTime, error) {
+	if number == 0 {
+		return nil, errGenesisParent
+	}
+	if window == 0 {
+		window = 1
+	}
+	blockchain := b.ethereum.BlockChain()
+	header := blockchain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, errBlockNotFound
+	}
+
+	blockTime := &BlockTime{Number: number}
+	var total uint64
+	for header.Number.Sign() > 0 && blockTime.Window < window {
+		parent := blockchain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return nil, errBlockNotFound
+		}
+		delta := header.Time.Uint64() - parent.Time.Uint64()
+		if blockTime.Window == 0 {
+			blockTime.Delta = delta
+		}
+		total += delta
+		blockTime.Window++
+		header = parent
+	}
+	blockTime.Average = float64(total) / float64(blockTime.Window)
+	return blockTime, nil
+}
+
+// ChainLinkError is the first inconsistency of the committed chain found by VerifyChain
+type ChainLinkError struct {
+	Number uint64
+	Reason string
+}
+
+func (e *ChainLinkError) Error() string {
+	return fmt.Sprintf("block %d: %s", e.Number, e.Reason)
+}
+
+// VerifyChain walks the committed chain from the genesis to the current block and
+// checks that the blocks are contiguously numbered and each links to its predecessor
+func (b *Backend) VerifyChain() error {
+	blockchain := b.ethereum.BlockChain()
+	parent := blockchain.GetBlockByNumber(0)
+	if parent == nil {
+		return &ChainLinkError{0, "missing genesis block"}
+	}
+
+	head := blockchain.CurrentBlock().NumberU64()
+	for number := uint64(1); number <= head; number++ {
+		block := blockchain.GetBlockByNumber(number)
+		switch {
+		case block == nil:
+			return &ChainLinkError{number, "missing block"}
+		case block.NumberU64() != number:
+			return &ChainLinkError{number, fmt.Sprintf("block is numbered %d", block.NumberU64())}
+		case block.ParentHash() != parent.Hash():
+			return &ChainLinkError{number, fmt.Sprintf("parent hash %x, previous block hash %x", block.ParentHash(), parent.Hash())}
+		}
+		parent = block
+	}
+	return nil
+}
+
+// RawBlockByNumber returns the rlp encoding of the committed block with the given number
+func (b *Backend) RawBlockByNumber(number uint64) ([]byte, error) {
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	return rlp.EncodeToBytes(block)
+}
+
+// RawBlockByHash returns the rlp encoding of the committed block with the given hash
+func (b *Backend) RawBlockByHash(hash common.Hash) ([]byte, error) {
+	block := b.ethereum.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	return rlp.EncodeToBytes(block)
+}
+
+// StateRoot returns the state root of the committed block with the given number
+func (b *Backend) StateRoot(number uint64) (common.Hash, error) {
+	header := b.ethereum.BlockChain().GetHeaderByNumber(number)
+	if header == nil {
+		return common.Hash{}, errBlockNotFound
+	}
+	return header.Root, nil
+}
+
+// StateRootByHash returns the state root of the committed block with the given hash
+func (b *Backend) StateRootByHash(hash common.Hash) (common.Hash, error) {
+	header := b.ethereum.BlockChain().GetHeaderByHash(hash)
+	if header == nil {
+		return common.Hash{}, errBlockNotFound
+	}
+	return header.Root, nil
+}
+
+// RawTransaction returns the rlp encoding of the committed transaction with the given hash
+func (b *Backend) RawTransaction(hash common.Hash) ([]byte, error) {
+	tx, _, _, _ := core.GetTransaction(b.ethereum.ChainDb(), hash)
+	if tx == nil {
+		return nil, errTxNotFound
+	}
+	return rlp.EncodeToBytes(tx)
+}
+
+// Receipts returns the receipts of the committed transactions with the given
+// hashes, with a nil entry for every unknown hash
+func (b *Backend) Receipts(hashes []common.Hash) []*ethTypes.Receipt {
+	receipts := make([]*ethTypes.Receipt, len(hashes))
+	for i, hash := range hashes {
+		receipts[i] = core.GetReceipt(b.ethereum.ChainDb(), hash)
+	}
+	return receipts
+}
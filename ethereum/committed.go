@@ -0,0 +1,70 @@
+package ethereum
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+//----------------------------------------------------------------------
+// Announcement of the committed blocks
+
+// committedFeed sends the committed blocks to the channels of its subscribers
+// without waiting for them. The channel of a subscriber is its queue: like the
+// webhooks, events are dropped while it is full, so a slow subscriber never
+// holds up a commit.
+type committedFeed struct {
+	mtx  sync.Mutex
+	subs map[*committedSubscription]struct{}
+}
+
+func (f *committedFeed) subscribe(ch chan<- CommittedBlockEvent) event.Subscription {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.subs == nil {
+		f.subs = make(map[*committedSubscription]struct{})
+	}
+	sub := &committedSubscription{feed: f, ch: ch, err: make(chan error)}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// send queues the event for every subscriber that has room for it
+func (f *committedFeed) send(ev CommittedBlockEvent) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	for sub := range f.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			log.Warn("Committed block subscriber is full, dropping the block", "number", ev.Block.NumberU64())
+		}
+	}
+}
+
+// committedSubscription implements event.Subscription for a committedFeed
+type committedSubscription struct {
+	feed *committedFeed
+	ch   chan<- CommittedBlockEvent
+	once sync.Once
+	err  chan error
+}
+
+// Unsubscribe stops the events. The channel is not closed, it may still hold
+// events sent before.
+func (s *committedSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.feed.mtx.Lock()
+		delete(s.feed.subs, s)
+		s.feed.mtx.Unlock()
+		close(s.err)
+	})
+}
+
+// Err is closed on Unsubscribe. The feed itself never fails.
+func (s *committedSubscription) Err() <-chan error {
+	return s.err
+}
@@ -0,0 +1,34 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCommittedFeedNeverBlocks(t *testing.T) {
+	var feed committedFeed
+	block := func(n int64) CommittedBlockEvent {
+		return CommittedBlockEvent{Block: ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(n)})}
+	}
+
+	// nobody reads the unbuffered channel, the buffered one takes the first block
+	stuck := make(chan CommittedBlockEvent)
+	queued := make(chan CommittedBlockEvent, 1)
+	feed.subscribe(stuck)
+	sub := feed.subscribe(queued)
+
+	feed.send(block(1))
+	feed.send(block(2))
+	assert.Equal(t, uint64(1), (<-queued).Block.NumberU64())
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	_, open := <-sub.Err()
+	assert.False(t, open)
+	feed.send(block(3))
+	assert.Equal(t, 0, len(queued))
+}
@@ -0,0 +1,182 @@
+package ethereum
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//----------------------------------------------------------------------
+
+// Config holds the ethermint specific settings for block processing.
+// The zero value keeps the behaviour of a plain go-ethereum node.
+// The fields not marked node local take part in consensus and must be
+// identical on every validator.
+type Config struct {
+	// TreasuryAddress receives TreasuryFeePercent of the transaction fees
+	// of every block. The remainder stays with the coinbase.
+	TreasuryAddress    common.Address
+	TreasuryFeePercent uint64
+
+	// FeeOnlyRewards stops minting block rewards, including those of a reward
+	// strategy, so validators only earn the transaction fees and the supply never grows
+	FeeOnlyRewards bool
+
+	// FallbackCoinbase receives the rewards of blocks whose proposer has no
+	// ethereum address, which would otherwise burn them at the zero address
+	FallbackCoinbase common.Address
+
+	// RefundTreasuryPercent of the gas refunded to the sender of a transaction,
+	// after the refund cap, is routed to TreasuryAddress instead
+	RefundTreasuryPercent uint64
+
+	// FailureRefunds maps recipients to the percentage of the fee of a failed
+	// transaction to them that is refunded to its sender by the coinbase
+	FailureRefunds map[common.Address]uint64
+
+	// GasSubsidies maps recipients to the percentage of the fee of a transaction
+	// to them that SubsidyPool pays back to its sender, as long as it has the balance
+	GasSubsidies map[common.Address]uint64
+	SubsidyPool  common.Address
+
+	// GasPriceFloor rejects transactions in CheckTx whose gas price is below a
+	// floor adapting to the fullness of recent blocks when set
+	GasPriceFloor *GasPriceFloorConfig
+
+	// GasPriceOracle configures the gas price percentiles of the recent blocks
+	// returned by Backend.GasPricePercentiles. Node local, nil takes the defaults.
+	GasPriceOracle *GasPriceOracleConfig
+
+	// GasLimitPID replaces core.CalcGasLimit with a PID controller when set
+	GasLimitPID *PIDGasLimitConfig
+
+	// GasLimitBump raises the gas limit by a step after sustained congestion,
+	// on top of core.CalcGasLimit or the PID controller, when set
+	GasLimitBump *GasLimitBumpConfig
+
+	// GasLimitPolicy fixes the gas limit or clamps the adjusted one when set.
+	// Part of consensus, all validators must use the same policy.
+	GasLimitPolicy *GasLimitPolicyConfig
+
+	// UtilizationAlert warns operators of blocks that stay close to the gas limit when set
+	UtilizationAlert *UtilizationAlertConfig
+
+	// CoinbaseMaturity is the number of blocks before a minted block reward
+	// can be spent. 0 and 1 allow spending in the next block.
+	CoinbaseMaturity uint64
+
+	// SenderGasPercent limits the gas a single sender may use per block to this
+	// percentage of the block gas limit. 0 disables the limit.
+	SenderGasPercent uint64
+
+	// MinGasPrice rejects transactions paying less per gas in CheckTx, before they
+	// reach the mempool. Node local. nil accepts all gas prices.
+	MinGasPrice *big.Int
+
+	// MaxGasPrice rejects transactions paying more per gas in CheckTx. Without
+	// dynamic fee transactions the whole gas price is the priority fee of the
+	// proposer, so this caps the tip. nil disables the cap.
+	MaxGasPrice *big.Int
+
+	// GasPriceGranularity rejects transactions in CheckTx whose gas price is not
+	// a multiple of it, so fees move in clean increments. nil disables the check.
+	GasPriceGranularity *big.Int
+
+	// MaxFeeValueMultiple rejects value transfers in CheckTx whose gas limit times
+	// gas price exceeds this multiple of the transferred value, to protect senders
+	// from mistyped fees. Transactions without value are exempt. 0 disables the check.
+	MaxFeeValueMultiple uint64
+
+	// RequireReplayProtection rejects transactions without an EIP155 chain id once
+	// EIP155 is active. Transactions signed for another chain are always rejected.
+	RequireReplayProtection bool
+
+	// MinAccountAge rejects transactions from accounts created within this many
+	// blocks, unless the sender holds at least YoungAccountBalance. An account is
+	// established once it exists in the committed state this many blocks back,
+	// the genesis accounts always are. It is checked by CheckTx and when
+	// delivering transactions. 0 disables the check.
+	MinAccountAge       uint64
+	YoungAccountBalance *big.Int
+
+	// StateAccountLimit keeps contract creations and calls out of the mempool of
+	// this node once the state holds this many accounts, as tracked from the state
+	// growth of every block. Plain transfers are still accepted. The size is
+	// tracked best-effort in the node indexes, so the limit is a mempool policy
+	// and not a consensus rule: delivered transactions are never checked against
+	// it and a proposer can still include state growing transactions past the
+	// limit. 0 disables the limit.
+	StateAccountLimit uint64
+
+	// BlockBatchSize is the number of consecutive tendermint heights whose
+	// transactions go into one ethereum block, which is committed at the last one.
+	// Within a batch the app hash is the intermediate state root.
+	// 0 and 1 commit one ethereum block per height.
+	BlockBatchSize uint64
+
+	// MinBlockTransactions holds a non-empty ethereum block open past the end of
+	// its batch until it has this many transactions, or spans MaxBlockWait
+	// tendermint heights if that is set, and is committed at the first height
+	// either holds. Blocks without transactions are not held. 0 and 1 never hold
+	// a block.
+	MinBlockTransactions uint64
+	MaxBlockWait         uint64
+
+	// ReorderTxs queues the transactions delivered at a tendermint height and
+	// applies them at its end with the transactions of every sender sorted by
+	// nonce. abci announces the transactions one by one, so DeliverTx returns OK
+	// for every queued transaction before it is executed, without the gas used
+	// or the contract address. Those failing at the end of the height are only
+	// logged and left out of the block, clients have to check for the receipt.
+	// Part of consensus, all validators must use the same setting.
+	ReorderTxs bool
+	// CanonicalTxOrder sorts the reordered transactions of a height by sender,
+	// nonce and hash instead, so the same set of transactions always yields the
+	// same block whatever order it is delivered in. Part of consensus as well.
+	CanonicalTxOrder bool
+
+	// SimulateCheckTx executes every transaction against the pending state in
+	// CheckTx and rejects it if the execution would fail. Expensive, node local.
+	SimulateCheckTx bool
+
+	// CallCacheSize is the number of eth_call results forwarded by Query that
+	// are kept for the latest state, each for at most CallCacheTTL.
+	// 0 disables the cache, a zero TTL keeps entries until the next block.
+	CallCacheSize uint64
+	CallCacheTTL  time.Duration
+
+	// MaxStateCopies bounds the copies of the pending state held at once by
+	// simulations, pending snapshots, eth_call and eth_estimateGas on the pending
+	// block and the other queries on it through the ABCI Query, see
+	// Backend.AcquireStateCopy. Further copies wait up to StateCopyWait and are
+	// rejected as busy after. The other queries on the pending block sent to the
+	// RPC endpoints of the node are not bounded. 0 is unlimited.
+	MaxStateCopies uint64
+	StateCopyWait  time.Duration
+
+	// StateCheckpoints maps block numbers to the trusted state roots of their
+	// blocks. A block whose state root differs is not inserted and halts block
+	// processing, instead of silently diverging from the checkpoint.
+	StateCheckpoints map[uint64]common.Hash
+
+	// VerifyCommittedRoot reloads the state from the chain after every commit and
+	// halts block processing if its root differs from the committed block, an
+	// early warning of a corrupted state commit. Node local.
+	VerifyCommittedRoot bool
+
+	// AccountTxIndex indexes the committed transactions by sender and recipient
+	// for account history queries. Node local, only blocks committed while it
+	// is set are indexed.
+	AccountTxIndex bool
+
+	// ExecutionIndex records the contracts destroyed and the storage slots
+	// changed by the committed transactions, for the SelfDestructs and SlotWriter
+	// queries. It adds to the tracing cost of every executed step. Node local,
+	// only blocks committed while it is set are indexed.
+	ExecutionIndex bool
+
+	// Webhooks posts the result of every committed transaction to the configured
+	// URLs when set. Node local, posting never delays a commit.
+	Webhooks *WebhookConfig
+}
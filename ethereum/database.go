@@ -0,0 +1,86 @@
+package ethereum
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+//----------------------------------------------------------------------
+// Ethermint specific indexes stored next to the chain data.
+// Records are json encoded and keyed by a prefix and the block number,
+// or by a prefix and an address for indexes spanning the whole chain.
+
+var (
+	blockAddressesPrefix     = []byte("emt-addresses-") // blockAddressesPrefix + num (uint64 big endian) -> addresses
+	blockRewardPrefix        = []byte("emt-reward-")    // blockRewardPrefix + num (uint64 big endian) -> minted reward
+	blockStatsPrefix         = []byte("emt-stats-")     // blockStatsPrefix + num (uint64 big endian) -> BlockStats
+	blockGrowthPrefix        = []byte("emt-growth-")    // blockGrowthPrefix + num (uint64 big endian) -> StateGrowth
+	blockRewardEventsPrefix  = []byte("emt-payouts-")   // blockRewardEventsPrefix + num (uint64 big endian) -> RewardEvents
+	blockSelfDestructsPrefix = []byte("emt-destructs-") // blockSelfDestructsPrefix + num (uint64 big endian) -> SelfDestructs
+	blockErrorsPrefix        = []byte("emt-errors-")    // blockErrorsPrefix + num (uint64 big endian) -> vm errors per category
+	blockLogCountsPrefix     = []byte("emt-logcounts-") // blockLogCountsPrefix + num (uint64 big endian) -> logs per emitting address
+	blockSlotWritesPrefix    = []byte("emt-slots-")     // blockSlotWritesPrefix + num (uint64 big endian) -> SlotWrites
+	blockSenderCountsPrefix  = []byte("emt-senders-")   // blockSenderCountsPrefix + num (uint64 big endian) -> transactions per sender
+	blockSizePrefix          = []byte("emt-size-")      // blockSizePrefix + num (uint64 big endian) -> BlockSize
+
+	contractCreationPrefix = []byte("emt-creation-") // contractCreationPrefix + address -> ContractCreation
+	rewardHistoryPrefix    = []byte("emt-rewards-")  // rewardHistoryPrefix + address -> entry count
+	// rewardHistoryPrefix + address + index (uint64 big endian) -> RewardHistoryEntry
+	sentTxsPrefix     = []byte("emt-sent-")     // sentTxsPrefix + address -> entry count
+	receivedTxsPrefix = []byte("emt-received-") // receivedTxsPrefix + address -> entry count
+	// sentTxsPrefix or receivedTxsPrefix + address + index (uint64 big endian) -> AccountTransaction
+
+	genesisAllocKey = []byte("emt-genesis-alloc") // genesisAllocKey -> core.GenesisAlloc
+	stateSizeKey    = []byte("emt-state-size")    // stateSizeKey -> StateSize of the latest block
+	forkScheduleKey = []byte("emt-fork-schedule") // forkScheduleKey -> activation block per fork scheduled by the strategy
+)
+
+func blockIndexKey(prefix []byte, number uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], number)
+	return key
+}
+
+func addressIndexKey(prefix []byte, addr common.Address) []byte {
+	return append(append([]byte{}, prefix...), addr[:]...)
+}
+
+// addressListKey is the key of the i-th entry of a list kept per address
+func addressListKey(prefix []byte, addr common.Address, i uint64) []byte {
+	return blockIndexKey(addressIndexKey(prefix, addr), i)
+}
+
+// writeBlockIndex stores v for the block with the given number
+func writeBlockIndex(db ethdb.Database, prefix []byte, number uint64, v interface{}) error {
+	return writeIndex(db, blockIndexKey(prefix, number), v)
+}
+
+// readBlockIndex loads the record of the block with the given number into v.
+// It returns errBlockNotFound if nothing was stored for that block.
+func readBlockIndex(db ethdb.Database, prefix []byte, number uint64, v interface{}) error {
+	if !readIndex(db, blockIndexKey(prefix, number), v) {
+		return errBlockNotFound
+	}
+	return nil
+}
+
+func writeIndex(db ethdb.Database, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return db.Put(key, data)
+}
+
+// readIndex loads the record under key into v and reports whether it exists
+func readIndex(db ethdb.Database, key []byte, v interface{}) bool {
+	data, err := db.Get(key)
+	if err != nil || len(data) == 0 {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
@@ -0,0 +1,489 @@
+package ethereum
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	errTxNotFound        = errors.New("transaction not found")
+	errSlotWriteNotFound = errors.New("storage slot was never written")
+)
+
+//----------------------------------------------------------------------
+// Replay of committed transactions for debugging
+
+// replayWork returns a work that executes the transactions of the committed
+// block on top of the state of its parent, the way they were delivered
+func replayWork(block *ethTypes.Block, statedb *state.StateDB) *work {
+	header := block.Header()
+	return &work{
+		header:       header,
+		state:        statedb,
+		totalUsedGas: big.NewInt(0),
+		refundFees:   big.NewInt(0),
+		failureFees:  big.NewInt(0),
+		gp:           new(core.GasPool).AddGas(header.GasLimit),
+	}
+}
+
+// replayTx executes a transaction of the replayed block with the tracer, through
+// the same state transitions as deliverTx
+func (b *Backend) replayTx(w *work, tx *ethTypes.Transaction, tracer vm.Tracer) (*ethTypes.Receipt, error) {
+	blockchain := b.ethereum.BlockChain()
+	chainConfig := b.ChainConfig()
+	from, err := ethTypes.Sender(ethTypes.MakeSigner(chainConfig, w.header.Number), tx)
+	if err != nil {
+		return nil, err
+	}
+	deliver := newDeliverTracer(false, tracer)
+	return w.applyTx(blockchain, b.emtConfig, chainConfig, from, tx, vm.Config{}, deliver)
+}
+
+// replayTransaction re-executes the block containing txHash on top of its parent
+// state, up to and including the transaction, which is executed with the tracer.
+// It returns the receipt of the transaction and the state right after it.
+func (b *Backend) replayTransaction(txHash common.Hash, tracer vm.Tracer) (*ethTypes.Receipt, *state.StateDB, error) {
+	blockchain := b.ethereum.BlockChain()
+	_, blockHash, number, index := core.GetTransaction(b.ethereum.ChainDb(), txHash)
+	block := blockchain.GetBlock(blockHash, number)
+	if block == nil {
+		return nil, nil, errTxNotFound
+	}
+	parent := blockchain.GetBlock(block.ParentHash(), number-1)
+	if parent == nil {
+		return nil, nil, errBlockNotFound
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := replayWork(block, statedb)
+	for i, tx := range block.Transactions() {
+		var txTracer vm.Tracer
+		if uint64(i) == index {
+			txTracer = tracer
+		}
+
+		statedb.StartRecord(tx.Hash(), blockHash, i)
+		receipt, err := b.replayTx(w, tx, txTracer)
+		if err != nil {
+			return nil, nil, err
+		}
+		if uint64(i) == index {
+			return receipt, statedb, nil
+		}
+	}
+	return nil, nil, errTxNotFound
+}
+
+// replayBlock re-executes all transactions of the committed block with the given
+// number on top of its parent state, with the tracer
+func (b *Backend) replayBlock(number uint64, tracer vm.Tracer) error {
+	blockchain := b.ethereum.BlockChain()
+	block := blockchain.GetBlockByNumber(number)
+	if block == nil {
+		return errBlockNotFound
+	}
+	if len(block.Transactions()) == 0 {
+		return nil
+	}
+	parent := blockchain.GetBlock(block.ParentHash(), number-1)
+	if parent == nil {
+		return errBlockNotFound
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+
+	w := replayWork(block, statedb)
+	for i, tx := range block.Transactions() {
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+		if _, err := b.replayTx(w, tx, tracer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//----------------------------------------------------------------------
+// Storage writes
+
+// StorageChange is a storage slot changed by a transaction
+type StorageChange struct {
+	Address  common.Address `json:"address"`
+	Slot     common.Hash    `json:"slot"`
+	OldValue common.Hash    `json:"oldValue"`
+	NewValue common.Hash    `json:"newValue"`
+}
+
+type storageSlot struct {
+	address common.Address
+	slot    common.Hash
+}
+
+// storageWriteTracer records the value of every slot before its first SSTORE
+type storageWriteTracer struct {
+	written []StorageChange
+	seen    map[storageSlot]bool
+}
+
+func newStorageWriteTracer() *storageWriteTracer {
+	return &storageWriteTracer{seen: make(map[storageSlot]bool)}
+}
+
+// CaptureState implements vm.Tracer. It is called before the op is executed.
+func (t *storageWriteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if op != vm.SSTORE || err != nil {
+		return nil
+	}
+
+	data := stack.Data()
+	key := storageSlot{contract.Address(), common.BigToHash(data[len(data)-1])}
+	if !t.seen[key] {
+		t.seen[key] = true
+		t.written = append(t.written, StorageChange{
+			Address:  key.address,
+			Slot:     key.slot,
+			OldValue: env.StateDB.GetState(key.address, key.slot),
+		})
+	}
+	return nil
+}
+
+// changes returns the written slots whose value differs after the transaction.
+// Writes that were reverted, or restored the old value, are dropped.
+func (t *storageWriteTracer) changes(statedb *state.StateDB) []StorageChange {
+	changes := []StorageChange{}
+	for _, change := range t.written {
+		change.NewValue = statedb.GetState(change.Address, change.Slot)
+		if change.NewValue != change.OldValue {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// StorageChanges returns the storage slots changed by a committed transaction,
+// in the order they were first written
+func (b *Backend) StorageChanges(txHash common.Hash) ([]StorageChange, error) {
+	tracer := newStorageWriteTracer()
+	_, statedb, err := b.replayTransaction(txHash, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return tracer.changes(statedb), nil
+}
+
+// SlotWrite is a storage slot changed by a committed transaction
+type SlotWrite struct {
+	Address     common.Address `json:"address"`
+	Slot        common.Hash    `json:"slot"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+}
+
+// SlotWriter returns the latest transaction that changed the storage slot of
+// addr in the block with the given number or before. The changes are recorded
+// when a block is committed, so blocks committed without the index are skipped.
+func (b *Backend) SlotWriter(addr common.Address, slot common.Hash, number uint64) (*SlotWrite, error) {
+	if number > b.ethereum.BlockChain().CurrentBlock().NumberU64() {
+		return nil, errBlockNotFound
+	}
+	db := b.ethereum.ChainDb()
+	for n := number; n > 0; n-- {
+		var writes []*SlotWrite
+		if err := readBlockIndex(db, blockSlotWritesPrefix, n, &writes); err != nil {
+			continue
+		}
+		for i := len(writes) - 1; i >= 0; i-- {
+			if writes[i].Address == addr && writes[i].Slot == slot {
+				writes[i].BlockNumber = n
+				return writes[i], nil
+			}
+		}
+	}
+	return nil, errSlotWriteNotFound
+}
+
+//----------------------------------------------------------------------
+// Storage reads
+
+// StorageRead is a storage slot read by a transaction and the value it read first
+type StorageRead struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Value   common.Hash    `json:"value"`
+}
+
+// storageReadTracer records the value of every slot at its first SLOAD. Slots
+// that are only written are not recorded.
+type storageReadTracer struct {
+	reads []StorageRead
+	seen  map[storageSlot]bool
+}
+
+func newStorageReadTracer() *storageReadTracer {
+	return &storageReadTracer{reads: []StorageRead{}, seen: make(map[storageSlot]bool)}
+}
+
+// CaptureState implements vm.Tracer. It is called before the op is executed.
+func (t *storageReadTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if op != vm.SLOAD || err != nil {
+		return nil
+	}
+
+	data := stack.Data()
+	key := storageSlot{contract.Address(), common.BigToHash(data[len(data)-1])}
+	if !t.seen[key] {
+		t.seen[key] = true
+		t.reads = append(t.reads, StorageRead{
+			Address: key.address,
+			Slot:    key.slot,
+			Value:   env.StateDB.GetState(key.address, key.slot),
+		})
+	}
+	return nil
+}
+
+// StorageReads returns the storage slots read by a committed transaction, in
+// the order they were first read. The slots it wrote are in StorageChanges.
+func (b *Backend) StorageReads(txHash common.Hash) ([]StorageRead, error) {
+	tracer := newStorageReadTracer()
+	if _, _, err := b.replayTransaction(txHash, tracer); err != nil {
+		return nil, err
+	}
+	return tracer.reads, nil
+}
+
+//----------------------------------------------------------------------
+// Access lists
+
+// AccessTuple is an address and the storage keys of it a transaction accessed,
+// in the format of an EIP-2930 access list entry
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// accessListTracer records the accounts and storage slots accessed by the
+// executed code, in order of first access. Like go-ethereum's access list
+// tracer, the sender and the recipient are only listed for their storage keys.
+type accessListTracer struct {
+	excluded map[common.Address]bool
+	list     []*AccessTuple
+	index    map[common.Address]*AccessTuple
+	slots    map[storageSlot]bool
+}
+
+func newAccessListTracer(excluded ...common.Address) *accessListTracer {
+	t := &accessListTracer{
+		excluded: make(map[common.Address]bool),
+		index:    make(map[common.Address]*AccessTuple),
+		slots:    make(map[storageSlot]bool),
+	}
+	for _, addr := range excluded {
+		t.excluded[addr] = true
+	}
+	return t
+}
+
+func (t *accessListTracer) tuple(addr common.Address) *AccessTuple {
+	tuple, ok := t.index[addr]
+	if !ok {
+		tuple = &AccessTuple{Address: addr, StorageKeys: []common.Hash{}}
+		t.index[addr] = tuple
+		t.list = append(t.list, tuple)
+	}
+	return tuple
+}
+
+func (t *accessListTracer) addAddress(addr common.Address) {
+	if !t.excluded[addr] {
+		t.tuple(addr)
+	}
+}
+
+func (t *accessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	key := storageSlot{addr, slot}
+	if !t.slots[key] {
+		t.slots[key] = true
+		tuple := t.tuple(addr)
+		tuple.StorageKeys = append(tuple.StorageKeys, slot)
+	}
+}
+
+// CaptureState implements vm.Tracer. It is called before the op is executed.
+func (t *accessListTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil {
+		return nil
+	}
+
+	data := stack.Data()
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		t.addSlot(contract.Address(), common.BigToHash(data[len(data)-1]))
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.SELFDESTRUCT:
+		t.addAddress(common.BigToAddress(data[len(data)-1]))
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL:
+		t.addAddress(common.BigToAddress(data[len(data)-2]))
+	}
+	return nil
+}
+
+// AccessList returns the accounts and storage slots a committed transaction
+// accessed, to be attached as an access list when it is resubmitted
+func (b *Backend) AccessList(txHash common.Hash) ([]*AccessTuple, error) {
+	tx, _, number, _ := core.GetTransaction(b.ethereum.ChainDb(), txHash)
+	if tx == nil {
+		return nil, errTxNotFound
+	}
+	signer := ethTypes.MakeSigner(b.ChainConfig(), new(big.Int).SetUint64(number))
+	from, err := ethTypes.Sender(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	to := crypto.CreateAddress(from, tx.Nonce())
+	if tx.To() != nil {
+		to = *tx.To()
+	}
+
+	tracer := newAccessListTracer(from, to)
+	if _, _, err := b.replayTransaction(txHash, tracer); err != nil {
+		return nil, err
+	}
+	list := tracer.list
+	if list == nil {
+		list = []*AccessTuple{}
+	}
+	return list, nil
+}
+
+//----------------------------------------------------------------------
+// Call graphs
+
+// CallEdge is a contract calling another contract, with the number of calls
+type CallEdge struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Calls uint64         `json:"calls"`
+}
+
+type callPair struct {
+	from, to common.Address
+}
+
+// callGraphTracer records the calls made by executing code to accounts with
+// code, in order of the first call. Calls are recorded when they are
+// attempted, whether or not they succeed.
+type callGraphTracer struct {
+	edges []*CallEdge
+	index map[callPair]*CallEdge
+}
+
+func newCallGraphTracer() *callGraphTracer {
+	return &callGraphTracer{edges: []*CallEdge{}, index: make(map[callPair]*CallEdge)}
+}
+
+// CaptureState implements vm.Tracer. It is called before the op is executed.
+func (t *callGraphTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil || (op != vm.CALL && op != vm.CALLCODE && op != vm.DELEGATECALL) {
+		return nil
+	}
+	data := stack.Data()
+	to := common.BigToAddress(data[len(data)-2])
+	if env.StateDB.GetCodeSize(to) == 0 {
+		return nil
+	}
+
+	key := callPair{contract.Address(), to}
+	edge, ok := t.index[key]
+	if !ok {
+		edge = &CallEdge{From: key.from, To: key.to}
+		t.index[key] = edge
+		t.edges = append(t.edges, edge)
+	}
+	edge.Calls++
+	return nil
+}
+
+// CallGraph replays the committed block with the given number and returns the
+// contracts that called other contracts in it
+func (b *Backend) CallGraph(number uint64) ([]*CallEdge, error) {
+	tracer := newCallGraphTracer()
+	if err := b.replayBlock(number, tracer); err != nil {
+		return nil, err
+	}
+	return tracer.edges, nil
+}
+
+//----------------------------------------------------------------------
+// Opcode traces
+
+// TraceStep is an opcode executed by a transaction, with the stack before it
+type TraceStep struct {
+	Pc      uint64   `json:"pc"`
+	Op      string   `json:"op"`
+	Gas     uint64   `json:"gas"`
+	GasCost uint64   `json:"gasCost"`
+	Depth   int      `json:"depth"`
+	Stack   []string `json:"stack"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// TransactionTrace is the opcode level execution of a committed transaction
+type TransactionTrace struct {
+	Gas uint64 `json:"gas"`
+	// Failed is set if the outermost call frame aborted, reverting the transaction
+	Failed bool        `json:"failed"`
+	Steps  []TraceStep `json:"structLogs"`
+}
+
+// opcodeTracer records every step of the execution
+type opcodeTracer struct {
+	steps  []TraceStep
+	failed bool
+}
+
+// CaptureState implements vm.Tracer. It is called before the op is executed,
+// and once more with the error for the step that fails.
+func (t *opcodeTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	step := TraceStep{Pc: pc, Op: op.String(), Gas: gas, GasCost: cost, Depth: depth, Stack: []string{}}
+	for _, item := range stack.Data() {
+		step.Stack = append(step.Stack, common.BigToHash(item).Hex())
+	}
+	if err != nil {
+		step.Error = err.Error()
+		if depth == 1 {
+			t.failed = true
+		}
+	}
+	t.steps = append(t.steps, step)
+	return nil
+}
+
+// TraceTransaction replays the committed transaction on top of the state before
+// it and returns the opcodes it executed. Plain transfers have no steps.
+func (b *Backend) TraceTransaction(txHash common.Hash) (*TransactionTrace, error) {
+	tracer := &opcodeTracer{steps: []TraceStep{}}
+	receipt, _, err := b.replayTransaction(txHash, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionTrace{Gas: receipt.GasUsed.Uint64(), Failed: tracer.failed, Steps: tracer.steps}, nil
+}
@@ -0,0 +1,40 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+)
+
+func TestReplayWorkMatchesDelivery(t *testing.T) {
+	key, txs := signedTransfers(t, 1)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	defer stubApplyTransaction(from)()
+
+	// the transfers to common.Address{1} are half paid by the pool
+	pool := common.Address{2}
+	p := newTestPending(t, withBalance(from, 1e+18), withTransfersRoom(len(txs)))
+	p.config = &Config{GasSubsidies: map[common.Address]uint64{{1}: 50}, SubsidyPool: pool}
+	p.work.state.AddBalance(pool, big.NewInt(1e+18))
+	parent := p.work.state.Copy()
+
+	_, err := p.deliverTx(nil, &eth.Config{}, txs[0])
+	assert.Nil(t, err)
+
+	block := ethTypes.NewBlock(p.work.header, txs, nil, nil)
+	w := replayWork(block, parent)
+	_, err = w.applyTx(nil, p.config, p.chainConfig, from, txs[0], vm.Config{}, newDeliverTracer(false, nil))
+	assert.Nil(t, err)
+
+	// the replay pays the subsidy like the delivery did
+	assert.Equal(t, 0, p.work.state.GetBalance(from).Cmp(parent.GetBalance(from)))
+	assert.Equal(t, 0, p.work.state.GetBalance(pool).Cmp(parent.GetBalance(pool)))
+	assert.NotEqual(t, 0, parent.GetBalance(pool).Cmp(big.NewInt(1e+18)))
+}
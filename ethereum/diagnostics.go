@@ -0,0 +1,118 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// commitFailureHistory is the number of CommitFailures kept by pending
+const commitFailureHistory = 16
+
+// Stages of the block assembly reported by CommitFailure
+const (
+	CommitStageInvariant  = "invariant"  // checking the registered invariants
+	CommitStageHeader     = "header"     // encoding the consensus data of the header
+	CommitStageState      = "state"      // committing the pending state
+	CommitStageCheckpoint = "checkpoint" // matching the state root of a checkpoint
+	CommitStageInsert     = "insert"     // inserting the block into the chain
+	CommitStageUpgrade    = "upgrade"    // scheduling fork upgrades
+	CommitStageReset      = "reset"      // starting the work of the next block
+	CommitStageVerify     = "verify"     // verifying the state root reloaded from the chain
+)
+
+//----------------------------------------------------------------------
+// Diagnostics of failed commits, kept in memory to triage consensus halts
+
+// CommitFailure is a snapshot of the pending block taken when its commit failed
+type CommitFailure struct {
+	BlockNumber uint64   `json:"blockNumber"`
+	TxCount     int      `json:"txCount"`
+	GasUsed     *big.Int `json:"gasUsed"`
+	Stage       string   `json:"stage"`
+	Error       string   `json:"error"`
+}
+
+// commitError is an error of work.commit together with the failing stage
+type commitError struct {
+	stage string
+	err   error
+}
+
+func (e *commitError) Error() string {
+	return e.stage + ": " + e.err.Error()
+}
+
+// ErrPendingClosed is returned by the pending block once the node shuts down
+var ErrPendingClosed = errors.New("the pending block is closed, the node is shutting down")
+
+// HaltError is returned by the pending block once a block failed to commit: it
+// broke an invariant, could not be inserted into the chain after its state was
+// committed, its state root did not match a checkpoint, or a stage after its
+// insertion failed, like reloading the state and matching it against the root,
+// starting the work of the next block or activating a fork scheduled since the
+// node started. The work of a failed block already holds its rewards and can't
+// be committed again. Block processing stops until the node restarts, and
+// tendermint replays the heights after the last inserted block.
+type HaltError struct {
+	Err error
+}
+
+func (e *HaltError) Error() string {
+	return "block processing halted: " + e.Err.Error()
+}
+
+// CheckpointError is the divergence of a block from a trusted checkpoint
+type CheckpointError struct {
+	Number   uint64
+	Root     common.Hash
+	Expected common.Hash
+}
+
+func (e *CheckpointError) Error() string {
+	return fmt.Sprintf("state root %x of block %d does not match the checkpoint %x", e.Root, e.Number, e.Expected)
+}
+
+// RootMismatchError is a committed block whose root differs from the state
+// reloaded from the chain after the commit
+type RootMismatchError struct {
+	Number   uint64
+	Root     common.Hash
+	Expected common.Hash
+}
+
+func (e *RootMismatchError) Error() string {
+	return fmt.Sprintf("reloaded state root %x does not match the root %x of the committed block %d", e.Root, e.Expected, e.Number)
+}
+
+// recordFailure keeps a diagnostic of the current work failing in the given stage,
+// or in the stage of a commitError, dropping the oldest one beyond commitFailureHistory
+func (p *pending) recordFailure(stage string, err error) {
+	if cerr, ok := err.(*commitError); ok {
+		stage, err = cerr.stage, cerr.err
+	}
+	p.failures = append(p.failures, &CommitFailure{
+		BlockNumber: p.work.header.Number.Uint64(),
+		TxCount:     len(p.work.transactions),
+		GasUsed:     new(big.Int).Set(p.work.totalUsedGas),
+		Stage:       stage,
+		Error:       err.Error(),
+	})
+	if len(p.failures) > commitFailureHistory {
+		p.failures = p.failures[len(p.failures)-commitFailureHistory:]
+	}
+}
+
+func (p *pending) commitFailures() []*CommitFailure {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return append([]*CommitFailure{}, p.failures...)
+}
+
+// CommitFailures returns the diagnostics of the latest failed commits, oldest first
+func (b *Backend) CommitFailures() []*CommitFailure {
+	return b.pending.commitFailures()
+}
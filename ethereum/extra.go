@@ -0,0 +1,65 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//----------------------------------------------------------------------
+// Consensus data recorded in the extra field of the block headers
+
+// blockExtra is the rlp encoded extra data ethermint writes into the header of
+// every block. It is part of the block hash, so the consensus rules that depend
+// on earlier blocks read it from the chain instead of the best-effort indexes,
+// and every node derives the same values whatever indexes it has.
+type blockExtra struct {
+	// Reward is the block reward minted to the coinbase
+	Reward *big.Int
+	// Height is the latest tendermint height that delivered to the block
+	Height uint64
+}
+
+var errHeightNotRecorded = errors.New("tendermint height not recorded in the header")
+
+// encodeBlockExtra returns the extra data of a header, at most the size the
+// header verification accepts
+func encodeBlockExtra(extra *blockExtra) ([]byte, error) {
+	data, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) > params.MaximumExtraDataSize {
+		return nil, fmt.Errorf("header extra data of %d bytes exceeds %d bytes", len(data), params.MaximumExtraDataSize)
+	}
+	return data, nil
+}
+
+// decodeBlockExtra returns the ethermint data of the header. Headers without
+// it, the genesis and blocks committed before it was recorded, read as zero
+// values.
+func decodeBlockExtra(header *ethTypes.Header) *blockExtra {
+	extra := new(blockExtra)
+	if len(header.Extra) == 0 || rlp.DecodeBytes(header.Extra, extra) != nil || extra.Reward == nil {
+		extra = &blockExtra{Reward: new(big.Int)}
+	}
+	return extra
+}
+
+// TendermintHeight returns the tendermint height at which the block with the
+// given number was committed, as recorded in its header
+func (b *Backend) TendermintHeight(number uint64) (uint64, error) {
+	header := b.ethereum.BlockChain().GetHeaderByNumber(number)
+	if header == nil {
+		return 0, errBlockNotFound
+	}
+	height := decodeBlockExtra(header).Height
+	if height == 0 {
+		return 0, errHeightNotRecorded
+	}
+	return height, nil
+}
@@ -0,0 +1,31 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestBlockExtra(t *testing.T) {
+	reward, _ := new(big.Int).SetString("5000000000000000000", 10)
+	data, err := encodeBlockExtra(&blockExtra{Reward: reward, Height: 1 << 40})
+	assert.Nil(t, err)
+	extra := decodeBlockExtra(&ethTypes.Header{Extra: data})
+	assert.Equal(t, 0, extra.Reward.Cmp(reward))
+	assert.Equal(t, uint64(1<<40), extra.Height)
+
+	// headers without ethermint data read as zero values
+	for _, data := range [][]byte{nil, []byte("geth genesis")} {
+		extra := decodeBlockExtra(&ethTypes.Header{Extra: data})
+		assert.Equal(t, 0, extra.Reward.Sign())
+		assert.Equal(t, uint64(0), extra.Height)
+	}
+
+	// the header verification bounds the size
+	huge := new(big.Int).Lsh(big.NewInt(1), 8*40)
+	_, err = encodeBlockExtra(&blockExtra{Reward: huge})
+	assert.NotNil(t, err)
+}
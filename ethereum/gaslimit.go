@@ -0,0 +1,249 @@
+package ethereum
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//----------------------------------------------------------------------
+// Gas limit adjustment for new blocks
+
+// PIDGasLimitConfig configures a PID controller that steers the block gas limit
+// towards a target utilization of recent blocks. Integer arithmetic is used
+// throughout so that every validator derives the same limit.
+type PIDGasLimitConfig struct {
+	// Target utilization of the block gas limit in per mille, eg. 500 for half full blocks
+	Target int64
+
+	// Proportional, integral and derivative gains in thousandths
+	Kp, Ki, Kd int64
+
+	// Window is the number of recent blocks averaged by the integral term
+	Window uint64
+}
+
+// GasLimitBumpConfig raises the block gas limit by a fixed step after a run of
+// congested blocks, up to a ceiling. Like the PID controller it is derived from
+// the committed headers only.
+type GasLimitBumpConfig struct {
+	// Threshold is the utilization of the gas limit in per mille from which a
+	// block counts as congested
+	Threshold int64
+
+	// Blocks is the number of consecutive congested blocks that raise the limit
+	Blocks uint64
+
+	// Step is added to the gas limit of the parent, within the change the header
+	// verification accepts
+	Step *big.Int
+
+	// Ceiling is the highest gas limit a bump reaches
+	Ceiling *big.Int
+}
+
+// GasLimitPolicyConfig overrides the adjusted gas limit. With Fixed set every
+// block targets that gas limit, ignoring the usage of the parent and the other
+// adjustments. Otherwise the adjusted gas limit is clamped to Min and Max,
+// each optional. The header verification only accepts a change of less than
+// 1/1024 of the parent's limit, so a limit outside the policy moves towards it
+// over several blocks.
+type GasLimitPolicyConfig struct {
+	Fixed    *big.Int
+	Min, Max *big.Int
+}
+
+// calcGasLimit returns the gas limit for the block following parent
+func calcGasLimit(blockchain *core.BlockChain, config *Config, parent *ethTypes.Block) *big.Int {
+	policy := config.GasLimitPolicy
+	if policy != nil && policy.Fixed != nil {
+		return boundGasLimit(parent.GasLimit(), policy.Fixed)
+	}
+
+	var limit *big.Int
+	if config.GasLimitPID != nil {
+		limit = pidGasLimit(config.GasLimitPID, recentHeaders(blockchain, parent, config.GasLimitPID.Window))
+	} else {
+		limit = core.CalcGasLimit(parent)
+	}
+	if config.GasLimitBump != nil {
+		limit = bumpGasLimit(config.GasLimitBump, recentHeaders(blockchain, parent, config.GasLimitBump.Blocks), limit)
+	}
+	if policy != nil {
+		limit = clampGasLimit(policy, parent.GasLimit(), limit)
+	}
+	return limit
+}
+
+// clampGasLimit moves limit into the range of the policy, as far as the header
+// verification accepts for a child of a block with parentLimit
+func clampGasLimit(policy *GasLimitPolicyConfig, parentLimit, limit *big.Int) *big.Int {
+	if policy.Min != nil && limit.Cmp(policy.Min) < 0 {
+		return boundGasLimit(parentLimit, policy.Min)
+	}
+	if policy.Max != nil && limit.Cmp(policy.Max) > 0 {
+		return boundGasLimit(parentLimit, policy.Max)
+	}
+	return limit
+}
+
+// bumpGasLimit raises limit to the parent's limit plus the step if the recent
+// headers, most recent first, are all congested. A limit that is already
+// higher is kept.
+func bumpGasLimit(config *GasLimitBumpConfig, recent []*ethTypes.Header, limit *big.Int) *big.Int {
+	if uint64(len(recent)) < config.Blocks {
+		return limit
+	}
+	for _, header := range recent {
+		if utilization(header) < config.Threshold {
+			return limit
+		}
+	}
+
+	parentLimit := recent[0].GasLimit
+	bumped := boundGasLimit(parentLimit, new(big.Int).Add(parentLimit, config.Step))
+	if bumped.Cmp(config.Ceiling) > 0 {
+		bumped = new(big.Int).Set(config.Ceiling)
+	}
+	if bumped.Cmp(limit) > 0 {
+		return bumped
+	}
+	return limit
+}
+
+// pidGasLimit computes the next gas limit from recent headers, most recent first
+func pidGasLimit(config *PIDGasLimitConfig, recent []*ethTypes.Header) *big.Int {
+	errs := make([]int64, len(recent))
+	for i, header := range recent {
+		errs[i] = utilization(header) - config.Target
+	}
+
+	proportional := errs[0]
+	integral := int64(0)
+	for _, e := range errs {
+		integral += e
+	}
+	integral /= int64(len(errs))
+	derivative := int64(0)
+	if len(errs) > 1 {
+		derivative = errs[0] - errs[1]
+	}
+
+	// the gains are in thousandths and the errors in per mille
+	adjustment := config.Kp*proportional + config.Ki*integral + config.Kd*derivative
+	parentLimit := recent[0].GasLimit
+	delta := new(big.Int).Mul(parentLimit, big.NewInt(adjustment))
+	delta.Div(delta, big.NewInt(1000*1000))
+
+	return boundGasLimit(parentLimit, new(big.Int).Add(parentLimit, delta))
+}
+
+// utilization of the header's gas limit in per mille
+func utilization(header *ethTypes.Header) int64 {
+	if header.GasLimit.Sign() == 0 {
+		return 0
+	}
+	used := new(big.Int).Mul(header.GasUsed, big.NewInt(1000))
+	return used.Div(used, header.GasLimit).Int64()
+}
+
+// boundGasLimit clamps limit to what the header verification accepts for a
+// child of a block with parentLimit, ie. a change of less than 1/1024
+func boundGasLimit(parentLimit, limit *big.Int) *big.Int {
+	step := new(big.Int).Div(parentLimit, params.GasLimitBoundDivisor)
+	step.Sub(step, big.NewInt(1))
+
+	if lower := new(big.Int).Sub(parentLimit, step); limit.Cmp(lower) < 0 {
+		limit = lower
+	}
+	if upper := new(big.Int).Add(parentLimit, step); limit.Cmp(upper) > 0 {
+		limit = upper
+	}
+	if limit.Cmp(params.MinGasLimit) < 0 {
+		limit = new(big.Int).Set(params.MinGasLimit)
+	}
+	return limit
+}
+
+// recentHeaders returns up to window headers ending with parent, most recent first
+func recentHeaders(blockchain *core.BlockChain, parent *ethTypes.Block, window uint64) []*ethTypes.Header {
+	headers := []*ethTypes.Header{parent.Header()}
+	for uint64(len(headers)) < window {
+		last := headers[len(headers)-1]
+		if last.Number.Sign() == 0 {
+			break
+		}
+		header := blockchain.GetHeader(last.ParentHash, last.Number.Uint64()-1)
+		if header == nil {
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+//----------------------------------------------------------------------
+// Gas limit utilization alerts
+
+// utilizationAlertHistory is the number of UtilizationAlerts kept in memory
+const utilizationAlertHistory = 16
+
+// UtilizationAlertConfig raises an alert once each of the last Window blocks
+// used at least Threshold per mille of its gas limit, hinting operators to
+// raise the gas limit
+type UtilizationAlertConfig struct {
+	Threshold int64
+	Window    uint64
+}
+
+// UtilizationAlert is raised at the block completing a window of high utilization
+type UtilizationAlert struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	// Utilization is the average over the window in per mille
+	Utilization int64 `json:"utilization"`
+}
+
+// utilizationMonitor raises an alert when the utilization becomes sustained and
+// stays quiet until it drops below the threshold again
+type utilizationMonitor struct {
+	config    *UtilizationAlertConfig
+	sustained bool
+	alerts    []*UtilizationAlert
+}
+
+// observe checks the recent headers, most recent first, after the block with the
+// given number was committed. It returns the alert it raised, if any.
+func (m *utilizationMonitor) observe(number uint64, recent []*ethTypes.Header) *UtilizationAlert {
+	average, sustained := sustainedUtilization(m.config, recent)
+	raise := sustained && !m.sustained
+	m.sustained = sustained
+	if !raise {
+		return nil
+	}
+
+	alert := &UtilizationAlert{BlockNumber: number, Utilization: average}
+	m.alerts = append(m.alerts, alert)
+	if len(m.alerts) > utilizationAlertHistory {
+		m.alerts = m.alerts[len(m.alerts)-utilizationAlertHistory:]
+	}
+	return alert
+}
+
+// sustainedUtilization returns the average utilization of the recent headers and
+// whether they fill the window and each reaches the threshold
+func sustainedUtilization(config *UtilizationAlertConfig, recent []*ethTypes.Header) (int64, bool) {
+	if len(recent) == 0 || uint64(len(recent)) < config.Window {
+		return 0, false
+	}
+	total := int64(0)
+	for _, header := range recent {
+		u := utilization(header)
+		if u < config.Threshold {
+			return 0, false
+		}
+		total += u
+	}
+	return total / int64(len(recent)), true
+}
@@ -0,0 +1,216 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+var testPIDConfig = &PIDGasLimitConfig{Target: 500, Kp: 500, Ki: 100, Kd: 0, Window: 16}
+
+// simulatePID feeds the controller blocks that use min(demand, limit) gas
+// and returns the gas limit of every block
+func simulatePID(config *PIDGasLimitConfig, start, demand *big.Int, blocks int) []*big.Int {
+	limits := []*big.Int{start}
+	recent := []*ethTypes.Header{}
+	for i := 0; i < blocks; i++ {
+		limit := limits[len(limits)-1]
+		used := demand
+		if used.Cmp(limit) > 0 {
+			used = limit
+		}
+		recent = append([]*ethTypes.Header{{GasLimit: limit, GasUsed: new(big.Int).Set(used)}}, recent...)
+		if uint64(len(recent)) > config.Window {
+			recent = recent[:config.Window]
+		}
+		limits = append(limits, pidGasLimit(config, recent))
+	}
+	return limits
+}
+
+func TestPIDGasLimitFullBlocks(t *testing.T) {
+	start := big.NewInt(5000000)
+	limits := simulatePID(testPIDConfig, start, new(big.Int).Mul(start, big.NewInt(100)), 100)
+	for i := 1; i < len(limits); i++ {
+		assert.Equal(t, 1, limits[i].Cmp(limits[i-1]), "gas limit should grow at block %d", i)
+		assert.Equal(t, limits[i], boundGasLimit(limits[i-1], limits[i]), "gas limit step too large at block %d", i)
+	}
+}
+
+func TestPIDGasLimitEmptyBlocks(t *testing.T) {
+	start := big.NewInt(5000000)
+	limits := simulatePID(testPIDConfig, start, big.NewInt(0), 100)
+	for i := 1; i < len(limits); i++ {
+		assert.Equal(t, -1, limits[i].Cmp(limits[i-1]), "gas limit should shrink at block %d", i)
+	}
+}
+
+func TestPIDGasLimitConverges(t *testing.T) {
+	// a steady demand of 4M gas should settle the limit around 8M for a 50% target
+	limits := simulatePID(testPIDConfig, big.NewInt(5000000), big.NewInt(4000000), 2000)
+	last := limits[len(limits)-1]
+
+	target := big.NewInt(8000000)
+	tolerance := big.NewInt(80000)
+	diff := new(big.Int).Sub(last, target)
+	assert.True(t, diff.Abs(diff).Cmp(tolerance) <= 0, "gas limit %v did not converge to %v", last, target)
+
+	// and stay there
+	assert.Equal(t, last, limits[len(limits)-100])
+}
+
+// observeUtilizations feeds the monitor blocks using the given gas of a 1000 gas limit
+// and returns the numbers of the blocks that raised an alert
+func observeUtilizations(monitor *utilizationMonitor, gasUsed []int64) []uint64 {
+	alerted := []uint64{}
+	recent := []*ethTypes.Header{}
+	for i, used := range gasUsed {
+		recent = append([]*ethTypes.Header{{GasLimit: big.NewInt(1000), GasUsed: big.NewInt(used)}}, recent...)
+		if uint64(len(recent)) > monitor.config.Window {
+			recent = recent[:monitor.config.Window]
+		}
+		if alert := monitor.observe(uint64(i+1), recent); alert != nil {
+			alerted = append(alerted, alert.BlockNumber)
+		}
+	}
+	return alerted
+}
+
+func TestUtilizationAlertSustained(t *testing.T) {
+	monitor := &utilizationMonitor{config: &UtilizationAlertConfig{Threshold: 800, Window: 3}}
+	// fires once the third high block completes the window, and not again while sustained
+	assert.Equal(t, []uint64{4}, observeUtilizations(monitor, []int64{500, 900, 850, 1000, 950, 900}))
+	assert.Equal(t, int64(916), monitor.alerts[0].Utilization)
+}
+
+func TestUtilizationAlertInterrupted(t *testing.T) {
+	monitor := &utilizationMonitor{config: &UtilizationAlertConfig{Threshold: 800, Window: 3}}
+	// a block below the threshold resets the window and rearms the alert
+	assert.Equal(t, []uint64{6, 10}, observeUtilizations(monitor, []int64{900, 900, 799, 900, 900, 900, 500, 900, 900, 900}))
+}
+
+// simulateGasLimitBump feeds the bump blocks using the given per mille of their
+// gas limit, on top of a base that keeps the limit of the parent, and returns
+// the gas limit of every block
+func simulateGasLimitBump(config *GasLimitBumpConfig, start *big.Int, utilizations []int64) []*big.Int {
+	limits := []*big.Int{start}
+	recent := []*ethTypes.Header{}
+	for _, perMille := range utilizations {
+		limit := limits[len(limits)-1]
+		used := new(big.Int).Mul(limit, big.NewInt(perMille))
+		used.Div(used, big.NewInt(1000))
+		recent = append([]*ethTypes.Header{{GasLimit: limit, GasUsed: used}}, recent...)
+		if uint64(len(recent)) > config.Blocks {
+			recent = recent[:config.Blocks]
+		}
+		limits = append(limits, bumpGasLimit(config, recent, limit))
+	}
+	return limits
+}
+
+func TestGasLimitBumpSustainedCongestion(t *testing.T) {
+	config := &GasLimitBumpConfig{Threshold: 900, Blocks: 3, Step: big.NewInt(4000), Ceiling: big.NewInt(5014000)}
+	limits := simulateGasLimitBump(config, big.NewInt(5000000), []int64{1000, 950, 900, 1000, 1000, 1000, 1000, 1000})
+	assert.Equal(t, []*big.Int{
+		big.NewInt(5000000), big.NewInt(5000000), big.NewInt(5000000),
+		// steps up once the third congested block completes the run
+		big.NewInt(5004000), big.NewInt(5008000), big.NewInt(5012000),
+		// and stops at the ceiling
+		big.NewInt(5014000), big.NewInt(5014000), big.NewInt(5014000),
+	}, limits)
+}
+
+func TestGasLimitBumpInterrupted(t *testing.T) {
+	config := &GasLimitBumpConfig{Threshold: 900, Blocks: 3, Step: big.NewInt(4000), Ceiling: big.NewInt(6000000)}
+	// a block below the threshold restarts the run
+	limits := simulateGasLimitBump(config, big.NewInt(5000000), []int64{1000, 1000, 899, 1000, 1000})
+	assert.Equal(t, big.NewInt(5000000), limits[len(limits)-1])
+}
+
+func TestGasLimitBumpBounded(t *testing.T) {
+	// a step beyond what the header verification accepts is cut down to it
+	config := &GasLimitBumpConfig{Threshold: 900, Blocks: 1, Step: big.NewInt(1000000), Ceiling: big.NewInt(6000000)}
+	limits := simulateGasLimitBump(config, big.NewInt(5000000), []int64{1000})
+	assert.Equal(t, boundGasLimit(big.NewInt(5000000), big.NewInt(6000000)), limits[1])
+	assert.Equal(t, 1, limits[1].Cmp(big.NewInt(5000000)))
+
+	// a higher limit of the base is kept
+	header := &ethTypes.Header{GasLimit: big.NewInt(5000000), GasUsed: big.NewInt(5000000)}
+	assert.Equal(t, big.NewInt(5004882), bumpGasLimit(config, []*ethTypes.Header{header}, big.NewInt(5004882)))
+}
+
+// simulateGasLimitPolicy commits empty blocks on top of a parent with the start
+// gas limit and returns the gas limit of every block
+func simulateGasLimitPolicy(config *Config, start *big.Int, blocks int) []*big.Int {
+	parent := ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(0), GasLimit: start, GasUsed: big.NewInt(0)})
+	limits := []*big.Int{start}
+	for i := 0; i < blocks; i++ {
+		limit := calcGasLimit(nil, config, parent)
+		limits = append(limits, limit)
+		parent = ethTypes.NewBlockWithHeader(&ethTypes.Header{
+			Number:   big.NewInt(int64(i + 1)),
+			GasLimit: limit,
+			GasUsed:  big.NewInt(0),
+		})
+	}
+	return limits
+}
+
+func TestGasLimitPolicyDefault(t *testing.T) {
+	// without a policy empty blocks lower the limit like core.CalcGasLimit
+	limits := simulateGasLimitPolicy(&Config{}, big.NewInt(10000000), 10)
+	for i := 1; i < len(limits); i++ {
+		parent := ethTypes.NewBlockWithHeader(&ethTypes.Header{GasLimit: limits[i-1], GasUsed: big.NewInt(0)})
+		assert.Equal(t, core.CalcGasLimit(parent), limits[i], "block %d", i)
+		assert.Equal(t, -1, limits[i].Cmp(limits[i-1]), "block %d", i)
+	}
+}
+
+func TestGasLimitPolicyFixed(t *testing.T) {
+	fixed := big.NewInt(8000000)
+	config := &Config{GasLimitPolicy: &GasLimitPolicyConfig{Fixed: fixed}}
+
+	// the fixed limit is kept whatever the usage
+	for _, limit := range simulateGasLimitPolicy(config, fixed, 10) {
+		assert.Equal(t, fixed, limit)
+	}
+
+	// a different limit of the parent moves towards it within the accepted change
+	limits := simulateGasLimitPolicy(config, big.NewInt(7900000), 20)
+	for i := 1; i < len(limits); i++ {
+		assert.Equal(t, limits[i], boundGasLimit(limits[i-1], limits[i]), "block %d", i)
+		assert.True(t, limits[i].Cmp(limits[i-1]) >= 0 && limits[i].Cmp(fixed) <= 0, "block %d", i)
+	}
+	assert.Equal(t, fixed, limits[len(limits)-1])
+}
+
+func TestGasLimitPolicyClamped(t *testing.T) {
+	// empty blocks lower the limit down to the minimum
+	min := big.NewInt(9950000)
+	config := &Config{GasLimitPolicy: &GasLimitPolicyConfig{Min: min}}
+	limits := simulateGasLimitPolicy(config, big.NewInt(10000000), 20)
+	assert.Equal(t, -1, limits[1].Cmp(limits[0]))
+	for i := 1; i < len(limits); i++ {
+		assert.True(t, limits[i].Cmp(min) >= 0, "block %d", i)
+	}
+	assert.Equal(t, min, limits[len(limits)-1])
+
+	// below the target core.CalcGasLimit raises the limit up to the maximum
+	max := big.NewInt(3010000)
+	config = &Config{GasLimitPolicy: &GasLimitPolicyConfig{Max: max}}
+	limits = simulateGasLimitPolicy(config, big.NewInt(3000000), 20)
+	assert.Equal(t, 1, limits[1].Cmp(limits[0]))
+	for i := 1; i < len(limits); i++ {
+		assert.True(t, limits[i].Cmp(max) <= 0, "block %d", i)
+	}
+	assert.Equal(t, max, limits[len(limits)-1])
+
+	// a parent above the maximum comes down within the accepted change
+	limits = simulateGasLimitPolicy(config, big.NewInt(3020000), 1)
+	assert.Equal(t, boundGasLimit(big.NewInt(3020000), max), limits[1])
+	assert.Equal(t, -1, limits[1].Cmp(big.NewInt(3020000)))
+}
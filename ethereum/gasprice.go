@@ -0,0 +1,180 @@
+package ethereum
+
+import (
+	"math/big"
+	"sort"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// fullBlockUtilization is the utilization in per mille from which a block counts as full
+const fullBlockUtilization = 900
+
+//----------------------------------------------------------------------
+// Adaptive gas price floor enforced in CheckTx
+
+// GasPriceFloorConfig configures a minimum gas price that rises after full blocks
+// and falls back after empty ones. Without a base fee the whole gas price goes to
+// the coinbase, so the floor is a minimum tip. It is derived from the committed
+// headers only.
+type GasPriceFloorConfig struct {
+	// Min is the floor after idle periods
+	Min *big.Int
+
+	// Step is the percentage the floor moves per full or empty block, at least 1 wei
+	Step uint64
+
+	// Window is the number of recent blocks replayed to compute the floor
+	Window uint64
+}
+
+// gasPriceFloor replays the recent headers, most recent first, starting from the minimum
+func gasPriceFloor(config *GasPriceFloorConfig, recent []*ethTypes.Header) *big.Int {
+	floor := new(big.Int).Set(config.Min)
+	for i := len(recent) - 1; i >= 0; i-- {
+		delta := new(big.Int).Mul(floor, new(big.Int).SetUint64(config.Step))
+		delta.Div(delta, big.NewInt(100))
+		if delta.Sign() == 0 {
+			delta.SetInt64(1)
+		}
+
+		switch header := recent[i]; {
+		case utilization(header) >= fullBlockUtilization:
+			floor.Add(floor, delta)
+		case header.GasUsed.Sign() == 0:
+			if floor.Sub(floor, delta); floor.Cmp(config.Min) < 0 {
+				floor.Set(config.Min)
+			}
+		}
+	}
+	return floor
+}
+
+// GasPriceFloor returns the minimum gas price accepted by CheckTx after the
+// latest block, or nil if no floor is configured
+func (b *Backend) GasPriceFloor() *big.Int {
+	config := b.emtConfig.GasPriceFloor
+	if config == nil {
+		return nil
+	}
+	blockchain := b.ethereum.BlockChain()
+	return gasPriceFloor(config, recentHeaders(blockchain, blockchain.CurrentBlock(), config.Window))
+}
+
+//----------------------------------------------------------------------
+// Gas price percentiles of the recent blocks for fee suggestions
+
+const defaultGasPriceOracleBlocks = 20
+
+var defaultGasPricePercentiles = []uint64{10, 50, 90}
+
+// GasPriceOracleConfig configures the gas price percentiles suggested to wallets.
+// Like the go-ethereum oracle it samples the lowest gas price of each recent
+// block with transactions. Zero values take the defaults.
+type GasPriceOracleConfig struct {
+	// Blocks is the number of recent blocks sampled
+	Blocks uint64
+
+	// Percentiles of the sampled prices, 10, 50 and 90 by default
+	Percentiles []uint64
+
+	// MinSamples is the number of sampled blocks below which the history is
+	// insufficient and every percentile is Min, at least 1
+	MinSamples uint64
+
+	// Min is the lowest gas price suggested at any percentile
+	Min *big.Int
+}
+
+// GasPricePercentile is the gas price at a percentile of the sampled prices
+type GasPricePercentile struct {
+	Percentile uint64   `json:"percentile"`
+	GasPrice   *big.Int `json:"gasPrice"`
+}
+
+// GasPriceCurve is the gas price at the configured percentiles
+type GasPriceCurve struct {
+	// number of blocks with transactions sampled
+	Samples int `json:"samples"`
+	// whether the history was insufficient and all percentiles are the minimum
+	Fallback    bool                 `json:"fallback"`
+	Percentiles []GasPricePercentile `json:"percentiles"`
+}
+
+type bigIntArray []*big.Int
+
+func (s bigIntArray) Len() int           { return len(s) }
+func (s bigIntArray) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// lowestGasPrice returns the lowest gas price paid in the block, nil if it is empty
+func lowestGasPrice(block *ethTypes.Block) *big.Int {
+	var lowest *big.Int
+	for _, tx := range block.Transactions() {
+		if lowest == nil || tx.GasPrice().Cmp(lowest) < 0 {
+			lowest = tx.GasPrice()
+		}
+	}
+	return lowest
+}
+
+// gasPriceCurve picks the percentiles of the sampled prices the same way as the
+// go-ethereum oracle, never below the minimum
+func gasPriceCurve(config *GasPriceOracleConfig, samples []*big.Int) *GasPriceCurve {
+	percentiles := config.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = defaultGasPricePercentiles
+	}
+	minSamples := config.MinSamples
+	if minSamples == 0 {
+		minSamples = 1
+	}
+	min := new(big.Int)
+	if config.Min != nil {
+		min.Set(config.Min)
+	}
+
+	sorted := make([]*big.Int, len(samples))
+	copy(sorted, samples)
+	sort.Sort(bigIntArray(sorted))
+
+	curve := &GasPriceCurve{
+		Samples:     len(sorted),
+		Fallback:    uint64(len(sorted)) < minSamples,
+		Percentiles: make([]GasPricePercentile, len(percentiles)),
+	}
+	for i, percentile := range percentiles {
+		price := min
+		if !curve.Fallback {
+			if sample := sorted[(len(sorted)-1)*int(percentile)/100]; sample.Cmp(min) > 0 {
+				price = sample
+			}
+		}
+		curve.Percentiles[i] = GasPricePercentile{Percentile: percentile, GasPrice: new(big.Int).Set(price)}
+	}
+	return curve
+}
+
+// GasPricePercentiles returns the gas price at the configured percentiles of the
+// lowest prices paid in the recent blocks, for slow, normal and fast fee options
+func (b *Backend) GasPricePercentiles() *GasPriceCurve {
+	config := b.emtConfig.GasPriceOracle
+	if config == nil {
+		config = &GasPriceOracleConfig{}
+	}
+	blocks := config.Blocks
+	if blocks == 0 {
+		blocks = defaultGasPriceOracleBlocks
+	}
+
+	blockchain := b.ethereum.BlockChain()
+	samples := []*big.Int{}
+	for _, header := range recentHeaders(blockchain, blockchain.CurrentBlock(), blocks) {
+		if block := blockchain.GetBlock(header.Hash(), header.Number.Uint64()); block != nil {
+			if price := lowestGasPrice(block); price != nil {
+				samples = append(samples, price)
+			}
+		}
+	}
+	return gasPriceCurve(config, samples)
+}
@@ -0,0 +1,98 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+var testGasPriceFloorConfig = &GasPriceFloorConfig{Min: big.NewInt(100), Step: 10, Window: 8}
+
+// simulateGasPriceFloor returns the floor after each of the blocks, using the given gas
+func simulateGasPriceFloor(config *GasPriceFloorConfig, gasUsed []int64) []*big.Int {
+	floors := []*big.Int{}
+	recent := []*ethTypes.Header{}
+	for _, used := range gasUsed {
+		recent = append([]*ethTypes.Header{{GasLimit: big.NewInt(1000), GasUsed: big.NewInt(used)}}, recent...)
+		if uint64(len(recent)) > config.Window {
+			recent = recent[:config.Window]
+		}
+		floors = append(floors, gasPriceFloor(config, recent))
+	}
+	return floors
+}
+
+func TestGasPriceFloorCongestion(t *testing.T) {
+	floors := simulateGasPriceFloor(testGasPriceFloorConfig, []int64{1000, 950, 900, 1000})
+	assert.Equal(t, []*big.Int{big.NewInt(110), big.NewInt(121), big.NewInt(133), big.NewInt(146)}, floors)
+}
+
+func TestGasPriceFloorIdle(t *testing.T) {
+	// falls back after empty blocks, never below the minimum
+	floors := simulateGasPriceFloor(testGasPriceFloorConfig, []int64{1000, 1000, 0, 0, 0})
+	assert.Equal(t, []*big.Int{big.NewInt(110), big.NewInt(121), big.NewInt(109), big.NewInt(100), big.NewInt(100)}, floors)
+}
+
+func TestGasPriceFloorPartialBlocks(t *testing.T) {
+	// blocks neither full nor empty keep the floor
+	floors := simulateGasPriceFloor(testGasPriceFloorConfig, []int64{1000, 500, 899})
+	assert.Equal(t, []*big.Int{big.NewInt(110), big.NewInt(110), big.NewInt(110)}, floors)
+}
+
+func TestGasPriceFloorWindow(t *testing.T) {
+	// congestion older than the window is forgotten
+	config := &GasPriceFloorConfig{Min: big.NewInt(100), Step: 10, Window: 2}
+	floors := simulateGasPriceFloor(config, []int64{1000, 1000, 500, 500})
+	assert.Equal(t, big.NewInt(100), floors[3])
+}
+
+func bigInts(values ...int64) []*big.Int {
+	ints := make([]*big.Int, len(values))
+	for i, value := range values {
+		ints[i] = big.NewInt(value)
+	}
+	return ints
+}
+
+// curvePrices returns the gas prices of the percentiles of the curve
+func curvePrices(curve *GasPriceCurve) []*big.Int {
+	prices := []*big.Int{}
+	for _, percentile := range curve.Percentiles {
+		prices = append(prices, percentile.GasPrice)
+	}
+	return prices
+}
+
+func TestGasPriceCurve(t *testing.T) {
+	config := &GasPriceOracleConfig{Min: big.NewInt(15)}
+	samples := bigInts(70, 10, 100, 20, 30, 90, 40, 60, 50, 80, 0)
+
+	curve := gasPriceCurve(config, samples)
+	assert.False(t, curve.Fallback)
+	assert.Equal(t, 11, curve.Samples)
+	assert.Equal(t, []uint64{10, 50, 90}, []uint64{
+		curve.Percentiles[0].Percentile, curve.Percentiles[1].Percentile, curve.Percentiles[2].Percentile,
+	})
+	// the 10th percentile is raised to the minimum
+	assert.Equal(t, bigInts(15, 50, 90), curvePrices(curve))
+
+	config.Percentiles = []uint64{0, 25, 100}
+	assert.Equal(t, bigInts(15, 20, 100), curvePrices(gasPriceCurve(config, samples)))
+}
+
+func TestGasPriceCurveInsufficientHistory(t *testing.T) {
+	config := &GasPriceOracleConfig{MinSamples: 3, Min: big.NewInt(5)}
+
+	curve := gasPriceCurve(config, bigInts(100, 200))
+	assert.True(t, curve.Fallback)
+	assert.Equal(t, bigInts(5, 5, 5), curvePrices(curve))
+
+	curve = gasPriceCurve(&GasPriceOracleConfig{}, nil)
+	assert.True(t, curve.Fallback)
+	assert.Equal(t, bigInts(0, 0, 0), curvePrices(curve))
+
+	assert.False(t, gasPriceCurve(config, bigInts(100, 200, 300)).Fallback)
+}
@@ -0,0 +1,28 @@
+package ethereum
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var errGenesisAllocNotFound = errors.New("genesis allocation not found")
+
+// WriteGenesisAlloc stores the account allocations of genesis, which go-ethereum
+// only keeps as state. An allocation stored earlier is kept.
+func WriteGenesisAlloc(db ethdb.Database, genesis *core.Genesis) error {
+	if readIndex(db, genesisAllocKey, new(core.GenesisAlloc)) {
+		return nil
+	}
+	return writeIndex(db, genesisAllocKey, genesis.Alloc)
+}
+
+// GenesisAlloc returns the account allocations of the loaded genesis
+func (b *Backend) GenesisAlloc() (core.GenesisAlloc, error) {
+	alloc := make(core.GenesisAlloc)
+	if !readIndex(b.ethereum.ChainDb(), genesisAllocKey, &alloc) {
+		return nil, errGenesisAllocNotFound
+	}
+	return alloc, nil
+}
@@ -0,0 +1,659 @@
+package ethereum
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var errContractNotFound = errors.New("contract creation not found")
+
+//----------------------------------------------------------------------
+// Per block indexes, built from the work when a block is committed
+
+// writeIndexes stores the indexes of a block that was inserted into the chain
+func (w *work) writeIndexes(blockchain *core.BlockChain, db ethdb.Database, config *Config,
+	chainConfig *params.ChainConfig, block *ethTypes.Block) error {
+	signer := ethTypes.MakeSigner(chainConfig, block.Number())
+	number := block.NumberU64()
+
+	addresses, err := w.touchedAddresses(signer)
+	if err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockAddressesPrefix, number, addresses); err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockRewardPrefix, number, w.blockReward); err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockStatsPrefix, number, w.stats()); err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockErrorsPrefix, number, w.execErrors); err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockLogCountsPrefix, number, w.logCounts()); err != nil {
+		return err
+	}
+	senderCounts, err := w.senderCounts(signer)
+	if err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockSenderCountsPrefix, number, senderCounts); err != nil {
+		return err
+	}
+	size, err := blockSize(block)
+	if err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockSizePrefix, number, size); err != nil {
+		return err
+	}
+
+	growth, err := w.stateGrowth(blockchain, addresses)
+	if err != nil {
+		return err
+	}
+	if err := writeBlockIndex(db, blockGrowthPrefix, number, growth); err != nil {
+		return err
+	}
+	if err := writeIndex(db, stateSizeKey, w.stateSize.grow(growth)); err != nil {
+		return err
+	}
+
+	if err := writeBlockIndex(db, blockRewardEventsPrefix, number, w.rewards); err != nil {
+		return err
+	}
+	if err := writeRewardHistory(db, number, w.rewards); err != nil {
+		return err
+	}
+
+	if config.AccountTxIndex {
+		if err := w.writeAccountTransactions(signer, db, block); err != nil {
+			return err
+		}
+	}
+	if config.ExecutionIndex {
+		if err := writeBlockIndex(db, blockSelfDestructsPrefix, number, w.selfDestructs); err != nil {
+			return err
+		}
+		if err := writeBlockIndex(db, blockSlotWritesPrefix, number, w.slotWrites); err != nil {
+			return err
+		}
+	}
+
+	for _, creation := range w.creations {
+		creation.BlockHash = block.Hash()
+		creation.BlockNumber = number
+		if err := writeIndex(db, addressIndexKey(contractCreationPrefix, creation.Address), creation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelfDestruct is a contract destroyed by a transaction
+type SelfDestruct struct {
+	Contract    common.Address `json:"contract"`
+	Beneficiary common.Address `json:"beneficiary"`
+	TxHash      common.Hash    `json:"transactionHash"`
+}
+
+// RewardHistoryEntry is the total reward credited to an address in a block
+type RewardHistoryEntry struct {
+	BlockNumber uint64   `json:"blockNumber"`
+	Amount      *big.Int `json:"amount"`
+}
+
+// RewardShare is the total reward credited to an address in a block
+type RewardShare struct {
+	Address common.Address `json:"address"`
+	Amount  *big.Int       `json:"amount"`
+}
+
+// rewardShares sums the rewards per beneficiary in order of the first reward
+func rewardShares(rewards []*RewardEvent) []*RewardShare {
+	shares := []*RewardShare{}
+	index := make(map[common.Address]*RewardShare)
+	for _, reward := range rewards {
+		if share, ok := index[reward.Address]; ok {
+			share.Amount.Add(share.Amount, reward.Amount)
+		} else {
+			share = &RewardShare{Address: reward.Address, Amount: new(big.Int).Set(reward.Amount)}
+			index[reward.Address] = share
+			shares = append(shares, share)
+		}
+	}
+	return shares
+}
+
+// writeRewardHistory appends the rewards of a block to the history of each
+// beneficiary. Like in appendAccountTransactions, entries of the same or later
+// blocks left by a rewound chain are dropped first.
+func writeRewardHistory(db ethdb.Database, number uint64, rewards []*RewardEvent) error {
+	for _, share := range rewardShares(rewards) {
+		addr := share.Address
+		var count uint64
+		readIndex(db, addressIndexKey(rewardHistoryPrefix, addr), &count)
+		for count > 0 {
+			last := new(RewardHistoryEntry)
+			if readIndex(db, addressListKey(rewardHistoryPrefix, addr, count-1), last) && last.BlockNumber < number {
+				break
+			}
+			count--
+		}
+
+		entry := &RewardHistoryEntry{BlockNumber: number, Amount: share.Amount}
+		if err := writeIndex(db, addressListKey(rewardHistoryPrefix, addr, count), entry); err != nil {
+			return err
+		}
+		if err := writeIndex(db, addressIndexKey(rewardHistoryPrefix, addr), count+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccountTransaction is a committed transaction sent or received by an address
+type AccountTransaction struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	TxHash      common.Hash `json:"transactionHash"`
+	TxIndex     uint64      `json:"transactionIndex"`
+}
+
+// writeAccountTransactions appends the transactions of a block to the sent list
+// of their senders and the received list of their recipients. The recipient of
+// a contract creation is the created contract.
+func (w *work) writeAccountTransactions(signer ethTypes.Signer, db ethdb.Database, block *ethTypes.Block) error {
+	sent := make(map[common.Address][]*AccountTransaction)
+	received := make(map[common.Address][]*AccountTransaction)
+	for i, tx := range w.transactions {
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+		entry := &AccountTransaction{
+			BlockNumber: block.NumberU64(),
+			BlockHash:   block.Hash(),
+			TxHash:      tx.Hash(),
+			TxIndex:     uint64(i),
+		}
+		sent[from] = append(sent[from], entry)
+
+		to := tx.To()
+		if to == nil && i < len(w.receipts) {
+			to = &w.receipts[i].ContractAddress
+		}
+		if to != nil {
+			received[*to] = append(received[*to], entry)
+		}
+	}
+
+	for addr, entries := range sent {
+		if err := appendAccountTransactions(db, sentTxsPrefix, addr, entries); err != nil {
+			return err
+		}
+	}
+	for addr, entries := range received {
+		if err := appendAccountTransactions(db, receivedTxsPrefix, addr, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendAccountTransactions appends the entries of a block to the list of addr.
+// Entries of the same or later blocks, left by a chain that was rewound, are
+// dropped first so the list stays ordered and has no duplicates.
+func appendAccountTransactions(db ethdb.Database, prefix []byte, addr common.Address, entries []*AccountTransaction) error {
+	var count uint64
+	readIndex(db, addressIndexKey(prefix, addr), &count)
+	for count > 0 {
+		last := new(AccountTransaction)
+		if readIndex(db, addressListKey(prefix, addr, count-1), last) && last.BlockNumber < entries[0].BlockNumber {
+			break
+		}
+		count--
+	}
+
+	for _, entry := range entries {
+		if err := writeIndex(db, addressListKey(prefix, addr, count), entry); err != nil {
+			return err
+		}
+		count++
+	}
+	return writeIndex(db, addressIndexKey(prefix, addr), count)
+}
+
+// ContractCreation links a contract to the transaction that deployed it
+type ContractCreation struct {
+	Address     common.Address `json:"address"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+}
+
+// contractCreated reports whether a creation transaction left a contract at addr.
+// Failed creations are reverted, while successful ones deploy code or, from
+// EIP158 on, bump the nonce of the new account.
+func contractCreated(statedb *state.StateDB, addr common.Address) bool {
+	return statedb.GetCodeSize(addr) > 0 || statedb.GetNonce(addr) > 0
+}
+
+// BlockStats are the aggregates of a committed block
+type BlockStats struct {
+	GasUsed      *big.Int `json:"gasUsed"`
+	Fees         *big.Int `json:"fees"`
+	TreasuryFees *big.Int `json:"treasuryFees"`
+	BurnedFees   *big.Int `json:"burnedFees"` // slashed from the validators
+	TxCount      int      `json:"txCount"`
+	Senders      int      `json:"senders"`   // distinct senders of the transactions
+	Creations    int      `json:"creations"` // contracts deployed by the transactions
+}
+
+func (w *work) stats() *BlockStats {
+	return &BlockStats{
+		GasUsed:      w.totalUsedGas,
+		Fees:         w.totalFees,
+		TreasuryFees: w.treasuryFees,
+		BurnedFees:   w.burnedFees,
+		TxCount:      len(w.transactions),
+		Senders:      len(w.senderGas), // charged for every delivered transaction
+		Creations:    len(w.creations), // failed creations are not recorded
+	}
+}
+
+// BlockSize is the size of the rlp encoding of a committed block and of its
+// transactions, for storage planning
+type BlockSize struct {
+	Size    uint64 `json:"size"`
+	TxCount int    `json:"txCount"`
+	// average size of the transactions, 0 for an empty block
+	AverageTxSize uint64 `json:"averageTxSize"`
+}
+
+func blockSize(block *ethTypes.Block) (*BlockSize, error) {
+	encoded, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return nil, err
+	}
+	size := &BlockSize{Size: uint64(len(encoded)), TxCount: len(block.Transactions())}
+	if size.TxCount == 0 {
+		return size, nil
+	}
+	var txsSize uint64
+	for _, tx := range block.Transactions() {
+		encoded, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			return nil, err
+		}
+		txsSize += uint64(len(encoded))
+	}
+	size.AverageTxSize = txsSize / uint64(size.TxCount)
+	return size, nil
+}
+
+// logCounts counts the logs of the block per emitting contract
+func (w *work) logCounts() map[common.Address]uint64 {
+	counts := make(map[common.Address]uint64)
+	for _, log := range w.allLogs {
+		counts[log.Address]++
+	}
+	return counts
+}
+
+// senderCounts counts the transactions of the block per sender
+func (w *work) senderCounts(signer ethTypes.Signer) (map[common.Address]uint64, error) {
+	counts := make(map[common.Address]uint64)
+	for _, tx := range w.transactions {
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		counts[from]++
+	}
+	return counts, nil
+}
+
+// StateGrowth is the change of the state trie caused by a block
+type StateGrowth struct {
+	AccountsAdded   int `json:"accountsAdded"`
+	AccountsRemoved int `json:"accountsRemoved"`
+	// CodeBytes is the size of the code of added minus removed accounts,
+	// a rough measure of the storage growth
+	CodeBytes int `json:"codeBytes"`
+}
+
+// stateGrowth compares the touched addresses and the reward beneficiaries
+// between the parent state and the committed state, instead of scanning the trie.
+// Accounts only reached through internal calls are not seen.
+func (w *work) stateGrowth(blockchain *core.BlockChain, addresses []common.Address) (*StateGrowth, error) {
+	parentState, err := blockchain.StateAt(w.parent.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[common.Address]bool)
+	growth := new(StateGrowth)
+	compare := func(addr common.Address) {
+		if seen[addr] {
+			return
+		}
+		seen[addr] = true
+
+		existed, exists := parentState.Exist(addr), w.state.Exist(addr)
+		switch {
+		case !existed && exists:
+			growth.AccountsAdded++
+			growth.CodeBytes += w.state.GetCodeSize(addr)
+		case existed && !exists:
+			growth.AccountsRemoved++
+			growth.CodeBytes -= parentState.GetCodeSize(addr)
+		}
+	}
+
+	for _, addr := range addresses {
+		compare(addr)
+	}
+	for _, reward := range w.rewards {
+		compare(reward.Address)
+	}
+	return growth, nil
+}
+
+// StateSize is the size of the state, summed up from the genesis allocation
+// and the state growth of every block
+type StateSize struct {
+	Accounts  uint64 `json:"accounts"`
+	CodeBytes uint64 `json:"codeBytes"`
+}
+
+// readStateSize returns the size of the state of the latest block. It starts
+// from the genesis allocation on new chains, older chains count their state
+// once, see initStateSize.
+func readStateSize(db ethdb.Database) *StateSize {
+	size := new(StateSize)
+	if readIndex(db, stateSizeKey, size) {
+		return size
+	}
+
+	alloc := make(core.GenesisAlloc)
+	readIndex(db, genesisAllocKey, &alloc)
+	for _, account := range alloc {
+		size.Accounts++
+		size.CodeBytes += uint64(len(account.Code))
+	}
+	return size
+}
+
+// initStateSize stores the size of the state of the latest block on chains with
+// blocks committed before the size was tracked, counting the accounts of the
+// state once. Without it the size would only start from the genesis allocation.
+func initStateSize(blockchain *core.BlockChain, db ethdb.Database) error {
+	if blockchain.CurrentBlock().NumberU64() == 0 || readIndex(db, stateSizeKey, new(StateSize)) {
+		return nil
+	}
+	statedb, err := blockchain.State()
+	if err != nil {
+		return err
+	}
+	size := new(StateSize)
+	for _, account := range statedb.RawDump().Accounts {
+		size.Accounts++
+		size.CodeBytes += uint64(len(account.Code) / 2) // hex encoded
+	}
+	log.Info("Counted the accounts of the state", "number", blockchain.CurrentBlock().Number(), "accounts", size.Accounts)
+	return writeIndex(db, stateSizeKey, size)
+}
+
+// grow returns the size after the growth
+func (s *StateSize) grow(growth *StateGrowth) *StateSize {
+	next := &StateSize{
+		Accounts:  s.Accounts + uint64(growth.AccountsAdded),
+		CodeBytes: s.CodeBytes,
+	}
+	next.Accounts = subFloor(next.Accounts, uint64(growth.AccountsRemoved))
+	if growth.CodeBytes >= 0 {
+		next.CodeBytes += uint64(growth.CodeBytes)
+	} else {
+		next.CodeBytes = subFloor(next.CodeBytes, uint64(-growth.CodeBytes))
+	}
+	return next
+}
+
+// subFloor returns a - b, at least 0
+func subFloor(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// touchedAddresses returns the distinct senders, recipients, created contracts
+// and log emitters of the work in order of first appearance. Failed creations
+// deployed nothing and are left out.
+func (w *work) touchedAddresses(signer ethTypes.Signer) ([]common.Address, error) {
+	seen := make(map[common.Address]bool)
+	addresses := []common.Address{}
+	add := func(addr common.Address) {
+		if !seen[addr] {
+			seen[addr] = true
+			addresses = append(addresses, addr)
+		}
+	}
+
+	for i, tx := range w.transactions {
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		add(from)
+
+		if to := tx.To(); to != nil {
+			add(*to)
+		} else if contractCreated(w.state, w.receipts[i].ContractAddress) {
+			add(w.receipts[i].ContractAddress)
+		}
+
+		for _, log := range w.receipts[i].Logs {
+			add(log.Address)
+		}
+	}
+	return addresses, nil
+}
+
+//----------------------------------------------------------------------
+// Index queries
+
+// ContractCreation returns the transaction and block that deployed the contract at addr
+func (b *Backend) ContractCreation(addr common.Address) (*ContractCreation, error) {
+	creation := new(ContractCreation)
+	if !readIndex(b.ethereum.ChainDb(), addressIndexKey(contractCreationPrefix, addr), creation) {
+		return nil, errContractNotFound
+	}
+	return creation, nil
+}
+
+// RewardHistory returns up to limit reward entries of addr, oldest first,
+// skipping the first offset entries
+func (b *Backend) RewardHistory(addr common.Address, offset, limit uint64) []*RewardHistoryEntry {
+	db := b.ethereum.ChainDb()
+
+	var count uint64
+	readIndex(db, addressIndexKey(rewardHistoryPrefix, addr), &count)
+
+	entries := []*RewardHistoryEntry{}
+	for i := offset; i < count && uint64(len(entries)) < limit; i++ {
+		entry := new(RewardHistoryEntry)
+		if readIndex(db, addressListKey(rewardHistoryPrefix, addr, i), entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SentTransactions returns up to limit committed transactions sent by addr,
+// oldest first, skipping the first offset entries
+func (b *Backend) SentTransactions(addr common.Address, offset, limit uint64) []*AccountTransaction {
+	return b.accountTransactions(sentTxsPrefix, addr, offset, limit)
+}
+
+// ReceivedTransactions returns up to limit committed transactions received by
+// addr, oldest first, skipping the first offset entries
+func (b *Backend) ReceivedTransactions(addr common.Address, offset, limit uint64) []*AccountTransaction {
+	return b.accountTransactions(receivedTxsPrefix, addr, offset, limit)
+}
+
+// accountTransactions pages through the list of addr. Entries of blocks that
+// are no longer canonical after a rewind are skipped.
+func (b *Backend) accountTransactions(prefix []byte, addr common.Address, offset, limit uint64) []*AccountTransaction {
+	db := b.ethereum.ChainDb()
+
+	var count uint64
+	readIndex(db, addressIndexKey(prefix, addr), &count)
+
+	entries := []*AccountTransaction{}
+	for i := offset; i < count && uint64(len(entries)) < limit; i++ {
+		entry := new(AccountTransaction)
+		if readIndex(db, addressListKey(prefix, addr, i), entry) && core.GetCanonicalHash(db, entry.BlockNumber) == entry.BlockHash {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// RewardDistribution is the economic outcome of a committed block
+type RewardDistribution struct {
+	BlockReward *big.Int `json:"blockReward"`
+	// fees paid by the transactions of the block, from the receipts
+	Fees *big.Int `json:"fees"`
+	// taken out of the supply by the slashes of the block
+	Burned *big.Int `json:"burned"`
+
+	Events     []*RewardEvent `json:"events"`
+	Recipients []*RewardShare `json:"recipients"`
+}
+
+// RewardDistribution reconstructs the rewards of the given committed block from
+// its reward events and receipts
+func (b *Backend) RewardDistribution(number uint64) (*RewardDistribution, error) {
+	db := b.ethereum.ChainDb()
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+
+	distribution := &RewardDistribution{BlockReward: new(big.Int), Fees: new(big.Int), Burned: new(big.Int)}
+	if err := readBlockIndex(db, blockRewardPrefix, number, distribution.BlockReward); err != nil {
+		return nil, err
+	}
+	if err := readBlockIndex(db, blockRewardEventsPrefix, number, &distribution.Events); err != nil {
+		return nil, err
+	}
+	if stats, err := b.BlockStats(number); err == nil && stats.BurnedFees != nil {
+		distribution.Burned = stats.BurnedFees
+	}
+
+	receipts := core.GetBlockReceipts(db, block.Hash(), number)
+	for i, tx := range block.Transactions() {
+		if i < len(receipts) {
+			distribution.Fees.Add(distribution.Fees, new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()))
+		}
+	}
+
+	distribution.Recipients = rewardShares(distribution.Events)
+	return distribution, nil
+}
+
+// BlockStats returns the aggregates of the given committed block
+func (b *Backend) BlockStats(number uint64) (*BlockStats, error) {
+	stats := new(BlockStats)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockStatsPrefix, number, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// BlockSize returns the size of the given committed block and the average size
+// of its transactions
+func (b *Backend) BlockSize(number uint64) (*BlockSize, error) {
+	size := new(BlockSize)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockSizePrefix, number, size); err != nil {
+		return nil, err
+	}
+	return size, nil
+}
+
+// LogCounts returns the number of logs each contract emitted in the committed
+// block with the given number
+func (b *Backend) LogCounts(number uint64) (map[common.Address]uint64, error) {
+	counts := make(map[common.Address]uint64)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockLogCountsPrefix, number, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// SenderCounts returns the number of transactions each sender sent in the
+// committed block with the given number
+func (b *Backend) SenderCounts(number uint64) (map[common.Address]uint64, error) {
+	counts := make(map[common.Address]uint64)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockSenderCountsPrefix, number, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// StateSize returns the size of the state of the latest committed block
+func (b *Backend) StateSize() *StateSize {
+	return readStateSize(b.ethereum.ChainDb())
+}
+
+// StateGrowth returns the change of the state caused by the given committed block
+func (b *Backend) StateGrowth(number uint64) (*StateGrowth, error) {
+	growth := new(StateGrowth)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockGrowthPrefix, number, growth); err != nil {
+		return nil, err
+	}
+	return growth, nil
+}
+
+// ExecutionErrors returns the number of failed transactions of the given
+// committed block per vm error category
+func (b *Backend) ExecutionErrors(number uint64) (map[string]uint64, error) {
+	counts := make(map[string]uint64)
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockErrorsPrefix, number, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// SelfDestructs returns the contracts destroyed in the given committed block,
+// if it was committed with the execution index
+func (b *Backend) SelfDestructs(number uint64) ([]*SelfDestruct, error) {
+	destructs := []*SelfDestruct{}
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockSelfDestructsPrefix, number, &destructs); err != nil {
+		return nil, err
+	}
+	return destructs, nil
+}
+
+// BlockAddresses returns the distinct addresses that appeared as sender,
+// recipient, created contract or log emitter in the given committed block
+func (b *Backend) BlockAddresses(number uint64) ([]common.Address, error) {
+	var addresses []common.Address
+	if err := readBlockIndex(b.ethereum.ChainDb(), blockAddressesPrefix, number, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
@@ -0,0 +1,80 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func TestAppendAccountTransactionsAfterRewind(t *testing.T) {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		t.Fatalf("Error creating database %v", err)
+	}
+	addr := common.Address{1}
+	entry := func(number uint64, tx byte) *AccountTransaction {
+		return &AccountTransaction{BlockNumber: number, TxHash: common.Hash{tx}}
+	}
+	list := func() []*AccountTransaction {
+		var count uint64
+		readIndex(db, addressIndexKey(sentTxsPrefix, addr), &count)
+		entries := []*AccountTransaction{}
+		for i := uint64(0); i < count; i++ {
+			e := new(AccountTransaction)
+			readIndex(db, addressListKey(sentTxsPrefix, addr, i), e)
+			entries = append(entries, e)
+		}
+		return entries
+	}
+
+	for _, entries := range [][]*AccountTransaction{
+		{entry(1, 1)},
+		{entry(2, 2), entry(2, 3)},
+		{entry(3, 4)},
+	} {
+		assert.Nil(t, appendAccountTransactions(db, sentTxsPrefix, addr, entries))
+	}
+	assert.Equal(t, []*AccountTransaction{entry(1, 1), entry(2, 2), entry(2, 3), entry(3, 4)}, list())
+
+	// the chain is rewound and block 2 is committed again
+	assert.Nil(t, appendAccountTransactions(db, sentTxsPrefix, addr, []*AccountTransaction{entry(2, 5)}))
+	assert.Equal(t, []*AccountTransaction{entry(1, 1), entry(2, 5)}, list())
+}
+
+func TestWriteRewardHistoryAfterRewind(t *testing.T) {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		t.Fatalf("Error creating database %v", err)
+	}
+	addr := common.Address{1}
+	reward := func(amount int64) []*RewardEvent {
+		return []*RewardEvent{{Address: addr, Amount: big.NewInt(amount), Kind: RewardKindBlock}}
+	}
+	entry := func(number uint64, amount int64) *RewardHistoryEntry {
+		return &RewardHistoryEntry{BlockNumber: number, Amount: big.NewInt(amount)}
+	}
+	list := func() []*RewardHistoryEntry {
+		var count uint64
+		readIndex(db, addressIndexKey(rewardHistoryPrefix, addr), &count)
+		entries := []*RewardHistoryEntry{}
+		for i := uint64(0); i < count; i++ {
+			e := new(RewardHistoryEntry)
+			readIndex(db, addressListKey(rewardHistoryPrefix, addr, i), e)
+			entries = append(entries, e)
+		}
+		return entries
+	}
+
+	for number := uint64(1); number <= 3; number++ {
+		assert.Nil(t, writeRewardHistory(db, number, reward(int64(number)*10)))
+	}
+	assert.Equal(t, []*RewardHistoryEntry{entry(1, 10), entry(2, 20), entry(3, 30)}, list())
+
+	// the chain is rewound and block 2 is committed again
+	assert.Nil(t, writeRewardHistory(db, 2, reward(25)))
+	assert.Equal(t, []*RewardHistoryEntry{entry(1, 10), entry(2, 25)}, list())
+}
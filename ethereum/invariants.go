@@ -0,0 +1,56 @@
+package ethereum
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+//----------------------------------------------------------------------
+// Application level invariants checked before every commit
+
+// Invariant checks the state of a block after its rewards were accumulated and
+// returns an error if it is violated. It must not modify the state and must be
+// deterministic, it decides whether the block is committed.
+type Invariant func(header *ethTypes.Header, statedb *state.StateDB) error
+
+type namedInvariant struct {
+	name  string
+	check Invariant
+}
+
+// InvariantError is the violation of a registered invariant that aborted a commit
+type InvariantError struct {
+	Name string
+	Err  error
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("invariant %s violated: %v", e.Name, e.Err)
+}
+
+func (p *pending) registerInvariant(name string, check Invariant) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.invariants = append(p.invariants, namedInvariant{name, check})
+}
+
+// checkInvariants runs the invariants in the order they were registered and
+// returns the first violation
+func (w *work) checkInvariants(invariants []namedInvariant) error {
+	for _, invariant := range invariants {
+		if err := invariant.check(w.header, w.state); err != nil {
+			return &InvariantError{Name: invariant.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// RegisterInvariant adds an invariant checked on every block before it is
+// committed. A violation aborts the commit, so every validator has to run the
+// same invariants.
+func (b *Backend) RegisterInvariant(name string, check Invariant) {
+	b.pending.registerInvariant(name, check)
+}
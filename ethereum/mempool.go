@@ -0,0 +1,161 @@
+package ethereum
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var errTxNotInPool = errors.New("transaction is not pending in the pool")
+
+// mempoolWindow is the number of recent blocks averaged for the throughput
+const mempoolWindow = 16
+
+// MempoolPosition estimates when a pending transaction of the pool is included
+type MempoolPosition struct {
+	// transactions and their gas expected to be included first
+	TxsAhead int      `json:"txsAhead"`
+	GasAhead *big.Int `json:"gasAhead"`
+	// number of blocks until inclusion, 1 is the next block, if every block is
+	// filled up to the gas limit of the pending block
+	Blocks uint64 `json:"blocks"`
+	// average gas used by the recent blocks, which tells how full they are
+	AverageGasUsed *big.Int `json:"averageGasUsed"`
+}
+
+// MempoolPosition estimates the inclusion of the pending pool transaction with
+// the given hash. Transactions paying a higher gas price and the earlier
+// transactions of the same sender are expected to go first, filling blocks up
+// to the current gas limit.
+func (b *Backend) MempoolPosition(hash common.Hash) (*MempoolPosition, error) {
+	pending, err := b.ethereum.TxPool().Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var target *ethTypes.Transaction
+	var sender common.Address
+	for from, txs := range pending {
+		for _, tx := range txs {
+			if tx.Hash() == hash {
+				target, sender = tx, from
+			}
+		}
+	}
+	if target == nil {
+		return nil, errTxNotInPool
+	}
+
+	position := &MempoolPosition{GasAhead: new(big.Int)}
+	for from, txs := range pending {
+		for _, tx := range txs {
+			ahead := tx.GasPrice().Cmp(target.GasPrice()) > 0
+			if from == sender {
+				ahead = tx.Nonce() < target.Nonce()
+			}
+			if ahead {
+				position.TxsAhead++
+				position.GasAhead.Add(position.GasAhead, tx.Gas())
+			}
+		}
+	}
+
+	capacity := b.pending.blockGasLimit()
+	if capacity.Sign() > 0 {
+		needed := new(big.Int).Add(position.GasAhead, target.Gas())
+		blocks := new(big.Int).Div(new(big.Int).Sub(needed, big.NewInt(1)), capacity)
+		position.Blocks = blocks.Uint64() + 1
+	}
+
+	headers := recentHeaders(b.ethereum.BlockChain(), b.ethereum.BlockChain().CurrentBlock(), mempoolWindow)
+	position.AverageGasUsed = new(big.Int)
+	for _, header := range headers {
+		position.AverageGasUsed.Add(position.AverageGasUsed, header.GasUsed)
+	}
+	position.AverageGasUsed.Div(position.AverageGasUsed, big.NewInt(int64(len(headers))))
+	return position, nil
+}
+
+//----------------------------------------------------------------------
+// Inclusion probability for fee suggestions
+
+// InclusionEstimate is the chance that a transaction paying GasPrice is included
+// in the next block
+type InclusionEstimate struct {
+	GasPrice    *big.Int `json:"gasPrice"`
+	Probability float64  `json:"probability"`
+	// gas of the pending pool transactions paying more
+	GasAhead *big.Int `json:"gasAhead"`
+}
+
+// blockFees is what a recent block tells about the gas price it took to get in
+type blockFees struct {
+	// lowest gas price included, nil for an empty block
+	minGasPrice *big.Int
+	full        bool
+}
+
+// takes reports whether the block would have had room for a transaction paying
+// gasPrice: it was not full, or the sender outbid the cheapest transaction in it
+func (f blockFees) takes(gasPrice *big.Int) bool {
+	return !f.full || f.minGasPrice == nil || gasPrice.Cmp(f.minGasPrice) >= 0
+}
+
+// inclusionProbability is the share of the recent blocks that would have taken
+// a transaction paying gasPrice, scaled down by how far the pending pool
+// transactions paying more already overfill the next block. It never falls as
+// the gas price rises.
+func inclusionProbability(gasPrice *big.Int, recent []blockFees, gasAhead, gas, gasLimit *big.Int) float64 {
+	history := 1.0
+	if len(recent) > 0 {
+		taken := 0
+		for _, fees := range recent {
+			if fees.takes(gasPrice) {
+				taken++
+			}
+		}
+		history = float64(taken) / float64(len(recent))
+	}
+
+	needed := new(big.Int).Add(gasAhead, gas)
+	if needed.Cmp(gasLimit) <= 0 {
+		return history
+	}
+	room, _ := new(big.Rat).SetFrac(gasLimit, needed).Float64()
+	return history * room
+}
+
+// InclusionProbability estimates the chance that a plain transfer paying the
+// given gas price is included in the next block, from the lowest prices of the
+// recent full blocks and the pending pool transactions that outbid it
+func (b *Backend) InclusionProbability(gasPrice *big.Int) (*InclusionEstimate, error) {
+	pending, err := b.ethereum.TxPool().Pending()
+	if err != nil {
+		return nil, err
+	}
+	estimate := &InclusionEstimate{GasPrice: gasPrice, GasAhead: new(big.Int)}
+	for _, txs := range pending {
+		for _, tx := range txs {
+			if tx.GasPrice().Cmp(gasPrice) > 0 {
+				estimate.GasAhead.Add(estimate.GasAhead, tx.Gas())
+			}
+		}
+	}
+
+	blockchain := b.ethereum.BlockChain()
+	headers := recentHeaders(blockchain, blockchain.CurrentBlock(), mempoolWindow)
+	recent := make([]blockFees, 0, len(headers))
+	for _, header := range headers {
+		fees := blockFees{full: utilization(header) >= fullBlockUtilization}
+		if block := blockchain.GetBlock(header.Hash(), header.Number.Uint64()); block != nil {
+			fees.minGasPrice = lowestGasPrice(block)
+		}
+		recent = append(recent, fees)
+	}
+
+	estimate.Probability = inclusionProbability(gasPrice, recent, estimate.GasAhead, params.TxGas, b.pending.blockGasLimit())
+	return estimate, nil
+}
@@ -0,0 +1,54 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// full blocks whose cheapest transactions paid 10, 20 and 30, and one with room left
+var testFeeHistory = []blockFees{
+	{minGasPrice: big.NewInt(10), full: true},
+	{minGasPrice: big.NewInt(20), full: true},
+	{minGasPrice: big.NewInt(30), full: true},
+	{minGasPrice: big.NewInt(50), full: false},
+}
+
+func TestInclusionProbabilityHistory(t *testing.T) {
+	gasLimit := big.NewInt(1000000)
+	probability := func(price int64) float64 {
+		return inclusionProbability(big.NewInt(price), testFeeHistory, new(big.Int), big.NewInt(21000), gasLimit)
+	}
+	assert.Equal(t, 0.25, probability(5))
+	assert.Equal(t, 0.5, probability(10))
+	assert.Equal(t, 0.75, probability(25))
+	assert.Equal(t, 1.0, probability(30))
+	assert.Equal(t, 1.0, probability(100))
+}
+
+func TestInclusionProbabilityMonotonic(t *testing.T) {
+	// the pool transactions outbidding lower prices overfill the next block
+	gasLimit := big.NewInt(100000)
+	poolPrices := []int64{40, 35, 30, 25, 20, 15}
+	previous := 0.0
+	for price := int64(0); price <= 60; price++ {
+		gasAhead := new(big.Int)
+		for _, poolPrice := range poolPrices {
+			if poolPrice > price {
+				gasAhead.Add(gasAhead, big.NewInt(30000))
+			}
+		}
+		p := inclusionProbability(big.NewInt(price), testFeeHistory, gasAhead, big.NewInt(21000), gasLimit)
+		assert.True(t, p >= previous, "probability fell at gas price %d", price)
+		assert.True(t, p >= 0 && p <= 1)
+		previous = p
+	}
+	assert.Equal(t, 1.0, previous)
+}
+
+func TestInclusionProbabilityNoHistory(t *testing.T) {
+	gasAhead := big.NewInt(79000)
+	p := inclusionProbability(big.NewInt(1), nil, gasAhead, big.NewInt(21000), big.NewInt(50000))
+	assert.Equal(t, 0.5, p)
+}
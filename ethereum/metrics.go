@@ -0,0 +1,58 @@
+package ethereum
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//----------------------------------------------------------------------
+// Prometheus metrics of the block processing, registered with the default
+// registry. They are updated under the pending mutex and cost an atomic
+// update each, so they never hold it up.
+
+var (
+	txsDeliveredCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ethermint",
+		Name:      "transactions_delivered_total",
+		Help:      "Number of transactions included in the pending block.",
+	})
+	txsFailedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ethermint",
+		Name:      "transactions_failed_total",
+		Help:      "Number of included transactions whose execution failed.",
+	})
+	blockGasUsedHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ethermint",
+		Name:      "block_gas_used",
+		Help:      "Gas used by the committed blocks.",
+		Buckets:   prometheus.ExponentialBuckets(21000, 4, 10),
+	})
+	blockCommitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ethermint",
+		Name:      "block_commit_seconds",
+		Help:      "Time taken to commit a block, from the state commit to the written indexes.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	stateCommitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ethermint",
+		Name:      "state_commit_seconds",
+		Help:      "Time taken to commit the state of a block.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		txsDeliveredCounter,
+		txsFailedCounter,
+		blockGasUsedHistogram,
+		blockCommitSeconds,
+		stateCommitSeconds,
+	)
+}
+
+// observeSince records the seconds passed since start
+func observeSince(histogram prometheus.Histogram, start time.Time) {
+	histogram.Observe(time.Since(start).Seconds())
+}
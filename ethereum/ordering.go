@@ -0,0 +1,117 @@
+package ethereum
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+//----------------------------------------------------------------------
+// Reordering the transactions of a block by nonce
+
+// nonceOrder sorts transactions of one sender by nonce
+type nonceOrder []*ethTypes.Transaction
+
+func (s nonceOrder) Len() int           { return len(s) }
+func (s nonceOrder) Less(i, j int) bool { return s[i].Nonce() < s[j].Nonce() }
+func (s nonceOrder) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// sortByNonce returns the transactions with those of every sender sorted by
+// nonce. The transactions of a sender take the positions its transactions had
+// in the delivery order, so the order between senders is the one tendermint
+// agreed on and the result is the same on every validator. Transactions with
+// an invalid signature keep their position.
+func sortByNonce(signer ethTypes.Signer, txs []*ethTypes.Transaction) []*ethTypes.Transaction {
+	positions := make(map[common.Address][]int)
+	var senders []common.Address
+	for i, tx := range txs {
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		if _, ok := positions[from]; !ok {
+			senders = append(senders, from)
+		}
+		positions[from] = append(positions[from], i)
+	}
+
+	sorted := make([]*ethTypes.Transaction, len(txs))
+	copy(sorted, txs)
+	for _, from := range senders {
+		group := make(nonceOrder, len(positions[from]))
+		for j, i := range positions[from] {
+			group[j] = txs[i]
+		}
+		sort.Stable(group)
+		for j, i := range positions[from] {
+			sorted[i] = group[j]
+		}
+	}
+	return sorted
+}
+
+// canonicalOrder sorts transactions by sender, nonce and hash. Transactions
+// with an invalid signature go last.
+type canonicalOrder struct {
+	txs     []*ethTypes.Transaction
+	senders [][]byte // nil for an invalid signature
+}
+
+func (s *canonicalOrder) Len() int { return len(s.txs) }
+func (s *canonicalOrder) Swap(i, j int) {
+	s.txs[i], s.txs[j] = s.txs[j], s.txs[i]
+	s.senders[i], s.senders[j] = s.senders[j], s.senders[i]
+}
+func (s *canonicalOrder) Less(i, j int) bool {
+	if (s.senders[i] == nil) != (s.senders[j] == nil) {
+		return s.senders[j] == nil
+	}
+	if c := bytes.Compare(s.senders[i], s.senders[j]); c != 0 {
+		return c < 0
+	}
+	if s.txs[i].Nonce() != s.txs[j].Nonce() {
+		return s.txs[i].Nonce() < s.txs[j].Nonce()
+	}
+	hi, hj := s.txs[i].Hash(), s.txs[j].Hash()
+	return bytes.Compare(hi[:], hj[:]) < 0
+}
+
+// sortCanonical returns the transactions sorted by sender, nonce and hash. The
+// result only depends on the set of transactions, not on the delivery order,
+// so a block pulled again from the mempool in another order is reproduced.
+func sortCanonical(signer ethTypes.Signer, txs []*ethTypes.Transaction) []*ethTypes.Transaction {
+	order := &canonicalOrder{
+		txs:     make([]*ethTypes.Transaction, len(txs)),
+		senders: make([][]byte, len(txs)),
+	}
+	copy(order.txs, txs)
+	for i, tx := range txs {
+		if from, err := ethTypes.Sender(signer, tx); err == nil {
+			order.senders[i] = from.Bytes()
+		}
+	}
+	sort.Sort(order)
+	return order.txs
+}
+
+// DeliverTxsByNonce applies the transactions to the pending block like
+// DeliverTxs after sorting those of every sender by nonce, or all of them in
+// the canonical order if configured. It returns the transactions in the order
+// they were applied with their receipts or errors.
+func (b *Backend) DeliverTxsByNonce(txs []*ethTypes.Transaction) ([]*ethTypes.Transaction, []*ethTypes.Receipt, []error) {
+	chainConfig := b.ChainConfig()
+	next := new(big.Int).Add(b.ethereum.BlockChain().CurrentBlock().Number(), big.NewInt(1))
+	signer := ethTypes.MakeSigner(chainConfig, next)
+
+	var sorted []*ethTypes.Transaction
+	if b.emtConfig.CanonicalTxOrder {
+		sorted = sortCanonical(signer, txs)
+	} else {
+		sorted = sortByNonce(signer, txs)
+	}
+	receipts, errs := b.DeliverTxs(sorted)
+	return sorted, receipts, errs
+}
@@ -0,0 +1,61 @@
+package ethereum
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSortByNonce(t *testing.T) {
+	signer := ethTypes.HomesteadSigner{}
+	_, a := signedTransfers(t, 3)
+	_, b := signedTransfers(t, 2)
+	unsigned := ethTypes.NewTransaction(0, common.Address{1}, big.NewInt(0), big.NewInt(21000), big.NewInt(10), nil)
+
+	// the senders keep their positions, only their own nonces are sorted
+	txs := []*ethTypes.Transaction{a[2], b[1], unsigned, a[0], b[0], a[1]}
+	expected := []*ethTypes.Transaction{a[0], b[0], unsigned, a[1], b[1], a[2]}
+	assert.Equal(t, expected, sortByNonce(signer, txs))
+
+	// the input is left as delivered
+	assert.Equal(t, a[2], txs[0])
+}
+
+func TestSortCanonicalStable(t *testing.T) {
+	signer := ethTypes.HomesteadSigner{}
+	var txs []*ethTypes.Transaction
+	for i := 0; i < 3; i++ {
+		_, sent := signedTransfers(t, 4)
+		txs = append(txs, sent...)
+	}
+	txs = append(txs, ethTypes.NewTransaction(0, common.Address{1}, big.NewInt(0), big.NewInt(21000), big.NewInt(10), nil))
+
+	expected := sortCanonical(signer, txs)
+	assert.Equal(t, len(txs), len(expected))
+	for i := 1; i < len(expected)-1; i++ {
+		prev, _ := ethTypes.Sender(signer, expected[i-1])
+		from, _ := ethTypes.Sender(signer, expected[i])
+		if prev == from {
+			assert.Equal(t, expected[i-1].Nonce()+1, expected[i].Nonce())
+		}
+	}
+	// the transaction without a valid signature goes last
+	assert.Equal(t, txs[len(txs)-1], expected[len(expected)-1])
+
+	// the same set pulled in any order is sorted the same way
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := make([]*ethTypes.Transaction, len(txs))
+		for j, k := range rnd.Perm(len(txs)) {
+			shuffled[j] = txs[k]
+		}
+		assert.Equal(t, expected, sortCanonical(signer, shuffled))
+		// the per sender order only depends on the delivery order
+		assert.Equal(t, sortByNonce(signer, shuffled), sortByNonce(signer, shuffled))
+	}
+}
@@ -1,8 +1,10 @@
 package ethereum
 
 import (
+	"errors"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -10,7 +12,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 
@@ -23,21 +27,99 @@ import (
 // pending manages concurrent access to the intermediate work object
 
 type pending struct {
-	mtx  *sync.Mutex
-	work *work
+	mtx    *sync.Mutex
+	work   *work
+	config *Config
+
+	// database for the ethermint block indexes
+	chainDb ethdb.Database
+
+	// rules of the pending block and the next ones. Scheduled fork upgrades
+	// replace it by a new config between two blocks, it is never written.
+	chainConfig *params.ChainConfig
+
+	// diagnostics of the latest failed commits
+	failures []*CommitFailure
+
+	// alerts on sustained gas limit utilization. nil if alerts are disabled
+	utilization *utilizationMonitor
+
+	// incremented whenever the pending state changes, see PendingSnapshot
+	version uint64
+
+	// traces the transactions delivered to every work. nil if not tracing
+	tracer vm.Tracer
+
+	// set once a commit failed after the state was committed, see HaltError
+	halted *HaltError
+	// set once the node shuts down, see Close
+	closed bool
+
+	// checked on every work before it is committed
+	invariants []namedInvariant
+
+	// slots for the copies of the pending state, see Pending and AcquireStateCopy
+	stateCopies stateCopies
+
+	// copy of the state the work started from, for CheckTx. It has its own lock
+	// so validating mempool transactions never waits for the block being delivered
+	checkMtx   sync.Mutex
+	checkState *state.StateDB
+	// established accounts of the block the check state leads to, see accountAgeState
+	checkAgeState *state.StateDB
+	// accounts of the check state
+	checkAccounts uint64
+}
+
+func newPending(config *Config) *pending {
+	p := &pending{mtx: &sync.Mutex{}, config: config, stateCopies: newStateCopies(config.MaxStateCopies)}
+	if config.UtilizationAlert != nil {
+		p.utilization = &utilizationMonitor{config: config.UtilizationAlert}
+	}
+	return p
 }
 
-func newPending() *pending {
-	return &pending{mtx: &sync.Mutex{}}
+// execute the transaction and return its receipt
+func (p *pending) deliverTx(blockchain *core.BlockChain, config *eth.Config, tx *ethTypes.Transaction) (*ethTypes.Receipt, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		return nil, ErrPendingClosed
+	}
+	if p.halted != nil {
+		return nil, p.halted
+	}
+	p.version++
+	return p.work.deliverTx(blockchain, config, p.config, p.chainConfig, tx)
 }
 
-// execute the transaction
-func (p *pending) deliverTx(blockchain *core.BlockChain, config *eth.Config, chainConfig *params.ChainConfig, tx *ethTypes.Transaction) error {
+// execute the transactions in order under a single lock and return the receipt
+// or the error of each. A failing transaction does not stop the ones after it.
+func (p *pending) deliverTxs(blockchain *core.BlockChain, config *eth.Config,
+	txs []*ethTypes.Transaction) ([]*ethTypes.Receipt, []error) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	blockHash := common.Hash{}
-	return p.work.deliverTx(blockchain, config, chainConfig, blockHash, tx)
+	receipts := make([]*ethTypes.Receipt, len(txs))
+	errs := make([]error, len(txs))
+	if p.closed {
+		for i := range errs {
+			errs[i] = ErrPendingClosed
+		}
+		return receipts, errs
+	}
+	if p.halted != nil {
+		for i := range errs {
+			errs[i] = p.halted
+		}
+		return receipts, errs
+	}
+	p.version++
+	for i, tx := range txs {
+		receipts[i], errs[i] = p.work.deliverTx(blockchain, config, p.config, p.chainConfig, tx)
+	}
+	return receipts, errs
 }
 
 // accumulate validator rewards
@@ -45,26 +127,154 @@ func (p *pending) accumulateRewards(strategy *emtTypes.Strategy) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	p.work.accumulateRewards(strategy)
+	if p.closed || p.halted != nil {
+		return
+	}
+	p.version++
+	p.work.accumulateRewards(strategy, p.config)
+	if strategy != nil && strategy.ChainUpgradeStrategy != nil {
+		p.work.upgrades = strategy.ChainUpgrades()
+	}
+}
+
+// collect the validator updates of the strategy from a copy of the pending
+// state, so the strategy neither sees a partial transaction nor changes the block
+func (p *pending) endBlock(strategy *emtTypes.Strategy) []*abciTypes.Validator {
+	if strategy == nil || strategy.ValidatorUpdateStrategy == nil {
+		return nil
+	}
+
+	p.mtx.Lock()
+	if p.halted != nil {
+		p.mtx.Unlock()
+		return nil
+	}
+	statedb := p.work.state.Copy()
+	p.mtx.Unlock()
+
+	return strategy.CollectValidatorUpdates(statedb)
 }
 
-// commit and reset the work
-func (p *pending) commit(blockchain *core.BlockChain, receiver common.Address) (common.Hash, error) {
+// commit and reset the work. The committed block and its logs are returned once
+// the block is in the chain, even if a later stage fails.
+func (p *pending) commit(blockchain *core.BlockChain, receiver common.Address) (*CommittedBlockEvent, error) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	blockHash, err := p.work.commit(blockchain)
+	if p.closed {
+		return nil, ErrPendingClosed
+	}
+	if p.halted != nil {
+		return nil, p.halted
+	}
+	// the rewards of the block are already accumulated in the work, so a work
+	// that failed to commit must never be committed again
+	if err := p.work.checkInvariants(p.invariants); err != nil {
+		p.recordFailure(CommitStageInvariant, err)
+		p.halted = &HaltError{err}
+		return nil, p.halted
+	}
+
+	p.version++
+	block, err := p.work.commit(blockchain, p.chainDb, p.config, p.chainConfig)
 	if err != nil {
-		return common.Hash{}, err
+		p.recordFailure("", err)
+		// the state of the block is on disk without the block, so the work must
+		// not be replaced by one on top of that state either
+		if cerr, ok := err.(*commitError); ok && (cerr.stage == CommitStageCheckpoint || cerr.stage == CommitStageInsert) {
+			if rerr := p.work.rollback(); rerr != nil {
+				log.Error("Error rolling back the pending state", "err", rerr)
+			}
+		}
+		p.halted = &HaltError{err}
+		return nil, p.halted
+	}
+	committed := &CommittedBlockEvent{Block: block, Logs: p.work.allLogs}
+	if committed.Logs == nil {
+		committed.Logs = []*ethTypes.Log{}
+	}
+
+	if p.utilization != nil {
+		head := blockchain.CurrentBlock()
+		recent := recentHeaders(blockchain, head, p.utilization.config.Window)
+		if alert := p.utilization.observe(head.NumberU64(), recent); alert != nil {
+			log.Warn("Sustained gas limit utilization, consider raising the gas limit",
+				"number", alert.BlockNumber, "utilization", alert.Utilization, "window", len(recent))
+		}
+	}
+
+	// the block is in the chain from here on, so a failure leaves a work that can
+	// neither be committed nor be extended and block processing halts
+
+	// the forks scheduled in this block apply from the next work on
+	if len(p.work.upgrades) > 0 {
+		chainConfig, err := applyForkUpgrades(p.chainConfig, blockchain.Genesis().Hash(), p.chainDb,
+			p.work.upgrades, p.work.header.Number)
+		if err != nil {
+			p.recordFailure(CommitStageUpgrade, err)
+			p.halted = &HaltError{err}
+			return committed, p.halted
+		}
+		p.chainConfig = chainConfig
 	}
 
 	work, err := p.resetWork(blockchain, receiver)
 	if err != nil {
-		return common.Hash{}, err
+		p.recordFailure(CommitStageReset, err)
+		p.halted = &HaltError{err}
+		return committed, p.halted
+	}
+	if p.config.VerifyCommittedRoot {
+		if err := verifyCommittedRoot(blockchain, block); err != nil {
+			p.recordFailure(CommitStageVerify, err)
+			p.halted = &HaltError{err}
+			return committed, p.halted
+		}
 	}
 
 	p.work = work
-	return blockHash, err
+	p.resetCheckState()
+	return committed, nil
+}
+
+// verifyCommittedRoot reloads the latest state from the chain and checks it
+// against the committed block
+func verifyCommittedRoot(blockchain *core.BlockChain, block *ethTypes.Block) error {
+	statedb, err := blockchain.State()
+	if err != nil {
+		return err
+	}
+	return checkCommittedRoot(block, blockchain.CurrentBlock(), statedb)
+}
+
+// checkCommittedRoot checks that the head of the chain is the committed block
+// and that the state reloaded at the head hashes to the root of the block
+func checkCommittedRoot(block, head *ethTypes.Block, statedb *state.StateDB) error {
+	if head.Hash() != block.Hash() {
+		return &RootMismatchError{block.NumberU64(), head.Root(), block.Root()}
+	}
+	if root := statedb.IntermediateRoot(false); root != block.Root() {
+		return &RootMismatchError{block.NumberU64(), root, block.Root()}
+	}
+	return nil
+}
+
+// Close discards the uncommitted work, resetting its state to the parent block,
+// and makes pending reject any further block processing. Tendermint replays the
+// discarded height when the node restarts.
+func (p *pending) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	p.version++
+	if p.work == nil || p.work.parent == nil {
+		return nil
+	}
+	return p.work.rollback()
 }
 
 // return a new work object with the latest block and state from the chain
@@ -75,7 +285,11 @@ func (p *pending) resetWork(blockchain *core.BlockChain, receiver common.Address
 	}
 
 	currentBlock := blockchain.CurrentBlock()
-	ethHeader := newBlockHeader(receiver, currentBlock)
+	ethHeader := newBlockHeader(receiver, currentBlock, calcGasLimit(blockchain, p.config, currentBlock))
+	ageState, err := accountAgeState(blockchain, p.config, ethHeader.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
 
 	return &work{
 		header:       ethHeader,
@@ -83,40 +297,177 @@ func (p *pending) resetWork(blockchain *core.BlockChain, receiver common.Address
 		state:        state,
 		txIndex:      0,
 		totalUsedGas: big.NewInt(0),
+		totalFees:    big.NewInt(0),
+		treasuryFees: big.NewInt(0),
+		refundFees:   big.NewInt(0),
+		failureFees:  big.NewInt(0),
+		burnedFees:   big.NewInt(0),
 		gp:           new(core.GasPool).AddGas(ethHeader.GasLimit),
+		blockReward:  big.NewInt(0),
+		immature:     immatureRewards(blockchain, currentBlock, p.config.CoinbaseMaturity),
+		senderGas:    make(map[common.Address]*big.Int),
+		execErrors:   make(map[string]uint64),
+		stateSize:    readStateSize(p.chainDb),
+		ageState:     ageState,
+		eip158:       p.chainConfig.IsEIP158(ethHeader.Number),
+		tracer:       p.tracer,
+		db:           p.chainDb,
 	}, nil
 }
 
-func (p *pending) updateHeaderWithTimeInfo(config *params.ChainConfig, parentTime uint64, numTx uint64) {
+func (p *pending) updateHeaderWithTimeInfo(parentTime uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.version++
+	p.work.updateHeaderWithTimeInfo(p.chainConfig, parentTime)
+}
+
+func (p *pending) beginBlock(numTxs int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.work.beginBlock(numTxs)
+}
+
+func (p *pending) setTracer(tracer vm.Tracer) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.tracer = tracer
+	if p.work != nil {
+		p.work.tracer = tracer
+	}
+}
+
+func (p *pending) setHeight(height uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.work.height = height
+}
+
+func (p *pending) txCount() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.work.transactions)
+}
+
+// GetNonce returns the next nonce of the account, counting the transactions
+// already delivered to the work
+func (p *pending) GetNonce(addr common.Address) uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.work.state.GetNonce(addr)
+}
+
+// fees sums gas used times gas price over the transactions of the work
+func (p *pending) fees() *big.Int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	fees := new(big.Int)
+	for i, tx := range p.work.transactions {
+		fees.Add(fees, new(big.Int).Mul(p.work.receipts[i].GasUsed, tx.GasPrice()))
+	}
+	return fees
+}
+
+// provisionalHash returns the hash of the block of the work as it would be committed now
+func (p *pending) provisionalHash() common.Hash {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.work.provisionalBlock().Hash()
+}
+
+func (p *pending) utilizationAlerts() []*UtilizationAlert {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.utilization == nil {
+		return []*UtilizationAlert{}
+	}
+	return append([]*UtilizationAlert{}, p.utilization.alerts...)
+}
+
+func (p *pending) intermediateRoot() common.Hash {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	p.work.updateHeaderWithTimeInfo(config, parentTime, numTx)
+	return p.work.state.IntermediateRoot(p.work.eip158)
+}
+
+// currentChainConfig returns the rules of the pending block. The config is
+// never written, so it may be read after the lock is released.
+func (p *pending) currentChainConfig() *params.ChainConfig {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.chainConfig
 }
 
 func (p *pending) gasLimit() big.Int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
 	return big.Int(*p.work.gp)
 }
 
+// blockGasLimit returns the gas limit in the header of the pending block, where
+// gasLimit is the gas left in it
+func (p *pending) blockGasLimit() *big.Int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return new(big.Int).Set(p.work.header.GasLimit)
+}
+
 //----------------------------------------------------------------------
 // Implements: miner.Pending API (our custom patch to go-ethereum)
 
-// Return a new block and a copy of the state from the latest work
+// Return the provisional block and a copy of the state from the latest work.
+// go-ethereum never says when it is done with the copy, so it takes no state
+// copy slot itself: eth_call and eth_estimateGas hold one in
+// PendingCallRPCService, and the other queries that reach it through the ABCI
+// Query hold one for their duration, see Backend.AcquireStateCopy.
 func (s *pending) Pending() (*ethTypes.Block, *state.StateDB) {
+	return s.provisional()
+}
+
+// pendingState returns the provisional block and a copy of the state from the
+// latest work, holding one of the MaxStateCopies slots until the returned
+// function is called. It waits up to wait for the slot.
+func (s *pending) pendingState(wait time.Duration) (*ethTypes.Block, *state.StateDB, func(), error) {
+	release, err := s.stateCopies.acquire(wait)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	block, statedb := s.provisional()
+	return block, statedb, release, nil
+}
+
+// provisional returns the provisional block and a copy of the state from the
+// latest work without taking a slot, for callers that hold one
+func (s *pending) provisional() (*ethTypes.Block, *state.StateDB) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	return ethTypes.NewBlock(
-		s.work.header,
-		s.work.transactions,
-		nil,
-		s.work.receipts,
-	), s.work.state.Copy()
+	return s.work.provisionalBlock(), s.work.state.Copy()
 }
 
 //----------------------------------------------------------------------
 //
 
+// pendingBlockHash is the block hash recorded with the logs of the pending
+// block. The hash is only known once the block is assembled in commit, which
+// replaces the placeholder in all logs of the block. The transaction hash and
+// index, and the index of the log in the block, are final when a log is
+// recorded.
+var pendingBlockHash = common.Hash{}
+
 // The work struct handles block processing.
 // It's updated with each DeliverTx and reset on Commit
 type work struct {
@@ -124,25 +475,289 @@ type work struct {
 	parent *ethTypes.Block
 	state  *state.StateDB
 
+	// index of the next transaction in the block, the number of transactions
+	// included so far. Rejected and failed applications do not advance it.
 	txIndex      int
 	transactions []*ethTypes.Transaction
 	receipts     ethTypes.Receipts
 	allLogs      []*ethTypes.Log
 
 	totalUsedGas *big.Int
+	totalFees    *big.Int
+	treasuryFees *big.Int // part of totalFees skimmed to the treasury
+	refundFees   *big.Int // gas refunds routed to the treasury
+	failureFees  *big.Int // part of totalFees refunded to the senders of failed transactions
+	burnedFees   *big.Int // funds taken out of the supply by the slashes
 	gp           *core.GasPool
+
+	// newly minted reward of this block, set in accumulateRewards
+	blockReward *big.Int
+	// rewards of recent blocks that may not be spent yet, per coinbase
+	immature map[common.Address]*big.Int
+	// gas used in this block, per sender
+	senderGas map[common.Address]*big.Int
+	// contracts successfully deployed in this block
+	creations []*ContractCreation
+	// rewards credited in accumulateRewards
+	rewards []*RewardEvent
+	// fork upgrades scheduled by the strategy in this block
+	upgrades []emtTypes.ForkUpgrade
+	// failed transactions of this block, per vm error category
+	execErrors map[string]uint64
+	// size of the state before this block
+	stateSize *StateSize
+	// committed state in which senders are established, see accountAgeState
+	ageState *state.StateDB
+	// whether the block deletes empty accounts, for the provisional root
+	eip158 bool
+	// contracts destroyed in this block
+	selfDestructs []*SelfDestruct
+	// storage slots changed in this block, in transaction order
+	slotWrites []*SlotWrite
+	// latest tendermint height that delivered to this block
+	height uint64
+	// optional tracer of the delivered transactions
+	tracer vm.Tracer
+	// database holding the ethermint indexes of the committed blocks
+	db ethdb.Database
+}
+
+// provisionalBlock assembles the block of the work as it would be committed now,
+// with the intermediate state root and the gas used so far. The root is taken
+// on a copy of the state: hashing the state finalises it, which is left to the
+// transactions and the commit of the block.
+func (w *work) provisionalBlock() *ethTypes.Block {
+	header := ethTypes.CopyHeader(w.header)
+	header.Root = w.state.Copy().IntermediateRoot(w.eip158) // like the receipts and intermediateRoot
+	header.GasUsed = new(big.Int).Set(w.totalUsedGas)
+	return ethTypes.NewBlock(header, w.transactions, nil, w.receipts)
+}
+
+// applyTransaction and insertChain are replaced in tests to inject failures
+var (
+	applyTransaction = applyStateTransition
+	insertChain      = (*core.BlockChain).InsertChain
+)
+
+// applyStateTransition is core.ApplyTransaction, except that it returns the gas
+// the execution consumed before the refund of the state transition instead of
+// the gas used, which is in the receipt. The difference is the refund granted
+// to the sender.
+func applyStateTransition(config *params.ChainConfig, bc *core.BlockChain, author *common.Address, gp *core.GasPool,
+	statedb *state.StateDB, header *ethTypes.Header, tx *ethTypes.Transaction, usedGas *big.Int,
+	cfg vm.Config) (*ethTypes.Receipt, *big.Int, error) {
+	msg, err := tx.AsMessage(ethTypes.MakeSigner(config, header.Number))
+	if err != nil {
+		return nil, nil, err
+	}
+	context := core.NewEVMContext(msg, header, bc, author)
+	evm := vm.NewEVM(context, statedb, config, cfg)
+	_, requiredGas, gas, err := core.NewStateTransition(evm, msg, gp).TransitionDb()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receipt := newTxReceipt(config, statedb, header, tx, usedGas, gas)
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(evm.Context.Origin, tx.Nonce())
+	}
+	return receipt, requiredGas, nil
+}
+
+// errAddressInUse fails contract creations at an address that already has a
+// nonce or code, see creationCollides
+var errAddressInUse = errors.New("contract address already in use")
+
+// creationCollides reports whether tx creates a contract at an address that
+// already has a nonce or code, which only genesis accounts can have. The vm of
+// this go-ethereum version would deploy over the account instead of failing the
+// creation, mixing the new contract with the existing state.
+func creationCollides(statedb *state.StateDB, from common.Address, tx *ethTypes.Transaction) bool {
+	if tx.To() != nil {
+		return false
+	}
+	addr := crypto.CreateAddress(from, tx.Nonce())
+	return statedb.GetNonce(addr) != 0 || statedb.GetCodeSize(addr) != 0
 }
 
-func (w *work) accumulateRewards(strategy *emtTypes.Strategy) {
-	ethash.AccumulateRewards(w.state, w.header, []*ethTypes.Header{})
-	w.header.GasUsed = w.totalUsedGas
+// applyCollidingCreation applies a contract creation that collides with an
+// existing account as a failed execution, in place of applyTransaction: the
+// sender buys the gas, all of which goes to the coinbase, and its nonce is
+// increased, but nothing is deployed. Like applyStateTransition it returns the
+// gas consumed before the refund, which is all the gas as there is none.
+func applyCollidingCreation(config *params.ChainConfig, bc *core.BlockChain, author *common.Address, gp *core.GasPool,
+	statedb *state.StateDB, header *ethTypes.Header, tx *ethTypes.Transaction, usedGas *big.Int,
+	cfg vm.Config) (*ethTypes.Receipt, *big.Int, error) {
+	msg, err := tx.AsMessage(ethTypes.MakeSigner(config, header.Number))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := gp.SubGas(tx.Gas()); err != nil {
+		return nil, nil, err
+	}
+	coinbase := header.Coinbase
+	if author != nil {
+		coinbase = *author
+	}
+
+	fee := new(big.Int).Mul(tx.Gas(), tx.GasPrice())
+	statedb.SubBalance(msg.From(), fee)
+	statedb.SetNonce(msg.From(), statedb.GetNonce(msg.From())+1)
+	statedb.AddBalance(coinbase, fee)
+
+	gas := new(big.Int).Set(tx.Gas())
+	receipt := newTxReceipt(config, statedb, header, tx, usedGas, gas)
+	receipt.ContractAddress = crypto.CreateAddress(msg.From(), tx.Nonce())
+	return receipt, gas, nil
+}
+
+// newTxReceipt adds the gas used by tx to the cumulative usedGas of the block
+// and returns the receipt of tx with the intermediate state root, as
+// core.ApplyTransaction does
+func newTxReceipt(config *params.ChainConfig, statedb *state.StateDB, header *ethTypes.Header,
+	tx *ethTypes.Transaction, usedGas, gas *big.Int) *ethTypes.Receipt {
+	usedGas.Add(usedGas, gas)
+	receipt := ethTypes.NewReceipt(statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes(), usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = new(big.Int).Set(gas)
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = ethTypes.CreateBloom(ethTypes.Receipts{receipt})
+	return receipt
+}
+
+// receiptStatusFailed is the ethermint encoding of a failed execution in the
+// receipts. go-ethereum 1.6 receipts have no status field, so the intermediate
+// state root, which is always a hash otherwise, is left empty instead. Other
+// ethereum clients do not read it as a failure.
+var receiptStatusFailed = []byte{}
+
+// markReceiptFailed replaces the intermediate state root of the receipt of a
+// transaction whose execution failed by receiptStatusFailed. The receipt stays
+// part of the receipt root of the block, so every node encodes it the same way.
+// The transaction stays in the block and is charged the gas it used.
+func markReceiptFailed(receipt *ethTypes.Receipt) {
+	receipt.PostState = receiptStatusFailed
+}
+
+// errCodeNotStored fails contract creations whose init code returned a contract
+// that could not be stored, see untracedFailure
+var errCodeNotStored = errors.New("contract code not stored: out of gas or over the code size limit")
+
+// untracedFailure returns the error of an execution that failed outside the
+// interpreter, where deliverTracer does not see it, or nil. These are a creation
+// whose code is over the size limit or runs out of gas when it is stored, and a
+// call to a precompiled contract that runs out of gas. Both consume all the gas
+// of the transaction and revert the new account, which a successful creation
+// keeps even without code. The outermost call frame never hits the call depth
+// limit, and the balance is checked before the execution.
+func untracedFailure(statedb *state.StateDB, tx *ethTypes.Transaction, receipt *ethTypes.Receipt, requiredGas *big.Int) error {
+	if requiredGas.Cmp(tx.Gas()) != 0 {
+		return nil
+	}
+	if tx.To() == nil {
+		if !statedb.Exist(receipt.ContractAddress) {
+			return errCodeNotStored
+		}
+		return nil
+	}
+	if precompiled, ok := vm.PrecompiledContracts[*tx.To()]; ok {
+		available := new(big.Int).Sub(tx.Gas(), core.IntrinsicGas(tx.Data(), false, true)) // homestead == true
+		if new(big.Int).SetUint64(precompiled.RequiredGas(len(tx.Data()))).Cmp(available) > 0 {
+			return vm.ErrOutOfGas
+		}
+	}
+	return nil
 }
 
 // Runs ApplyTransaction against the ethereum blockchain, fetches any logs,
-// and appends the tx, receipt, and logs
-func (w *work) deliverTx(blockchain *core.BlockChain, config *eth.Config, chainConfig *params.ChainConfig, blockHash common.Hash, tx *ethTypes.Transaction) error {
-	w.state.StartRecord(tx.Hash(), blockHash, w.txIndex)
-	receipt, _, err := core.ApplyTransaction(
+// and appends the tx, receipt, and logs. The receipt is returned.
+func (w *work) deliverTx(blockchain *core.BlockChain, config *eth.Config, emtConfig *Config,
+	chainConfig *params.ChainConfig, tx *ethTypes.Transaction) (*ethTypes.Receipt, error) {
+	if err := checkReplayProtection(emtConfig, chainConfig, w.header.Number, tx); err != nil {
+		return nil, &TxRejectedError{err}
+	}
+	signer := ethTypes.MakeSigner(chainConfig, w.header.Number)
+	from, err := ethTypes.Sender(signer, tx)
+	if err != nil {
+		return nil, &TxRejectedError{err}
+	}
+	if err := w.admitTx(emtConfig, from, tx); err != nil {
+		return nil, &TxRejectedError{err}
+	}
+	if err := w.checkTransaction(from, tx); err != nil {
+		return nil, &TxRejectedError{err}
+	}
+
+	// ApplyTransaction buys the gas of the transaction before it can fail, so the
+	// gas pool and the state are restored to keep the accounting of the block
+	snapshot := w.state.Snapshot()
+	gasLeft := new(big.Int).Set((*big.Int)(w.gp))
+	usedGas := new(big.Int).Set(w.totalUsedGas)
+
+	tracer := newDeliverTracer(emtConfig.ExecutionIndex, w.tracer)
+	w.state.StartRecord(tx.Hash(), pendingBlockHash, w.txIndex)
+	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+	receipt, err := w.applyTx(blockchain, emtConfig, chainConfig, from, tx, vmConfig, tracer)
+	// failing and out of gas executions do not return an error, they are
+	// included with their receipt. An error leaves the transaction out of the
+	// block: it is rejected if it is due to the transaction, otherwise the
+	// transaction was checked to be valid so it is a fault of the node.
+	if err != nil {
+		w.state.RevertToSnapshot(snapshot)
+		(*big.Int)(w.gp).Set(gasLeft)
+		w.totalUsedGas.Set(usedGas)
+		if rejection := rejectedExecution(err); rejection != nil {
+			return nil, &TxRejectedError{rejection}
+		}
+		return nil, err
+	}
+
+	logs := w.state.GetLogs(tx.Hash())
+
+	w.txIndex++
+	w.totalFees.Add(w.totalFees, new(big.Int).Mul(receipt.GasUsed, tx.GasPrice()))
+	w.chargeSenderGas(from, receipt.GasUsed)
+	txsDeliveredCounter.Inc()
+	if tracer.err != nil {
+		w.execErrors[execErrorKind(tracer.err)]++
+		txsFailedCounter.Inc()
+	}
+	for _, destruct := range tracer.selfDestructs {
+		// reverted self-destructs leave the contract in place
+		if !w.state.Exist(destruct.Contract) {
+			destruct.TxHash = tx.Hash()
+			w.selfDestructs = append(w.selfDestructs, destruct)
+		}
+	}
+	if tracer.writes != nil {
+		for _, change := range tracer.writes.changes(w.state) {
+			w.slotWrites = append(w.slotWrites, &SlotWrite{Address: change.Address, Slot: change.Slot, TxHash: tx.Hash()})
+		}
+	}
+	if tx.To() == nil && contractCreated(w.state, receipt.ContractAddress) {
+		w.creations = append(w.creations, &ContractCreation{Address: receipt.ContractAddress, TxHash: tx.Hash()})
+	}
+
+	w.appendTx(tx, receipt, logs)
+	return receipt, nil
+}
+
+// applyTx executes a delivered transaction on the state of the work with the
+// tracer and applies the ethermint rules to its outcome: the failure status of
+// the receipt, the treasury share of the refund, the failure refunds and the
+// gas subsidies. Colliding contract creations fail without execution.
+// deliverTx and the replays of the debug API share it, so a replayed block goes
+// through the same state transitions.
+func (w *work) applyTx(blockchain *core.BlockChain, emtConfig *Config, chainConfig *params.ChainConfig,
+	from common.Address, tx *ethTypes.Transaction, vmConfig vm.Config, tracer *deliverTracer) (*ethTypes.Receipt, error) {
+	// the tracer is needed for the failure status, see deliverTracer
+	vmConfig.Debug = true
+	vmConfig.Tracer = tracer
+	apply := applyTransaction
+	if creationCollides(w.state, from, tx) {
+		apply, tracer.err = applyCollidingCreation, errAddressInUse
+	}
+	receipt, requiredGas, err := apply(
 		chainConfig,
 		blockchain,
 		nil, // defaults to address of the author of the header
@@ -151,73 +766,151 @@ func (w *work) deliverTx(blockchain *core.BlockChain, config *eth.Config, chainC
 		w.header,
 		tx,
 		w.totalUsedGas,
-		vm.Config{EnablePreimageRecording: config.EnablePreimageRecording},
+		vmConfig,
 	)
 	if err != nil {
-		return err
-		log.Info("DeliverTx error", "err", err)
-		return abciTypes.ErrInternalError
+		return nil, err
 	}
 
-	logs := w.state.GetLogs(tx.Hash())
+	if tracer.err == nil {
+		tracer.err = untracedFailure(w.state, tx, receipt, requiredGas)
+	}
+	if tracer.err != nil {
+		markReceiptFailed(receipt)
+	}
+	if emtConfig.RefundTreasuryPercent > 0 {
+		w.routeRefund(emtConfig, from, tx, receipt, requiredGas)
+	}
+	if tracer.err != nil && len(emtConfig.FailureRefunds) > 0 {
+		w.refundFailure(emtConfig, from, tx, receipt)
+	}
+	if len(emtConfig.GasSubsidies) > 0 {
+		w.subsidizeGas(emtConfig, from, tx, receipt)
+	}
+	return receipt, nil
+}
 
-	w.txIndex++
+// beginBlock makes room for the transactions tendermint announced for the next
+// height, so delivering them does not grow the slices of the work. Logs are
+// sized for one per transaction.
+func (w *work) beginBlock(numTxs int) {
+	if cap(w.transactions)-len(w.transactions) < numTxs {
+		transactions := make([]*ethTypes.Transaction, len(w.transactions), len(w.transactions)+numTxs)
+		copy(transactions, w.transactions)
+		w.transactions = transactions
+	}
+	if cap(w.receipts)-len(w.receipts) < numTxs {
+		receipts := make(ethTypes.Receipts, len(w.receipts), len(w.receipts)+numTxs)
+		copy(receipts, w.receipts)
+		w.receipts = receipts
+	}
+	if cap(w.allLogs)-len(w.allLogs) < numTxs {
+		allLogs := make([]*ethTypes.Log, len(w.allLogs), len(w.allLogs)+numTxs)
+		copy(allLogs, w.allLogs)
+		w.allLogs = allLogs
+	}
+}
 
-	// The slices are allocated in updateHeaderWithTimeInfo
+// appendTx adds a delivered transaction with its receipt and logs to the block.
+// The slices are preallocated in beginBlock.
+func (w *work) appendTx(tx *ethTypes.Transaction, receipt *ethTypes.Receipt, logs []*ethTypes.Log) {
 	w.transactions = append(w.transactions, tx)
 	w.receipts = append(w.receipts, receipt)
 	w.allLogs = append(w.allLogs, logs...)
-
-	return err
 }
 
 // Commit the ethereum state, update the header, make a new block and add it
 // to the ethereum blockchain. The application root hash is the hash of the ethereum block.
-func (w *work) commit(blockchain *core.BlockChain) (common.Hash, error) {
+func (w *work) commit(blockchain *core.BlockChain, db ethdb.Database, config *Config,
+	chainConfig *params.ChainConfig) (*ethTypes.Block, error) {
+	start := time.Now()
+
+	// the consensus data of the header, checked before anything is written
+	extra, err := encodeBlockExtra(&blockExtra{Reward: w.blockReward, Height: w.height})
+	if err != nil {
+		return nil, &commitError{CommitStageHeader, err}
+	}
+	w.header.Extra = extra
 
 	// commit ethereum state and update the header
 	hashArray, err := w.state.Commit(false) // XXX: ugh hardforks
+	observeSince(stateCommitSeconds, start)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, &commitError{CommitStageState, err}
 	}
 	w.header.Root = hashArray
+	if expected, ok := config.StateCheckpoints[w.header.Number.Uint64()]; ok && hashArray != expected {
+		log.Error("State root does not match the checkpoint", "number", w.header.Number,
+			"root", hashArray, "expected", expected)
+		return nil, &commitError{CommitStageCheckpoint, &CheckpointError{w.header.Number.Uint64(), hashArray, expected}}
+	}
+	// NewBlock derives the same bloom from the receipts, set here so the header
+	// is complete for anyone reading it before the block
+	w.header.Bloom = ethTypes.CreateBloom(w.receipts)
 
 	// create block object and compute final commit hash (hash of the ethereum block)
 	block := ethTypes.NewBlock(w.header, w.transactions, nil, w.receipts)
 	blockHash := block.Hash()
 
+	// the transaction hash and the indexes were set by the state when the
+	// logs were recorded, the block is only known now, see pendingBlockHash
 	for _, log := range w.allLogs {
 		log.BlockHash = blockHash
+		log.BlockNumber = w.header.Number.Uint64()
 	}
 
 	// save the block to disk
 	log.Info("Committing block", "stateHash", hashArray, "blockHash", blockHash)
-	_, err = blockchain.InsertChain([]*ethTypes.Block{block})
+	_, err = insertChain(blockchain, []*ethTypes.Block{block})
 	if err != nil {
 		log.Info("Error inserting ethereum block in chain", "err", err)
-		return common.Hash{}, err
+		return nil, &commitError{CommitStageInsert, err}
+	}
+
+	// the block is final at this point, so a failing index must not halt the chain
+	if err := w.writeIndexes(blockchain, db, config, chainConfig, block); err != nil {
+		log.Error("Error writing block indexes", "blockHash", blockHash, "err", err)
 	}
-	return blockHash, err
+	observeSince(blockCommitSeconds, start)
+	blockGasUsedHistogram.Observe(float64(w.totalUsedGas.Uint64()))
+	return block, nil
 }
 
-func (w *work) updateHeaderWithTimeInfo(config *params.ChainConfig, parentTime uint64, numTx uint64) {
+// rollback returns the work to the state of its parent block after a commit
+// that failed past the state commit, so nothing reads the root of a block that
+// is not in the chain. The committed trie nodes stay on disk unreferenced.
+func (w *work) rollback() error {
+	statedb, err := state.New(w.parent.Root(), w.db)
+	if err != nil {
+		return err
+	}
+	w.state = statedb
+	w.header.Root = common.Hash{}
+	return nil
+}
+
+func (w *work) updateHeaderWithTimeInfo(config *params.ChainConfig, parentTime uint64) {
 	lastBlock := w.parent
+	// a block has to follow its parent in time. The genesis timestamp is set by
+	// hand and a skewed proposer may report a time that is not ahead either.
+	if parentTime <= lastBlock.Time().Uint64() {
+		log.Warn("Block time is not after the parent, correcting it", "number", w.header.Number,
+			"time", parentTime, "parentTime", lastBlock.Time())
+		parentTime = lastBlock.Time().Uint64() + 1
+	}
 	w.header.Time = new(big.Int).SetUint64(parentTime)
 	w.header.Difficulty = ethash.CalcDifficulty(config, parentTime,
 		lastBlock.Time().Uint64(), lastBlock.Number(), lastBlock.Difficulty())
-	w.transactions = make([]*ethTypes.Transaction, 0, numTx)
-	w.receipts = make([]*ethTypes.Receipt, 0, numTx)
-	w.allLogs = make([]*ethTypes.Log, 0, numTx)
 }
 
 //----------------------------------------------------------------------
 
 // Create a new block header from the previous block
-func newBlockHeader(receiver common.Address, prevBlock *ethTypes.Block) *ethTypes.Header {
+func newBlockHeader(receiver common.Address, prevBlock *ethTypes.Block, gasLimit *big.Int) *ethTypes.Header {
 	return &ethTypes.Header{
 		Number:     prevBlock.Number().Add(prevBlock.Number(), big.NewInt(1)),
 		ParentHash: prevBlock.Hash(),
-		GasLimit:   core.CalcGasLimit(prevBlock),
+		GasLimit:   gasLimit,
 		Coinbase:   receiver,
 	}
 }
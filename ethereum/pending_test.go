@@ -0,0 +1,584 @@
+package ethereum
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const benchmarkBlockTxs = 5000
+
+// deliverBenchmarkBlock appends benchmarkBlockTxs transactions with a log each
+// to an empty work, with or without announcing them in beginBlock first
+func deliverBenchmarkBlock(b *testing.B, preallocate bool) {
+	tx := new(ethTypes.Transaction)
+	receipt := new(ethTypes.Receipt)
+	logs := []*ethTypes.Log{new(ethTypes.Log)}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := &work{}
+		if preallocate {
+			w.beginBlock(benchmarkBlockTxs)
+		}
+		for j := 0; j < benchmarkBlockTxs; j++ {
+			w.appendTx(tx, receipt, logs)
+		}
+	}
+}
+
+func BenchmarkDeliverGrowingSlices(b *testing.B) {
+	deliverBenchmarkBlock(b, false)
+}
+
+func BenchmarkDeliverPreallocatedSlices(b *testing.B) {
+	deliverBenchmarkBlock(b, true)
+}
+
+// benchmarkCallCode writes a storage slot 50 times
+var benchmarkCallCode = common.FromHex(strings.Repeat("6001600055", 50))
+
+// executeBenchmarkCalls runs one call of benchmarkCallCode per iteration with
+// the given vm config, for the per transaction cost of the tracer of deliverTx
+func executeBenchmarkCalls(b *testing.B, vmConfig vm.Config) {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		b.Fatalf("Error creating database %v", err)
+	}
+	statedb, err := state.New(common.Hash{}, db)
+	if err != nil {
+		b.Fatalf("Error creating state %v", err)
+	}
+	from, contract := common.Address{1}, common.Address{2}
+	statedb.AddBalance(from, big.NewInt(1e+18))
+	statedb.SetCode(contract, benchmarkCallCode)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      from,
+		GasPrice:    big.NewInt(10),
+		GasLimit:    big.NewInt(1000000),
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+	}
+	evm := vm.NewEVM(context, statedb, &params.ChainConfig{HomesteadBlock: big.NewInt(0)}, vmConfig)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot := statedb.Snapshot()
+		if _, _, err := evm.Call(vm.AccountRef(from), contract, nil, 1000000, new(big.Int)); err != nil {
+			b.Fatalf("Error executing call %v", err)
+		}
+		statedb.RevertToSnapshot(snapshot)
+	}
+}
+
+func BenchmarkExecuteUntraced(b *testing.B) {
+	executeBenchmarkCalls(b, vm.Config{})
+}
+
+func BenchmarkExecuteDeliverTracer(b *testing.B) {
+	executeBenchmarkCalls(b, vm.Config{Debug: true, Tracer: newDeliverTracer(false, nil)})
+}
+
+func BenchmarkExecuteDeliverTracerIndexed(b *testing.B) {
+	executeBenchmarkCalls(b, vm.Config{Debug: true, Tracer: newDeliverTracer(true, nil)})
+}
+
+func TestBeginBlockKeepsDeliveredTxs(t *testing.T) {
+	w := &work{}
+	w.beginBlock(1)
+	tx := new(ethTypes.Transaction)
+	w.appendTx(tx, new(ethTypes.Receipt), nil)
+
+	// a later height of the same batch grows the slices once
+	w.beginBlock(2)
+	assert.Equal(t, []*ethTypes.Transaction{tx}, w.transactions)
+	assert.Equal(t, 3, cap(w.transactions))
+	assert.Equal(t, 3, cap(w.receipts))
+	assert.Equal(t, 2, cap(w.allLogs))
+}
+
+func TestFailedApplyRestoresGas(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Error generating key %v", err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	// 50000 gas of the block are already used
+	w := newTestPending(t, withBalance(from, 1e+18)).work
+	w.totalUsedGas.SetUint64(50000)
+	w.gp = new(core.GasPool).AddGas(big.NewInt(950000))
+	tx, err := ethTypes.SignTx(
+		ethTypes.NewTransaction(0, common.Address{1}, big.NewInt(0), big.NewInt(21000), big.NewInt(10), nil),
+		ethTypes.HomesteadSigner{},
+		key,
+	)
+	if err != nil {
+		t.Fatalf("Error creating transaction %v", err)
+	}
+
+	// buys the gas of the transaction and fails half way
+	errApply := errors.New("injected failure")
+	defer func() { applyTransaction = applyStateTransition }()
+	applyTransaction = func(config *params.ChainConfig, bc *core.BlockChain, author *common.Address, gp *core.GasPool,
+		statedb *state.StateDB, header *ethTypes.Header, tx *ethTypes.Transaction, usedGas *big.Int, cfg vm.Config) (*ethTypes.Receipt, *big.Int, error) {
+		if err := gp.SubGas(tx.Gas()); err != nil {
+			return nil, nil, err
+		}
+		statedb.SubBalance(from, new(big.Int).Mul(tx.Gas(), tx.GasPrice()))
+		usedGas.Add(usedGas, tx.Gas())
+		return nil, nil, errApply
+	}
+
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	receipt, err := w.deliverTx(nil, &eth.Config{}, &Config{}, chainConfig, tx)
+	assert.Equal(t, errApply, err)
+	assert.Nil(t, receipt)
+
+	assert.Equal(t, 0, (*big.Int)(w.gp).Cmp(big.NewInt(950000)))
+	assert.Equal(t, 0, w.totalUsedGas.Cmp(big.NewInt(50000)))
+	assert.Equal(t, 0, w.state.GetBalance(from).Cmp(big.NewInt(1e+18)))
+	assert.Equal(t, 0, w.txIndex)
+	assert.Equal(t, 0, len(w.transactions))
+}
+
+func TestApplyErrorsOfTheTxAreRejections(t *testing.T) {
+	key, txs := signedTransfers(t, 1)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	defer func() { applyTransaction = applyStateTransition }()
+
+	// a nil rejection is a fault of the node
+	errNonce := fmt.Errorf("invalid nonce: have %d, expected %d", 0, 1)
+	errApply := errors.New("injected failure")
+	for _, c := range []struct {
+		applyErr  error
+		rejection error
+	}{
+		{core.ErrGasLimitReached, ErrBlockFull},
+		{errNonce, errNonce},
+		{errApply, nil},
+	} {
+		applyErr := c.applyErr
+		applyTransaction = func(config *params.ChainConfig, bc *core.BlockChain, author *common.Address, gp *core.GasPool,
+			statedb *state.StateDB, header *ethTypes.Header, tx *ethTypes.Transaction, usedGas *big.Int, cfg vm.Config) (*ethTypes.Receipt, *big.Int, error) {
+			return nil, nil, applyErr
+		}
+
+		p := newTestPending(t, withBalance(from, 1e+18), withTransfersRoom(1))
+		_, err := p.work.deliverTx(nil, &eth.Config{}, &Config{}, chainConfig, txs[0])
+		rejected, ok := err.(*TxRejectedError)
+		if c.rejection == nil {
+			assert.False(t, ok, "expected a node error, got %v", err)
+			assert.Equal(t, c.applyErr, err)
+		} else if assert.True(t, ok, "expected a TxRejectedError, got %v", err) {
+			assert.Equal(t, c.rejection, rejected.Err)
+		}
+		assert.Equal(t, 0, len(p.work.transactions))
+	}
+}
+
+func TestPendingStateCopies(t *testing.T) {
+	addr := common.Address{1}
+	p := newTestPending(t, withBalance(addr, 1e+18))
+	p.config = &Config{MaxStateCopies: 1, StateCopyWait: 10 * time.Millisecond}
+	p.stateCopies = newStateCopies(1)
+
+	_, statedb, release, err := p.pendingState(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, statedb.GetBalance(addr).Cmp(big.NewInt(1e+18)))
+	_, _, _, err = p.pendingState(p.config.StateCopyWait)
+	assert.Equal(t, ErrStateCopiesBusy, err)
+
+	// the slot is free again once the copy is released
+	release()
+	_, statedb, release, err = p.pendingState(0)
+	assert.Nil(t, err)
+	assert.NotNil(t, statedb)
+	release()
+}
+
+func BenchmarkPendingBalance(b *testing.B) {
+	p := newTestPending(b, withAccounts(1000))
+	addr := common.BigToAddress(big.NewInt(1))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, statedb := p.Pending()
+		statedb.GetBalance(addr)
+	}
+}
+
+func BenchmarkPendingSnapshotBalance(b *testing.B) {
+	p := newTestPending(b, withAccounts(1000))
+	addr := common.BigToAddress(big.NewInt(1))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.snapshot().Balance(addr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPendingSnapshotStale(t *testing.T) {
+	addr := common.Address{1}
+	p := newTestPending(t, withBalance(addr, 10))
+
+	live, copied := p.snapshot(), p.snapshot()
+	_, err := copied.State()
+	assert.Nil(t, err)
+
+	// a change of the pending state invalidates the live reads only
+	p.version++
+	p.work.state.AddBalance(addr, big.NewInt(5))
+
+	_, err = live.Balance(addr)
+	assert.Equal(t, errStaleSnapshot, err)
+	balance, err := copied.Balance(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, balance.Cmp(big.NewInt(10)))
+
+	balance, err = p.snapshot().Balance(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, balance.Cmp(big.NewInt(15)))
+}
+
+func TestProvisionalHashKeepsState(t *testing.T) {
+	funded, touched := common.Address{1}, common.Address{2}
+	p := newTestPending(t, withBalance(funded, 10), withBalance(touched, 0))
+	p.work.eip158 = true
+	statedb := p.work.state
+
+	// the root drops the empty account, like the commit of the block
+	p.provisionalHash()
+	block, _ := p.provisional()
+	assert.Equal(t, statedb.Copy().IntermediateRoot(true), block.Root())
+	// the pending state itself is not finalised
+	assert.True(t, statedb.Exist(touched))
+}
+
+func TestPendingSnapshotStateCopies(t *testing.T) {
+	addr := common.Address{1}
+	p := newTestPending(t, withBalance(addr, 1e+18))
+	p.config = &Config{MaxStateCopies: 1, StateCopyWait: 10 * time.Millisecond}
+	p.stateCopies = newStateCopies(1)
+
+	held := p.snapshot()
+	statedb, err := held.State()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, statedb.GetBalance(addr).Cmp(big.NewInt(1e+18)))
+
+	// the copy keeps its slot until it is released
+	_, err = p.snapshot().State()
+	assert.Equal(t, ErrStateCopiesBusy, err)
+	held.Release()
+	_, err = p.snapshot().State()
+	assert.Nil(t, err)
+}
+
+func TestFailedInsertHalts(t *testing.T) {
+	addr := common.Address{1}
+	p := newTestPending(t, withBalance(addr, 10))
+	p.work.parent = ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(0)})
+	w := p.work
+
+	inserts := 0
+	defer func() { insertChain = (*core.BlockChain).InsertChain }()
+	insertChain = func(*core.BlockChain, ethTypes.Blocks) (int, error) {
+		inserts++
+		return 0, errors.New("injected failure")
+	}
+
+	committed, err := p.commit(nil, common.Address{})
+	assert.Nil(t, committed)
+	haltErr, ok := err.(*HaltError)
+	assert.True(t, ok, "expected a HaltError, got %v", err)
+
+	// the work is rolled back to its parent and not reset
+	assert.True(t, w == p.work)
+	assert.Equal(t, common.Hash{}, w.header.Root)
+	assert.Equal(t, 0, w.state.GetBalance(addr).Sign())
+	assert.Equal(t, CommitStageInsert, p.failures[0].Stage)
+
+	// nothing is processed until the node restarts
+	_, err = p.commit(nil, common.Address{})
+	assert.Equal(t, haltErr, err)
+	assert.Equal(t, 1, inserts)
+	_, err = p.deliverTx(nil, &eth.Config{}, nil)
+	assert.Equal(t, haltErr, err)
+}
+
+func TestUpdateHeaderTimeAfterParent(t *testing.T) {
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	parent := ethTypes.NewBlockWithHeader(&ethTypes.Header{
+		Number:     big.NewInt(5),
+		Time:       big.NewInt(100),
+		Difficulty: big.NewInt(131072),
+	})
+
+	for _, c := range []struct {
+		time, expected uint64
+	}{
+		{101, 101}, // ahead of the parent
+		{100, 101}, // equal to the parent
+		{90, 101},  // before the parent
+	} {
+		w := &work{header: &ethTypes.Header{Number: big.NewInt(6)}, parent: parent}
+		w.updateHeaderWithTimeInfo(chainConfig, c.time)
+		assert.Equal(t, c.expected, w.header.Time.Uint64(), "time %d", c.time)
+		assert.Equal(t, 1, w.header.Difficulty.Sign())
+	}
+}
+
+// signedTransfers returns n transfers of the key with the nonces 0 to n-1
+func signedTransfers(tb testing.TB, n int) (*ecdsa.PrivateKey, []*ethTypes.Transaction) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		tb.Fatalf("Error generating key %v", err)
+	}
+	txs := make([]*ethTypes.Transaction, n)
+	for i := range txs {
+		txs[i], err = ethTypes.SignTx(
+			ethTypes.NewTransaction(uint64(i), common.Address{1}, big.NewInt(1), big.NewInt(21000), big.NewInt(10), nil),
+			ethTypes.HomesteadSigner{},
+			key,
+		)
+		if err != nil {
+			tb.Fatalf("Error creating transaction %v", err)
+		}
+	}
+	return key, txs
+}
+
+// testPendingOption sets up the work of newTestPending
+type testPendingOption func(w *work)
+
+// withBalance funds the account with amount wei
+func withBalance(addr common.Address, amount int64) testPendingOption {
+	return func(w *work) { w.state.AddBalance(addr, big.NewInt(amount)) }
+}
+
+// withAccounts funds the accounts 1 to n with 1 ether each
+func withAccounts(n int) testPendingOption {
+	return func(w *work) {
+		for i := 0; i < n; i++ {
+			w.state.AddBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(1e+18))
+		}
+	}
+}
+
+// withCode deploys code at the address
+func withCode(addr common.Address, code []byte) testPendingOption {
+	return func(w *work) { w.state.SetCode(addr, code) }
+}
+
+// withTransfersRoom limits the gas of the block to txs transfers
+func withTransfersRoom(txs int) testPendingOption {
+	return func(w *work) {
+		gasLimit := big.NewInt(int64(21000 * txs))
+		w.header.GasLimit = gasLimit
+		w.gp = new(core.GasPool).AddGas(gasLimit)
+	}
+}
+
+// newTestPending returns a pending whose empty work builds block 1 with a gas
+// limit of 1000000 on a new in memory state, under the homestead rules
+func newTestPending(tb testing.TB, options ...testPendingOption) *pending {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		tb.Fatalf("Error creating database %v", err)
+	}
+	statedb, err := state.New(common.Hash{}, db)
+	if err != nil {
+		tb.Fatalf("Error creating state %v", err)
+	}
+
+	gasLimit := big.NewInt(1000000)
+	p := newPending(&Config{})
+	p.chainDb = db
+	p.chainConfig = &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	p.work = &work{
+		header: &ethTypes.Header{
+			Number:     big.NewInt(1),
+			Time:       big.NewInt(1),
+			Difficulty: big.NewInt(1),
+			GasLimit:   gasLimit,
+		},
+		state:        statedb,
+		totalUsedGas: big.NewInt(0),
+		totalFees:    big.NewInt(0),
+		gp:           new(core.GasPool).AddGas(gasLimit),
+		senderGas:    make(map[common.Address]*big.Int),
+		execErrors:   make(map[string]uint64),
+		db:           db,
+	}
+	for _, option := range options {
+		option(p.work)
+	}
+	return p
+}
+
+// stubApplyTransaction replaces the execution by a transfer that only buys the
+// gas and bumps the nonce of the sender
+func stubApplyTransaction(from common.Address) func() {
+	applyTransaction = func(config *params.ChainConfig, bc *core.BlockChain, author *common.Address, gp *core.GasPool,
+		statedb *state.StateDB, header *ethTypes.Header, tx *ethTypes.Transaction, usedGas *big.Int, cfg vm.Config) (*ethTypes.Receipt, *big.Int, error) {
+		if err := gp.SubGas(tx.Gas()); err != nil {
+			return nil, nil, err
+		}
+		statedb.SubBalance(from, new(big.Int).Mul(tx.Gas(), tx.GasPrice()))
+		statedb.SetNonce(from, tx.Nonce()+1)
+		usedGas.Add(usedGas, tx.Gas())
+		receipt := ethTypes.NewReceipt(nil, usedGas)
+		receipt.GasUsed = new(big.Int).Set(tx.Gas())
+		return receipt, tx.Gas(), nil
+	}
+	return func() { applyTransaction = applyStateTransition }
+}
+
+const benchmarkDeliverTxs = 1000
+
+// deliverBenchmarkTxs delivers benchmarkDeliverTxs transfers to a new pending
+// block per iteration, one call per transaction or all in one batch
+func deliverBenchmarkTxs(b *testing.B, batched bool) {
+	key, txs := signedTransfers(b, benchmarkDeliverTxs)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	defer stubApplyTransaction(from)()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		p := newTestPending(b, withBalance(from, 1e+18), withTransfersRoom(len(txs)))
+		b.StartTimer()
+
+		if batched {
+			_, errs := p.deliverTxs(nil, &eth.Config{}, txs)
+			for _, err := range errs {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			continue
+		}
+		for _, tx := range txs {
+			if _, err := p.deliverTx(nil, &eth.Config{}, tx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDeliverTxSingle(b *testing.B) {
+	deliverBenchmarkTxs(b, false)
+}
+
+func BenchmarkDeliverTxBatched(b *testing.B) {
+	deliverBenchmarkTxs(b, true)
+}
+
+func TestDeliverTxsContinuesAfterFailure(t *testing.T) {
+	key, txs := signedTransfers(t, 3)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	defer stubApplyTransaction(from)()
+
+	// the second transaction repeats the nonce of the first
+	txs = []*ethTypes.Transaction{txs[0], txs[0], txs[1]}
+	p := newTestPending(t, withBalance(from, 1e+18), withTransfersRoom(len(txs)))
+	version := p.version
+	receipts, errs := p.deliverTxs(nil, &eth.Config{}, txs)
+
+	assert.Nil(t, errs[0])
+	_, rejected := errs[1].(*TxRejectedError)
+	assert.True(t, rejected, "expected a TxRejectedError, got %v", errs[1])
+	assert.Nil(t, receipts[1])
+	assert.Nil(t, errs[2])
+	assert.Equal(t, 0, receipts[2].CumulativeGasUsed.Cmp(big.NewInt(42000)))
+
+	assert.Equal(t, []*ethTypes.Transaction{txs[0], txs[2]}, p.work.transactions)
+	assert.Equal(t, 0, p.work.totalUsedGas.Cmp(big.NewInt(42000)))
+	assert.Equal(t, uint64(2), p.work.state.GetNonce(from))
+	assert.Equal(t, version+1, p.version)
+}
+
+func TestCheckCommittedRoot(t *testing.T) {
+	p := newTestPending(t, withBalance(common.Address{1}, 10))
+	root, err := p.work.state.Commit(false)
+	if err != nil {
+		t.Fatalf("Error committing state %v", err)
+	}
+	reloaded, err := state.New(root, p.work.db)
+	if err != nil {
+		t.Fatalf("Error reloading state %v", err)
+	}
+
+	block := ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(1), Root: root})
+	assert.Nil(t, checkCommittedRoot(block, block, reloaded))
+
+	// the state on disk does not hash to the root the block was built with
+	corrupted := ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(1), Root: common.Hash{1}})
+	err = checkCommittedRoot(corrupted, corrupted, reloaded)
+	assert.Equal(t, &RootMismatchError{1, root, common.Hash{1}}, err)
+
+	// the head of the chain is not the committed block
+	err = checkCommittedRoot(corrupted, block, reloaded)
+	assert.Equal(t, &RootMismatchError{1, root, common.Hash{1}}, err)
+}
+
+func TestCloseDiscardsWork(t *testing.T) {
+	key, txs := signedTransfers(t, 2)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	defer stubApplyTransaction(from)()
+
+	// the work starts from the committed state of its parent
+	p := newTestPending(t, withBalance(from, 1e+18), withTransfersRoom(len(txs)))
+	root, err := p.work.state.Commit(false)
+	if err != nil {
+		t.Fatalf("Error committing state %v", err)
+	}
+	p.work.state, err = state.New(root, p.work.db)
+	if err != nil {
+		t.Fatalf("Error reloading state %v", err)
+	}
+	p.work.parent = ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: big.NewInt(0), Root: root})
+
+	_, err = p.deliverTx(nil, &eth.Config{}, txs[0])
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), p.work.state.GetNonce(from))
+
+	assert.Nil(t, p.Close())
+	assert.Nil(t, p.Close())
+
+	// nothing of the delivered transaction is left in the state
+	assert.Equal(t, uint64(0), p.work.state.GetNonce(from))
+	assert.Equal(t, 0, p.work.state.GetBalance(from).Cmp(big.NewInt(1e+18)))
+
+	_, err = p.deliverTx(nil, &eth.Config{}, txs[1])
+	assert.Equal(t, ErrPendingClosed, err)
+	_, errs := p.deliverTxs(nil, &eth.Config{}, txs[1:])
+	assert.Equal(t, []error{ErrPendingClosed}, errs)
+	_, err = p.commit(nil, common.Address{})
+	assert.Equal(t, ErrPendingClosed, err)
+}
@@ -0,0 +1,264 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	emtTypes "github.com/tendermint/ethermint/types"
+)
+
+var errImmatureBalance = errors.New("insufficient mature balance")
+
+//----------------------------------------------------------------------
+// Block rewards
+
+// Kinds of RewardEvent
+const (
+	RewardKindBlock    = "block"    // newly minted block reward
+	RewardKindFees     = "fees"     // transaction fees kept by the coinbase
+	RewardKindTreasury = "treasury" // transaction fees skimmed to the treasury
+	RewardKindRefund   = "refund"   // gas refunds routed to the treasury
+)
+
+// RewardEvent is an amount credited to a beneficiary when the rewards of a block are accumulated
+type RewardEvent struct {
+	Address common.Address `json:"address"`
+	Amount  *big.Int       `json:"amount"`
+	Kind    string         `json:"kind"`
+}
+
+func (w *work) accumulateRewards(strategy *emtTypes.Strategy, config *Config) {
+	w.skimTreasuryFee(config)
+	w.applySlashes(strategy)
+
+	// only what the coinbase receives is recorded as the block reward
+	if !config.FeeOnlyRewards {
+		before := new(big.Int).Set(w.state.GetBalance(w.header.Coinbase))
+		if strategy != nil && strategy.RewardStrategy != nil {
+			strategy.AccumulateRewards(w.state, w.header)
+		} else {
+			ethash.AccumulateRewards(w.state, w.header, []*ethTypes.Header{})
+		}
+		w.blockReward = new(big.Int).Sub(w.state.GetBalance(w.header.Coinbase), before)
+	}
+
+	// the fees were credited to the coinbase by ApplyTransaction
+	kept := new(big.Int).Sub(w.totalFees, w.failureFees)
+	w.addReward(w.header.Coinbase, kept.Sub(kept, w.treasuryFees), RewardKindFees)
+	w.addReward(config.TreasuryAddress, w.treasuryFees, RewardKindTreasury)
+	w.addReward(config.TreasuryAddress, w.refundFees, RewardKindRefund)
+	w.addReward(w.header.Coinbase, w.blockReward, RewardKindBlock)
+
+	w.header.GasUsed = w.totalUsedGas
+}
+
+func (w *work) addReward(addr common.Address, amount *big.Int, kind string) {
+	if amount.Sign() > 0 {
+		w.rewards = append(w.rewards, &RewardEvent{Address: addr, Amount: new(big.Int).Set(amount), Kind: kind})
+	}
+}
+
+// skimTreasuryFee moves the configured share of the block's transaction fees
+// from the coinbase, which was credited by ApplyTransaction, to the treasury.
+// The share is rounded down so that every validator computes the same split.
+func (w *work) skimTreasuryFee(config *Config) {
+	fees := new(big.Int).Sub(w.totalFees, w.failureFees)
+	if config.TreasuryFeePercent == 0 || fees.Sign() == 0 {
+		return
+	}
+
+	skim := new(big.Int).SetUint64(config.TreasuryFeePercent)
+	skim.Mul(skim, fees)
+	skim.Div(skim, big.NewInt(100))
+
+	// the coinbase may have spent part of its fees within the block
+	if balance := w.state.GetBalance(w.header.Coinbase); balance.Cmp(skim) < 0 {
+		skim = new(big.Int).Set(balance)
+	}
+
+	w.state.SubBalance(w.header.Coinbase, skim)
+	w.state.AddBalance(config.TreasuryAddress, skim)
+	w.treasuryFees.Add(w.treasuryFees, skim)
+}
+
+//----------------------------------------------------------------------
+// Gas refund routing
+
+// grantedRefund returns the gas refunded to the sender of a transaction, after
+// the refund cap of the fork. requiredGas is the gas the state transition
+// consumed before the refund, the gas bought minus the gas left after the
+// execution, so the refund is what it exceeds the gas charged in the receipt by.
+func grantedRefund(receipt *ethTypes.Receipt, requiredGas *big.Int) *big.Int {
+	refund := new(big.Int).Sub(requiredGas, receipt.GasUsed)
+	if refund.Sign() < 0 {
+		return new(big.Int)
+	}
+	return refund
+}
+
+// routeRefund moves RefundTreasuryPercent of the refund of a transaction from
+// its sender to the treasury. The amount is rounded down and bounded by the
+// balance of the sender, so every validator computes the same split.
+func (w *work) routeRefund(config *Config, from common.Address, tx *ethTypes.Transaction,
+	receipt *ethTypes.Receipt, requiredGas *big.Int) {
+	amount := grantedRefund(receipt, requiredGas)
+	amount.Mul(amount, tx.GasPrice())
+	amount.Mul(amount, new(big.Int).SetUint64(config.RefundTreasuryPercent))
+	amount.Div(amount, big.NewInt(100))
+
+	if balance := w.state.GetBalance(from); balance.Cmp(amount) < 0 {
+		amount = new(big.Int).Set(balance)
+	}
+	if amount.Sign() == 0 {
+		return
+	}
+
+	w.state.SubBalance(from, amount)
+	w.state.AddBalance(config.TreasuryAddress, amount)
+	w.refundFees.Add(w.refundFees, amount)
+}
+
+//----------------------------------------------------------------------
+// Fee refunds of failed transactions
+
+// refundFailure gives the FailureRefunds percentage of the recipient of a failed
+// transaction of its fee back to the sender, from the coinbase credited by
+// ApplyTransaction. The amount is rounded down and bounded by the balance of the
+// coinbase, so every validator computes the same refund.
+func (w *work) refundFailure(config *Config, from common.Address, tx *ethTypes.Transaction, receipt *ethTypes.Receipt) {
+	to := tx.To()
+	if to == nil {
+		return
+	}
+	percent := config.FailureRefunds[*to]
+	if percent == 0 {
+		return
+	}
+
+	amount := new(big.Int).Mul(receipt.GasUsed, tx.GasPrice())
+	amount.Mul(amount, new(big.Int).SetUint64(percent))
+	amount.Div(amount, big.NewInt(100))
+	if balance := w.state.GetBalance(w.header.Coinbase); balance.Cmp(amount) < 0 {
+		amount = new(big.Int).Set(balance)
+	}
+	if amount.Sign() == 0 {
+		return
+	}
+
+	w.state.SubBalance(w.header.Coinbase, amount)
+	w.state.AddBalance(from, amount)
+	w.failureFees.Add(w.failureFees, amount)
+}
+
+//----------------------------------------------------------------------
+// Gas subsidies
+
+// subsidizeGas pays the GasSubsidies percentage of the recipient of a transaction
+// of its fee back to the sender, from the SubsidyPool. The amount is rounded down
+// and bounded by the balance of the pool, so once the pool is depleted the sender
+// bears the full cost.
+func (w *work) subsidizeGas(config *Config, from common.Address, tx *ethTypes.Transaction, receipt *ethTypes.Receipt) {
+	to := tx.To()
+	if to == nil {
+		return
+	}
+	percent := config.GasSubsidies[*to]
+	if percent == 0 {
+		return
+	}
+
+	amount := new(big.Int).Mul(receipt.GasUsed, tx.GasPrice())
+	amount.Mul(amount, new(big.Int).SetUint64(percent))
+	amount.Div(amount, big.NewInt(100))
+	if balance := w.state.GetBalance(config.SubsidyPool); balance.Cmp(amount) < 0 {
+		amount = new(big.Int).Set(balance)
+	}
+	if amount.Sign() == 0 {
+		return
+	}
+
+	w.state.SubBalance(config.SubsidyPool, amount)
+	w.state.AddBalance(from, amount)
+}
+
+//----------------------------------------------------------------------
+// Coinbase reward maturity
+//
+// A block reward minted at height h can only be spent from height h+maturity on.
+// The immature amounts are derived from the recent headers only, their coinbase
+// and the minted reward recorded in their extra data, so every node computes the
+// same values whatever indexes it has. Blocks committed before the reward was
+// recorded count as minting nothing on all nodes alike.
+
+// immatureRewards sums the rewards minted to each coinbase in the maturity-1
+// blocks up to and including parent, which are not spendable in the next block
+func immatureRewards(blockchain *core.BlockChain, parent *ethTypes.Block, maturity uint64) map[common.Address]*big.Int {
+	immature := make(map[common.Address]*big.Int)
+	if maturity <= 1 {
+		return immature
+	}
+
+	for _, header := range recentHeaders(blockchain, parent, maturity-1) {
+		reward := decodeBlockExtra(header).Reward
+		if reward.Sign() == 0 {
+			continue
+		}
+		if sum, ok := immature[header.Coinbase]; ok {
+			sum.Add(sum, reward)
+		} else {
+			immature[header.Coinbase] = reward
+		}
+	}
+	return immature
+}
+
+// checkMaturity rejects transactions that would spend immature rewards of the sender
+func (w *work) checkMaturity(from common.Address, tx *ethTypes.Transaction) error {
+	locked, ok := w.immature[from]
+	if !ok {
+		return nil
+	}
+
+	spendable := new(big.Int).Sub(w.state.GetBalance(from), locked)
+	if spendable.Cmp(tx.Cost()) < 0 {
+		return fmt.Errorf("%v: spendable %v, immature %v, tx cost %v", errImmatureBalance, spendable, locked, tx.Cost())
+	}
+	return nil
+}
+
+//----------------------------------------------------------------------
+// Slashing
+
+// applySlashes takes the penalties of the strategy from the validator accounts.
+// A penalty is bounded by the balance of the account and the funds are burned,
+// accounted in burnedFees. Penalties without a positive amount are skipped.
+func (w *work) applySlashes(strategy *emtTypes.Strategy) {
+	if strategy == nil || strategy.SlashingStrategy == nil {
+		return
+	}
+
+	for _, slash := range strategy.Slashes() {
+		if slash.Amount == nil || slash.Amount.Sign() <= 0 {
+			log.Warn("Skipping slash without a positive amount", "address", slash.Address, "amount", slash.Amount)
+			continue
+		}
+		amount := slash.Amount
+		if balance := w.state.GetBalance(slash.Address); balance.Cmp(amount) < 0 {
+			amount = new(big.Int).Set(balance)
+		}
+		if amount.Sign() == 0 {
+			continue
+		}
+
+		log.Info("Slashing validator", "address", slash.Address, "amount", amount)
+		w.state.SubBalance(slash.Address, amount)
+		w.burnedFees.Add(w.burnedFees, amount)
+	}
+}
@@ -0,0 +1,82 @@
+package ethereum
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var errNoShards = errors.New("the number of shards must be positive")
+
+//----------------------------------------------------------------------
+// Sender based sharding hints for parallel execution experiments.
+// Nothing here takes part in block processing.
+
+// shardOf assigns an account to one of shards logical shards. The address is
+// hashed first so that consecutive or vanity addresses spread evenly.
+func shardOf(addr common.Address, shards uint64) uint64 {
+	return binary.BigEndian.Uint64(crypto.Keccak256(addr[:])[:8]) % shards
+}
+
+// TxShard is the shard of a transaction, the one of its sender
+type TxShard struct {
+	TxHash common.Hash    `json:"transactionHash"`
+	Sender common.Address `json:"sender"`
+	Shard  uint64         `json:"shard"`
+	// CrossShard is set if the recipient belongs to another shard. Contract
+	// creations stay in the shard of their sender.
+	CrossShard bool `json:"crossShard"`
+}
+
+// ShardLoad is the share of a block that falls into a shard
+type ShardLoad struct {
+	Transactions uint64 `json:"transactions"`
+	GasUsed      uint64 `json:"gasUsed"`
+}
+
+// BlockShards is the sharding of the transactions of a block
+type BlockShards struct {
+	Transactions []*TxShard  `json:"transactions"`
+	Loads        []ShardLoad `json:"loads"`
+	CrossShard   uint64      `json:"crossShard"`
+}
+
+// TransactionShards assigns the transactions of the committed block with the
+// given number to shards by sender, and reports which of them reach into
+// another shard and how the gas used of the block is spread over the shards
+func (b *Backend) TransactionShards(number, shards uint64) (*BlockShards, error) {
+	if shards == 0 {
+		return nil, errNoShards
+	}
+	block := b.ethereum.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	receipts := core.GetBlockReceipts(b.ethereum.ChainDb(), block.Hash(), number)
+
+	result := &BlockShards{Transactions: []*TxShard{}, Loads: make([]ShardLoad, shards)}
+	signer := ethTypes.MakeSigner(b.ChainConfig(), block.Number())
+	for i, tx := range block.Transactions() {
+		from, err := ethTypes.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		txShard := &TxShard{TxHash: tx.Hash(), Sender: from, Shard: shardOf(from, shards)}
+		if to := tx.To(); to != nil && shardOf(*to, shards) != txShard.Shard {
+			txShard.CrossShard = true
+			result.CrossShard++
+		}
+		result.Transactions = append(result.Transactions, txShard)
+
+		load := &result.Loads[txShard.Shard]
+		load.Transactions++
+		if i < len(receipts) {
+			load.GasUsed += receipts[i].GasUsed.Uint64()
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//----------------------------------------------------------------------
+// Pre-execution of transactions in CheckTx
+
+// SimulateTx executes tx on a copy of the pending state and returns the error
+// that made the execution fail, if any. core.ApplyTransaction only reports
+// consensus errors, so the message is run on the EVM directly to observe the
+// vm error of a failing call or creation. Nothing is written back.
+func (b *Backend) SimulateTx(tx *ethTypes.Transaction) error {
+	block, statedb, release, err := b.pending.pendingState(b.emtConfig.StateCopyWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	blockchain := b.ethereum.BlockChain()
+	header := block.Header()
+
+	chainConfig := b.ChainConfig()
+	msg, err := tx.AsMessage(ethTypes.MakeSigner(chainConfig, header.Number))
+	if err != nil {
+		return err
+	}
+	return runMessage(blockchain, chainConfig, header, statedb, msg, tx.Gas())
+}
+
+// runMessage executes msg with the given gas on statedb and returns the vm
+// error of a failing call or creation. The sender must afford the gas at the
+// gas price of msg and the value, but the gas is not bought from it.
+func runMessage(blockchain *core.BlockChain, chainConfig *params.ChainConfig, header *ethTypes.Header,
+	statedb *state.StateDB, msg core.Message, gas *big.Int) error {
+	intrGas := core.IntrinsicGas(msg.Data(), msg.To() == nil, true) // homestead == true
+	if gas.Cmp(intrGas) < 0 {
+		return core.ErrIntrinsicGas
+	}
+	cost := new(big.Int).Mul(gas, msg.GasPrice())
+	if statedb.GetBalance(msg.From()).Cmp(cost.Add(cost, msg.Value())) < 0 {
+		return core.ErrInsufficientFunds
+	}
+	left := new(big.Int).Sub(gas, intrGas).Uint64()
+
+	context := core.NewEVMContext(msg, header, blockchain, &header.Coinbase)
+	evm := vm.NewEVM(context, statedb, chainConfig, vm.Config{})
+	sender := vm.AccountRef(msg.From())
+	var err error
+	if msg.To() == nil {
+		_, _, _, err = evm.Create(sender, msg.Data(), left, msg.Value())
+	} else {
+		statedb.SetNonce(msg.From(), statedb.GetNonce(msg.From())+1)
+		_, _, err = evm.Call(sender, *msg.To(), msg.Data(), left, msg.Value())
+	}
+	return err
+}
+
+//----------------------------------------------------------------------
+// Gas estimation against the pending state
+
+// ErrGasEstimateFailed is returned when a message fails even with all the gas
+// it may use, so no gas limit makes it succeed
+var ErrGasEstimateFailed = errors.New("gas required exceeds allowance or always failing transaction")
+
+// estimateGas returns the lowest gas with which msg succeeds on the pending
+// state, searched between the intrinsic gas and the gas of the message, or the
+// gas limit of the block if the message has less than a transfer. The search is
+// capped by the gas the sender can pay for. Every run uses its own copy of the
+// state, the work and its gas pool are not touched.
+func (p *pending) estimateGas(blockchain *core.BlockChain, chainConfig *params.ChainConfig, msg core.Message) (uint64, error) {
+	p.mtx.Lock()
+	header := ethTypes.CopyHeader(p.work.header)
+	statedb := p.work.state.Copy()
+	p.mtx.Unlock()
+
+	run := func(gas uint64) error {
+		return runMessage(blockchain, chainConfig, header, statedb.Copy(), msg, new(big.Int).SetUint64(gas))
+	}
+
+	hi := header.GasLimit.Uint64()
+	if msg.Gas() != nil && msg.Gas().Cmp(params.TxGas) >= 0 {
+		hi = msg.Gas().Uint64()
+	}
+	if msg.GasPrice().Sign() > 0 {
+		available := new(big.Int).Sub(statedb.GetBalance(msg.From()), msg.Value())
+		if available.Sign() < 0 {
+			return 0, core.ErrInsufficientFunds
+		}
+		if allowance := available.Div(available, msg.GasPrice()); allowance.Cmp(new(big.Int).SetUint64(hi)) < 0 {
+			hi = allowance.Uint64()
+		}
+	}
+	if err := run(hi); err != nil {
+		return 0, fmt.Errorf("%v: %v", ErrGasEstimateFailed, err)
+	}
+
+	// lo always fails and hi always succeeds
+	lo := core.IntrinsicGas(msg.Data(), msg.To() == nil, true).Uint64() - 1
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if run(mid) == nil {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, nil
+}
+
+// EstimateGas returns the lowest gas limit with which msg succeeds on the
+// pending state, without changing it
+func (b *Backend) EstimateGas(msg core.Message) (uint64, error) {
+	release, err := b.AcquireStateCopy()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return b.pending.estimateGas(b.ethereum.BlockChain(), b.ChainConfig(), msg)
+}
+
+//----------------------------------------------------------------------
+// Bound on the state copies held by simulations
+
+// ErrStateCopiesBusy is returned when no state copy could be acquired in time
+var ErrStateCopiesBusy = errors.New("too many concurrent state copies, try again later")
+
+// stateCopies is a semaphore on the copies of the pending state. nil means unlimited.
+type stateCopies chan struct{}
+
+func newStateCopies(limit uint64) stateCopies {
+	if limit == 0 {
+		return nil
+	}
+	return make(stateCopies, limit)
+}
+
+// acquire waits up to wait for a free slot and returns the function that frees it
+func (s stateCopies) acquire(wait time.Duration) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	release := func() { <-s }
+
+	select {
+	case s <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if wait <= 0 {
+		return nil, ErrStateCopiesBusy
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case s <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrStateCopiesBusy
+	}
+}
+
+// AcquireStateCopy reserves one of the MaxStateCopies slots for a simulation on
+// a copy of the state, or a query that copies it through Pending. The returned
+// function must be called once the copy is no longer used.
+func (b *Backend) AcquireStateCopy() (func(), error) {
+	return b.pending.stateCopies.acquire(b.emtConfig.StateCopyWait)
+}
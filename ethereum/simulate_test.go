@@ -0,0 +1,70 @@
+package ethereum
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// adds 1 to the sum of the first three storage slots and stores it in slot 0
+	sumRuntimeCode = common.FromHex("0x600054600154016002540160010160005500")
+	// fails on an invalid opcode
+	failingRuntimeCode = []byte{0xfe}
+)
+
+func TestEstimateGas(t *testing.T) {
+	from, sum, failing := common.Address{1}, common.Address{2}, common.Address{3}
+	p := newTestPending(t, withBalance(from, 1e+18), withCode(sum, sumRuntimeCode), withCode(failing, failingRuntimeCode))
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+
+	// a transfer needs the intrinsic gas only
+	transfer := ethTypes.NewMessage(from, &common.Address{4}, 0, big.NewInt(1), nil, big.NewInt(10), nil, false)
+	gas, err := p.estimateGas(nil, chainConfig, transfer)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(21000), gas)
+
+	// a call is estimated to the lowest gas it succeeds with
+	call := ethTypes.NewMessage(from, &sum, 0, big.NewInt(0), big.NewInt(500000), big.NewInt(10), nil, false)
+	gas, err = p.estimateGas(nil, chainConfig, call)
+	assert.Nil(t, err)
+	assert.True(t, gas > 21000+20000, "gas %d does not pay the storage write", gas)
+	run := func(gas uint64) error {
+		return runMessage(nil, chainConfig, p.work.header, p.work.state.Copy(), call, new(big.Int).SetUint64(gas))
+	}
+	assert.Nil(t, run(gas))
+	assert.NotNil(t, run(gas-1))
+
+	// no gas makes a failing call succeed
+	fail := ethTypes.NewMessage(from, &failing, 0, big.NewInt(0), nil, big.NewInt(10), nil, false)
+	_, err = p.estimateGas(nil, chainConfig, fail)
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), ErrGasEstimateFailed.Error()), "unexpected error %v", err)
+
+	// the estimate is capped by the gas the sender can pay for
+	poor := common.Address{5}
+	p.work.state.AddBalance(poor, big.NewInt(21000*10+1))
+	transfer = ethTypes.NewMessage(poor, &common.Address{4}, 0, big.NewInt(1), nil, big.NewInt(10), nil, false)
+	gas, err = p.estimateGas(nil, chainConfig, transfer)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(21000), gas)
+	call = ethTypes.NewMessage(poor, &sum, 0, big.NewInt(0), nil, big.NewInt(10), nil, false)
+	_, err = p.estimateGas(nil, chainConfig, call)
+	assert.NotNil(t, err)
+	transfer = ethTypes.NewMessage(poor, &common.Address{4}, 0, big.NewInt(21000*10+2), nil, big.NewInt(10), nil, false)
+	_, err = p.estimateGas(nil, chainConfig, transfer)
+	assert.Equal(t, core.ErrInsufficientFunds, err)
+
+	// the pending work is left as it was
+	assert.Equal(t, uint64(0), p.work.state.GetNonce(from))
+	assert.Equal(t, 0, p.work.state.GetBalance(common.Address{4}).Sign())
+	assert.Equal(t, common.Hash{}, p.work.state.GetState(sum, common.Hash{}))
+	assert.Equal(t, 0, (*big.Int)(p.work.gp).Cmp(big.NewInt(1000000)))
+}
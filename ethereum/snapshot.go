@@ -0,0 +1,137 @@
+package ethereum
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+var errStaleSnapshot = errors.New("the pending block changed since the snapshot was taken")
+
+//----------------------------------------------------------------------
+// Read-only views of the pending block for frequent RPC queries
+
+// PendingSnapshot is an immutable view of the pending block at the time it was
+// taken. Unlike Pending it neither assembles a block nor copies the state up
+// front: Balance and Nonce read the live state of the work under the pending
+// mutex as long as no transaction, reward or commit changed it since, and fail
+// with errStaleSnapshot after that. State copies the state once, taking one of
+// the MaxStateCopies slots until Release; from then on all reads use the copy
+// and never go stale.
+//
+// A snapshot may be kept and used from any goroutine after the pending mutex is
+// released. Its reads never block delivering transactions for longer than a
+// single lookup, and they never observe a partially applied transaction.
+type PendingSnapshot struct {
+	// Header is a copy of the header of the pending block, without the state root
+	// and gas used which change with every transaction
+	Header *ethTypes.Header
+	// TxCount is the number of transactions delivered to the pending block
+	TxCount int
+
+	pending *pending
+	version uint64
+
+	mtx     sync.Mutex
+	state   *state.StateDB // lazily copied in State
+	release func()         // frees the state copy slot of state
+}
+
+// snapshot returns a view of the current work
+func (p *pending) snapshot() *PendingSnapshot {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return &PendingSnapshot{
+		Header:  ethTypes.CopyHeader(p.work.header),
+		TxCount: len(p.work.transactions),
+		pending: p,
+		version: p.version,
+	}
+}
+
+// view runs f on the state of the snapshot, the copy if there is one or else
+// the live state of the work while it is unchanged
+func (s *PendingSnapshot) view(f func(*state.StateDB)) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.state != nil {
+		f(s.state)
+		return nil
+	}
+
+	p := s.pending
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.version != s.version {
+		return errStaleSnapshot
+	}
+	f(p.work.state)
+	return nil
+}
+
+// Balance returns the balance of the account in the pending state
+func (s *PendingSnapshot) Balance(addr common.Address) (*big.Int, error) {
+	var balance *big.Int
+	err := s.view(func(statedb *state.StateDB) {
+		balance = statedb.GetBalance(addr)
+	})
+	return balance, err
+}
+
+// Nonce returns the nonce of the account in the pending state
+func (s *PendingSnapshot) Nonce(addr common.Address) (uint64, error) {
+	var nonce uint64
+	err := s.view(func(statedb *state.StateDB) {
+		nonce = statedb.GetNonce(addr)
+	})
+	return nonce, err
+}
+
+// State returns a copy of the pending state of the snapshot. It is copied on the
+// first call and shared by the later ones, so callers must not modify it. The
+// copy waits up to StateCopyWait for a state copy slot, which is held until
+// Release, and fails with ErrStateCopiesBusy after that.
+func (s *PendingSnapshot) State() (*state.StateDB, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.state != nil {
+		return s.state, nil
+	}
+
+	// wait for the slot before taking the pending mutex, not to hold up delivery
+	p := s.pending
+	release, err := p.stateCopies.acquire(p.config.StateCopyWait)
+	if err != nil {
+		return nil, err
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.version != s.version {
+		release()
+		return nil, errStaleSnapshot
+	}
+	s.state, s.release = p.work.state.Copy(), release
+	return s.state, nil
+}
+
+// Release drops the state copied by State and frees its slot. The copy must not
+// be used after, the reads of the snapshot go back to the live state of the
+// work. It does nothing if State was not called.
+func (s *PendingSnapshot) Release() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.release != nil {
+		s.release()
+	}
+	s.state, s.release = nil, nil
+}
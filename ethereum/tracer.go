@@ -0,0 +1,64 @@
+package ethereum
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+//----------------------------------------------------------------------
+// Tracer run by deliverTx for the failure status and the per block indexes
+
+// deliverTracer keeps the error that aborted the outermost call frame. The vm of
+// go-ethereum 1.6.1 only reports its errors to a tracer, so every transaction
+// runs with one for the failure status of its receipt, and untracedFailure
+// catches the failures outside the interpreter. The other steps return right
+// away unless the execution index is on, which records the contracts that
+// executed SELFDESTRUCT and the storage slots written, or an extra tracer is set.
+type deliverTracer struct {
+	err           error
+	selfDestructs []*SelfDestruct
+	writes        *storageWriteTracer // nil unless the execution index is on
+
+	// optional tracer of the node that sees every step too, see Backend.SetTracer
+	extra vm.Tracer
+}
+
+// newDeliverTracer returns a tracer that records the execution index if index
+// is set and passes every step on to extra if it is not nil
+func newDeliverTracer(index bool, extra vm.Tracer) *deliverTracer {
+	t := &deliverTracer{extra: extra}
+	if index {
+		t.writes = newStorageWriteTracer()
+	}
+	return t
+}
+
+// CaptureState implements vm.Tracer. The interpreter reports errors along
+// with the state of the failing step.
+func (t *deliverTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64,
+	memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.extra != nil {
+		t.extra.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
+	if err != nil {
+		if depth == 1 {
+			t.err = err
+		}
+		return nil
+	}
+	if t.writes == nil {
+		return nil
+	}
+
+	if op == vm.SSTORE {
+		t.writes.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
+	if op == vm.SELFDESTRUCT {
+		data := stack.Data()
+		t.selfDestructs = append(t.selfDestructs, &SelfDestruct{
+			Contract:    contract.Address(),
+			Beneficiary: common.BigToAddress(data[len(data)-1]),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+
+	emtTypes "github.com/tendermint/ethermint/types"
+)
+
+var (
+	errUnknownFork     = errors.New("unknown fork")
+	errForkActive      = errors.New("fork is already active")
+	errPastForkUpgrade = errors.New("activation height is not in the future")
+)
+
+//----------------------------------------------------------------------
+// Scheduling of fork upgrades at runtime
+
+// forkBlock returns the activation height field of the named fork. The DAO fork
+// is left out as it also changes the extra data rules of the headers.
+func forkBlock(config *params.ChainConfig, fork string) (**big.Int, error) {
+	switch fork {
+	case "homestead":
+		return &config.HomesteadBlock, nil
+	case "eip150":
+		return &config.EIP150Block, nil
+	case "eip155":
+		return &config.EIP155Block, nil
+	case "eip158":
+		return &config.EIP158Block, nil
+	}
+	return nil, fmt.Errorf("%v: %s", errUnknownFork, fork)
+}
+
+// scheduleFork moves the activation of a fork that is not active at height
+// to a later block.
+func scheduleFork(config *params.ChainConfig, upgrade emtTypes.ForkUpgrade, height *big.Int) error {
+	field, err := forkBlock(config, upgrade.Fork)
+	if err != nil {
+		return err
+	}
+	if current := *field; current != nil && current.Cmp(height) <= 0 {
+		return fmt.Errorf("%v: %s at %v", errForkActive, upgrade.Fork, current)
+	}
+	if upgrade.Block == nil || upgrade.Block.Cmp(height) <= 0 {
+		return fmt.Errorf("%v: %s at %v, height %v", errPastForkUpgrade, upgrade.Fork, upgrade.Block, height)
+	}
+
+	*field = new(big.Int).Set(upgrade.Block)
+	return nil
+}
+
+// applyForkUpgrades schedules the upgrades after the block at height was
+// committed and returns the chain config with the upgrades, which it also
+// stores for the next start. Invalid upgrades are skipped, which every
+// validator does alike.
+//
+// The given config is left untouched: it is shared with the readers of the
+// current rules, so the pending block swaps in the returned copy between two
+// blocks and every validator switches at the same block. The config of the
+// blockchain, the tx pool and the RPC handlers of go-ethereum is read without
+// any lock, so it only follows on restart, see restoreForkUpgrades.
+func applyForkUpgrades(current *params.ChainConfig, genesis common.Hash, db ethdb.Database,
+	upgrades []emtTypes.ForkUpgrade, height *big.Int) (*params.ChainConfig, error) {
+	config := *current
+	schedule := loadForkSchedule(db)
+	// check the upgrades against the forks scheduled so far
+	if err := applyForkSchedule(&config, schedule); err != nil {
+		return nil, err
+	}
+	for _, upgrade := range upgrades {
+		if err := scheduleFork(&config, upgrade, height); err != nil {
+			log.Warn("Rejected fork upgrade", "err", err)
+			continue
+		}
+		schedule[upgrade.Fork] = upgrade.Block
+		log.Info("Scheduled fork upgrade", "fork", upgrade.Fork, "block", upgrade.Block)
+	}
+	if err := writeIndex(db, forkScheduleKey, schedule); err != nil {
+		return nil, err
+	}
+	if err := core.WriteChainConfig(db, genesis, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// scheduledChainConfig returns a copy of config with the forks scheduled so far
+func scheduledChainConfig(config *params.ChainConfig, db ethdb.Database) (*params.ChainConfig, error) {
+	scheduled := *config
+	if err := applyForkSchedule(&scheduled, loadForkSchedule(db)); err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+// applyForkSchedule sets the activation blocks of the scheduled forks in config
+func applyForkSchedule(config *params.ChainConfig, schedule map[string]*big.Int) error {
+	for fork, block := range schedule {
+		field, err := forkBlock(config, fork)
+		if err != nil {
+			return err
+		}
+		*field = new(big.Int).Set(block)
+	}
+	return nil
+}
+
+// loadForkSchedule returns the activation blocks of the forks the strategy
+// scheduled so far
+func loadForkSchedule(db ethdb.Database) map[string]*big.Int {
+	schedule := make(map[string]*big.Int)
+	readIndex(db, forkScheduleKey, &schedule)
+	return schedule
+}
+
+// restoreForkUpgrades applies the upgrades scheduled so far to the config of
+// the genesis before go-ethereum starts. It replaces the stored chain config by
+// the one of the genesis, and rewinds the chain if they differ on a fork the
+// head passed, so without them every restart would drop the upgrades.
+func restoreForkUpgrades(ctx *node.ServiceContext, config *eth.Config) error {
+	if config.Genesis == nil || config.Genesis.Config == nil {
+		return nil
+	}
+	db, err := ctx.OpenDatabase("chaindata", config.DatabaseCache, config.DatabaseHandles)
+	if err != nil {
+		return err
+	}
+	schedule := loadForkSchedule(db)
+	db.Close()
+	if len(schedule) == 0 {
+		return nil
+	}
+
+	chainConfig := *config.Genesis.Config
+	if err := applyForkSchedule(&chainConfig, schedule); err != nil {
+		return err
+	}
+	genesis := *config.Genesis
+	genesis.Config = &chainConfig
+	config.Genesis = &genesis
+	log.Info("Restored scheduled fork upgrades", "forks", len(schedule))
+	return nil
+}
@@ -0,0 +1,34 @@
+package ethereum
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+//----------------------------------------------------------------------
+// Categories of the vm errors that made a transaction fail
+
+const (
+	ExecErrorOutOfGas      = "out-of-gas"
+	ExecErrorInvalidOpcode = "invalid-opcode"
+	ExecErrorInvalidJump   = "invalid-jump"
+	ExecErrorStack         = "stack"
+	ExecErrorOther         = "other"
+)
+
+// execErrorKind returns the category of a vm error
+func execErrorKind(err error) string {
+	msg := err.Error()
+	switch {
+	case err == vm.ErrOutOfGas:
+		return ExecErrorOutOfGas
+	case strings.HasPrefix(msg, "invalid opcode"):
+		return ExecErrorInvalidOpcode
+	case strings.HasPrefix(msg, "invalid jump destination"):
+		return ExecErrorInvalidJump
+	case strings.HasPrefix(msg, "stack underflow"), strings.HasPrefix(msg, "stack limit reached"):
+		return ExecErrorStack
+	}
+	return ExecErrorOther
+}
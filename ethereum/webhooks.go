@@ -0,0 +1,193 @@
+package ethereum
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	defaultWebhookQueueSize = 1024
+	defaultWebhookRetries   = 3
+	defaultWebhookBackoff   = time.Second
+	defaultWebhookTimeout   = 10 * time.Second
+)
+
+// WebhookConfig posts the results of the committed transactions to URLs for
+// integrations. Zero values take the defaults.
+type WebhookConfig struct {
+	URLs []string
+
+	// QueueSize is the number of results buffered per URL. Results are dropped
+	// while the queue is full, so a slow endpoint never holds up a commit.
+	QueueSize int
+
+	// Retries is the number of times a failed post is repeated, waiting Backoff
+	// before the first and doubling the wait with every further retry
+	Retries int
+	Backoff time.Duration
+
+	// Timeout bounds a single post
+	Timeout time.Duration
+}
+
+// TxResult is the payload posted for a committed transaction
+type TxResult struct {
+	TxHash      common.Hash     `json:"transactionHash"`
+	Status      string          `json:"status"`
+	BlockNumber uint64          `json:"blockNumber"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	GasUsed     uint64          `json:"gasUsed"`
+	Logs        []*ethTypes.Log `json:"logs"`
+}
+
+const (
+	TxStatusSuccess = "success"
+	TxStatusFailed  = "failed"
+)
+
+//----------------------------------------------------------------------
+// webhooks posts transaction results from one goroutine per URL
+
+type webhooks struct {
+	config  WebhookConfig
+	client  *http.Client
+	queues  []chan *TxResult
+	quit    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// newWebhooks starts the workers posting to the configured URLs
+func newWebhooks(config *WebhookConfig) *webhooks {
+	cfg := *config
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultWebhookQueueSize
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = defaultWebhookRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultWebhookBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+
+	h := &webhooks{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		quit:   make(chan struct{}),
+	}
+	for _, url := range cfg.URLs {
+		queue := make(chan *TxResult, cfg.QueueSize)
+		h.queues = append(h.queues, queue)
+		h.stopped.Add(1)
+		go h.loop(url, queue)
+	}
+	return h
+}
+
+// blockCommitted queues the results of the transactions of the block without
+// waiting for any of them to be posted
+func (h *webhooks) blockCommitted(block *ethTypes.Block, receipts ethTypes.Receipts) {
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) {
+			break
+		}
+		result := newTxResult(block, tx, receipts[i])
+		for j, queue := range h.queues {
+			select {
+			case queue <- result:
+			default:
+				log.Warn("Webhook queue is full, dropping transaction result",
+					"url", h.config.URLs[j], "hash", result.TxHash)
+			}
+		}
+	}
+}
+
+func newTxResult(block *ethTypes.Block, tx *ethTypes.Transaction, receipt *ethTypes.Receipt) *TxResult {
+	status := TxStatusSuccess
+	if bytes.Equal(receipt.PostState, receiptStatusFailed) {
+		status = TxStatusFailed
+	}
+	logs := receipt.Logs
+	if logs == nil {
+		logs = []*ethTypes.Log{}
+	}
+	return &TxResult{
+		TxHash:      tx.Hash(),
+		Status:      status,
+		BlockNumber: block.NumberU64(),
+		BlockHash:   block.Hash(),
+		GasUsed:     receipt.GasUsed.Uint64(),
+		Logs:        logs,
+	}
+}
+
+func (h *webhooks) loop(url string, queue chan *TxResult) {
+	defer h.stopped.Done()
+	for {
+		select {
+		case result := <-queue:
+			h.deliver(url, result)
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// deliver posts the result, retrying with exponential backoff until the
+// retries are used up or the webhooks are stopped
+func (h *webhooks) deliver(url string, result *TxResult) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Error("Error encoding transaction result", "hash", result.TxHash, "err", err)
+		return
+	}
+
+	backoff := h.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err := h.post(url, body)
+		if err == nil {
+			return
+		}
+		if attempt >= h.config.Retries {
+			log.Warn("Giving up posting transaction result", "url", url, "hash", result.TxHash, "err", err)
+			return
+		}
+		log.Debug("Error posting transaction result, retrying", "url", url, "hash", result.TxHash,
+			"backoff", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+func (h *webhooks) post(url string, body []byte) error {
+	resp, err := h.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// stop ends the workers, dropping the results that are still queued
+func (h *webhooks) stop() {
+	close(h.quit)
+	h.stopped.Wait()
+}
@@ -1,7 +1,10 @@
 package types
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 
 	"github.com/tendermint/abci/types"
@@ -17,7 +20,54 @@ type ValidatorsStrategy interface {
 	GetUpdatedValidators() []*types.Validator
 }
 
+// Slash is a penalty taken from the ethereum account of a misbehaving validator
+type Slash struct {
+	Address common.Address
+	Amount  *big.Int
+}
+
+// SlashingStrategy is an optional strategy that turns the evidence it collected
+// into penalties, applied when the rewards of a block are accumulated.
+// The penalties must be computed deterministically.
+type SlashingStrategy interface {
+	Slashes() []Slash
+}
+
+// RewardStrategy is an optional strategy that replaces the ethash block reward.
+// It credits the rewards of the block to the state when they are accumulated,
+// after the fees and slashes. The rewards must be computed deterministically.
+type RewardStrategy interface {
+	AccumulateRewards(state *state.StateDB, header *ethTypes.Header)
+}
+
+// ForkUpgrade moves the activation height of a go-ethereum fork
+type ForkUpgrade struct {
+	Fork  string
+	Block *big.Int
+}
+
+// ChainUpgradeStrategy is an optional strategy that schedules fork upgrades,
+// collected when the rewards of a block are accumulated and applied once the
+// block is committed, so every validator executes the blocks from the
+// activation height on with the fork. The upgrades must be computed
+// deterministically.
+type ChainUpgradeStrategy interface {
+	ChainUpgrades() []ForkUpgrade
+}
+
+// ValidatorUpdateStrategy is an optional strategy that lets the ethereum state,
+// for example a staking contract, change the validator set. It reads a copy of
+// the pending state at the end of every block and returns the validators to
+// add, update or remove (power 0). The updates must be computed deterministically.
+type ValidatorUpdateStrategy interface {
+	CollectValidatorUpdates(state *state.StateDB) []*types.Validator
+}
+
 type Strategy struct {
 	MinerRewardStrategy
 	ValidatorsStrategy
+	SlashingStrategy
+	ChainUpgradeStrategy
+	RewardStrategy
+	ValidatorUpdateStrategy
 }